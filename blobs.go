@@ -87,6 +87,10 @@ type BlobDescriptorService interface {
 	// the restriction that the algorithm of the descriptor must match the
 	// canonical algorithm (ie sha256) of the annotator.
 	SetDescriptor(ctx context.Context, dgst digest.Digest, desc Descriptor) error
+
+	// Clear removes the descriptor for the digest from the service. If the
+	// digest is unknown to the service, ErrBlobUnknown will be returned.
+	Clear(ctx context.Context, dgst digest.Digest) error
 }
 
 // ReadSeekCloser is the primary reader type for blob data, combining
@@ -107,6 +111,13 @@ type BlobProvider interface {
 	Open(ctx context.Context, dgst digest.Digest) (ReadSeekCloser, error)
 }
 
+// BlobDeleter enables deleting blobs from storage.
+type BlobDeleter interface {
+	// Delete removes the blob identified by the digest from the service. If
+	// the blob is unknown to the service, ErrBlobUnknown will be returned.
+	Delete(ctx context.Context, dgst digest.Digest) error
+}
+
 // BlobServer can serve blobs via http.
 type BlobServer interface {
 	// ServeBlob attempts to serve the blob, identifed by dgst, via http. The
@@ -138,6 +149,12 @@ type BlobIngester interface {
 
 	// Resume attempts to resume a write to a blob, identified by an id.
 	Resume(ctx context.Context, id string) (BlobWriter, error)
+
+	// Mount makes the blob identified by dgst, already present in the
+	// repository named sourceRepo, available in this service without
+	// transferring any data. If the blob is not known to the source
+	// repository, ErrBlobUnknown will be returned.
+	Mount(ctx context.Context, sourceRepo string, dgst digest.Digest) (Descriptor, error)
 }
 
 // BlobWriter provides a handle for inserting data into a blob store.
@@ -180,6 +197,7 @@ type BlobService interface {
 	BlobStatter
 	BlobProvider
 	BlobIngester
+	BlobDeleter
 }
 
 // BlobStore represent the entire suite of blob related operations. Such an
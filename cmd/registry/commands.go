@@ -0,0 +1,554 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/handlers"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	"github.com/docker/distribution/version"
+	"github.com/docker/libtrust"
+)
+
+// command is a registry subcommand, run in place of the registry server.
+// Each command parses its own flags from the arguments that follow its name.
+type command struct {
+	name        string
+	description string
+	run         func(args []string)
+}
+
+// commands lists the available subcommands, in the order that they are shown
+// in the usage output.
+var commands = []command{
+	{
+		name:        "garbage-collect",
+		description: "remove blobs that are not referenced by any repository",
+		run:         garbageCollect,
+	},
+	{
+		name:        "du",
+		description: "report the storage used by each repository",
+		run:         diskUsage,
+	},
+	{
+		name:        "retention",
+		description: "report the tags that the retention policies would prune",
+		run:         retentionReport,
+	},
+	{
+		name:        "copy",
+		description: "copy a repository to a new name, without copying blob data",
+		run:         copyRepository,
+	},
+	{
+		name:        "rename",
+		description: "rename a repository, without copying blob data",
+		run:         renameRepository,
+	},
+	{
+		name:        "uploads",
+		description: "list the uploads in progress, or cancel one",
+		run:         listUploads,
+	},
+	{
+		name:        "scrub",
+		description: "verify that the data of each blob matches its digest",
+		run:         scrubBlobs,
+	},
+	{
+		name:        "fsck",
+		description: "check the consistency of the repositories in the storage",
+		run:         checkStorage,
+	},
+	{
+		name:        "export",
+		description: "write a repository, or one of its tags, to a tar archive",
+		run:         exportRepository,
+	},
+	{
+		name:        "import",
+		description: "restore a repository from an archive written by export",
+		run:         importRepository,
+	},
+}
+
+// lookupCommand returns the subcommand with the given name.
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+
+	return command{}, false
+}
+
+// newCommandFlagSet returns a flag set for the named command, with usage
+// output describing the command's arguments.
+func newCommandFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage:", os.Args[0], name, "[options]", arguments)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// commandFatalf prints the error along with the usage of the flag set and
+// exits.
+func commandFatalf(fs *flag.FlagSet, format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	fs.Usage()
+	os.Exit(1)
+}
+
+// setupCommand resolves the configuration from the remaining arguments in
+// fs, configures logging and creates the storage driver, as the registry
+// would.
+func setupCommand(fs *flag.FlagSet) (context.Context, *configuration.Configuration, storagedriver.StorageDriver) {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, "version", version.Version)
+
+	config, err := resolveConfiguration(fs.Args())
+	if err != nil {
+		commandFatalf(fs, "configuration error: %v", err)
+	}
+
+	ctx, err = configureLogging(ctx, config)
+	if err != nil {
+		commandFatalf(fs, "error configuring logger: %v", err)
+	}
+
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		commandFatalf(fs, "failed to construct %s driver: %v", config.Storage.Type(), err)
+	}
+
+	return ctx, config, driver
+}
+
+// garbageCollect removes the data of blobs which are not referenced by any
+// repository. Unless a grace period is given, the registry must not accept
+// writes while this runs.
+func garbageCollect(args []string) {
+	fs := newCommandFlagSet("garbage-collect", "<config>")
+	dryRun := fs.Bool("dry-run", false, "print the blobs that would be deleted, without deleting them")
+	gracePeriod := fs.Duration("grace-period", 0, "never delete blobs modified within this period before the collection starts, allowing it to run while registries with online garbage collection enabled are serving")
+	fs.Parse(args)
+
+	ctx, _, driver := setupCommand(fs)
+
+	swept, err := storage.MarkAndSweep(ctx, driver, *gracePeriod, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to garbage collect: %v\n", err)
+		os.Exit(1)
+	}
+
+	var reclaimed int64
+	for _, desc := range swept {
+		if *dryRun {
+			fmt.Printf("would delete blob %v (%d bytes)\n", desc.Digest, desc.Length)
+		} else {
+			fmt.Printf("deleted blob %v (%d bytes)\n", desc.Digest, desc.Length)
+		}
+		reclaimed += desc.Length
+	}
+
+	if *dryRun {
+		fmt.Printf("%d blobs eligible for deletion, %d bytes would be reclaimed\n", len(swept), reclaimed)
+	} else {
+		fmt.Printf("%d blobs deleted, %d bytes reclaimed\n", len(swept), reclaimed)
+	}
+}
+
+// diskUsage reports the bytes used by each repository, along with the bytes
+// shared between repositories and those not referenced by any of them.
+func diskUsage(args []string) {
+	fs := newCommandFlagSet("du", "<config>")
+	format := fs.String("format", "table", "output format, either table or json")
+	fs.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		commandFatalf(fs, "unknown format %q", *format)
+	}
+
+	ctx, _, driver := setupCommand(fs)
+
+	usage, err := storage.ReportUsage(ctx, driver)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to report storage usage: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		p, err := json.MarshalIndent(usage, "", "   ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode storage usage: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(p))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tBLOBS\tLOGICAL\tUNIQUE\tSHARED\t")
+	for _, repo := range usage.Repositories {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t\n", repo.Name, repo.Blobs, repo.LogicalBytes, repo.UniqueBytes, repo.SharedBytes)
+	}
+	tw.Flush()
+
+	fmt.Println()
+	fmt.Printf("total:    %d bytes in the blob store\n", usage.TotalBytes)
+	fmt.Printf("logical:  %d bytes linked into repositories\n", usage.LogicalBytes)
+	fmt.Printf("unique:   %d bytes linked into a single repository\n", usage.UniqueBytes)
+	fmt.Printf("shared:   %d bytes linked into several repositories, saving %d bytes\n", usage.SharedBytes, usage.SavedBytes)
+	fmt.Printf("orphaned: %d bytes not linked into any repository\n", usage.OrphanedBytes)
+}
+
+// retentionReport lists the tags that the configured retention policies
+// select for pruning, without removing them. The registry removes them in
+// the background unless its retention is configured as a dry run.
+func retentionReport(args []string) {
+	fs := newCommandFlagSet("retention", "<config>")
+	format := fs.String("format", "table", "output format, either table or json")
+	fs.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		commandFatalf(fs, "unknown format %q", *format)
+	}
+
+	ctx, config, driver := setupCommand(fs)
+
+	policies, err := handlers.RetentionPolicies(config.Retention)
+	if err != nil {
+		commandFatalf(fs, "configuration error: %v", err)
+	}
+
+	registry := storage.NewRegistryWithDriver(ctx, driver, nil)
+	pruned, err := storage.PruneTags(ctx, registry, driver, policies, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to apply retention policies: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		if pruned == nil {
+			pruned = []storage.PrunedTag{}
+		}
+
+		p, err := json.MarshalIndent(pruned, "", "   ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode retention report: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(p))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tTAG\tUPDATED\tREASON\t")
+	for _, tag := range pruned {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", tag.Repository, tag.Tag, tag.Updated.Format(time.RFC3339), tag.Reason)
+	}
+	tw.Flush()
+
+	fmt.Println()
+	fmt.Printf("%d tags would be pruned\n", len(pruned))
+}
+
+// copyRepository copies a repository to a new name within the storage.
+func copyRepository(args []string) {
+	runRepositoryCopy("copy", storage.CopyRepository, args)
+}
+
+// renameRepository renames a repository within the storage. The registry
+// should not accept writes to the repository while this runs.
+func renameRepository(args []string) {
+	runRepositoryCopy("rename", storage.RenameRepository, args)
+}
+
+// runRepositoryCopy parses the arguments of the named command and copies the
+// repository with copy, re-signing schema1 manifests with the configured
+// key.
+func runRepositoryCopy(name string, copy func(context.Context, distribution.Namespace, storagedriver.StorageDriver, string, string, libtrust.PrivateKey) (storage.RepositoryCopy, error), args []string) {
+	fs := newCommandFlagSet(name, "<config>")
+	from := fs.String("from", "", "name of the source repository")
+	to := fs.String("to", "", "name of the destination repository, which must not exist")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" {
+		commandFatalf(fs, "both -from and -to are required")
+	}
+
+	ctx, config, driver := setupCommand(fs)
+
+	key, err := handlers.SigningKey(config)
+	if err != nil {
+		commandFatalf(fs, "unable to load schema1 signing key: %v", err)
+	}
+
+	registry := storage.NewRegistryWithDriver(ctx, driver, nil)
+	copied, err := copy(ctx, registry, driver, *from, *to, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to %s repository: %v\n", name, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s: %d layers, %d manifests and %d tags from %s to %s\n", name, copied.Layers, copied.Manifests, copied.Tags, copied.From, copied.To)
+}
+
+// listUploads lists the uploads in progress, in one repository or in all of
+// them, or cancels the upload given with -cancel.
+func listUploads(args []string) {
+	fs := newCommandFlagSet("uploads", "<config>")
+	name := fs.String("name", "", "name of the repository, all repositories if omitted")
+	cancel := fs.String("cancel", "", "id of an upload to cancel, removing the data received so far")
+	format := fs.String("format", "table", "output format, either table or json")
+	fs.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		commandFatalf(fs, "unknown format %q", *format)
+	}
+
+	if *cancel != "" && *name == "" {
+		commandFatalf(fs, "-name is required with -cancel")
+	}
+
+	ctx, _, driver := setupCommand(fs)
+
+	if *cancel != "" {
+		if err := storage.CancelUpload(ctx, driver, *name, *cancel); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to cancel upload %s in %s: %v\n", *cancel, *name, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("cancelled upload %s in %s\n", *cancel, *name)
+		return
+	}
+
+	uploads, err := storage.ListUploads(ctx, driver, *name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list uploads: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		p, err := json.MarshalIndent(uploads, "", "   ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode uploads: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(p))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tID\tSIZE\tSTARTED\tHASHSTATE\t")
+	for _, upload := range uploads {
+		started := "unknown"
+		if !upload.StartedAt.IsZero() {
+			started = upload.StartedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t\n", upload.Repository, upload.ID, upload.Size, started, upload.HashStateOffset)
+	}
+	tw.Flush()
+
+	fmt.Println()
+	fmt.Printf("%d uploads in progress\n", len(uploads))
+}
+
+// scrubBlobs verifies the data of every blob, reporting the corrupt blobs
+// and optionally moving them out of the blob store.
+func scrubBlobs(args []string) {
+	fs := newCommandFlagSet("scrub", "<config>")
+	quarantine := fs.Bool("quarantine", false, "move corrupt blobs out of the blob store, so that the registry stops serving them")
+	rateLimit := fs.Int64("rate-limit", 0, "maximum number of bytes read per second, unlimited if zero")
+	format := fs.String("format", "table", "output format, either table or json")
+	fs.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		commandFatalf(fs, "unknown format %q", *format)
+	}
+
+	if *rateLimit < 0 {
+		commandFatalf(fs, "-rate-limit must not be negative")
+	}
+
+	ctx, _, driver := setupCommand(fs)
+
+	report, err := storage.ScrubBlobs(ctx, driver, storage.ScrubOptions{
+		RateLimit:  *rateLimit,
+		Quarantine: *quarantine,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to scrub blobs: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		p, err := json.MarshalIndent(report, "", "   ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode scrub report: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(p))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIGEST\tSIZE\tREASON\tQUARANTINED\t")
+	for _, corrupt := range report.Corrupt {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%t\t\n", corrupt.Digest, corrupt.Size, corrupt.Reason, corrupt.Quarantined)
+	}
+	tw.Flush()
+
+	fmt.Println()
+	fmt.Printf("%d blobs verified, %d bytes read, %d corrupt\n", report.Blobs, report.Bytes, len(report.Corrupt))
+}
+
+// checkStorage reports the inconsistencies in the links and tags of the
+// repositories, repairing them if asked to. The registry must not accept
+// writes while repairing.
+func checkStorage(args []string) {
+	fs := newCommandFlagSet("fsck", "<config>")
+	repair := fs.Bool("repair", false, "fix the inconsistencies that are safe to fix; the registry must not accept writes meanwhile")
+	format := fs.String("format", "table", "output format, either table or json")
+	fs.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		commandFatalf(fs, "unknown format %q", *format)
+	}
+
+	ctx, _, driver := setupCommand(fs)
+
+	report, err := storage.CheckStorage(ctx, driver, *repair)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to check storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		p, err := json.MarshalIndent(report, "", "   ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to encode fsck report: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(p))
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tTAG\tKIND\tDIGEST\tREPAIRED\tPATH\t")
+	for _, problem := range report.Inconsistencies {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\t\n", problem.Repository, problem.Tag, problem.Kind, problem.Digest, problem.Repaired, problem.Path)
+	}
+	tw.Flush()
+
+	fmt.Println()
+	fmt.Printf("%d repositories, %d links and %d tags checked, %d inconsistencies found\n", report.Repositories, report.Links, report.Tags, len(report.Inconsistencies))
+}
+
+// parseCommandArgument parses the flags in args, then the positional argument
+// that the command expects before its configuration, then the flags that
+// follow it, so that flags may come on either side of the argument.
+func parseCommandArgument(fs *flag.FlagSet, args []string, what string) string {
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		commandFatalf(fs, "%s is required", what)
+	}
+
+	argument := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+
+	return argument
+}
+
+// exportRepository writes the manifests, tags and blobs of a repository, or
+// of one of its tags, to a tar archive that import restores.
+func exportRepository(args []string) {
+	fs := newCommandFlagSet("export", "<repository>[:tag] <config>")
+	output := fs.String("o", "", "path of the archive to write")
+	reference := parseCommandArgument(fs, args, "repository")
+
+	if *output == "" {
+		commandFatalf(fs, "-o is required")
+	}
+
+	name, tag := reference, ""
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		name, tag = reference[:i], reference[i+1:]
+	}
+
+	ctx, _, driver := setupCommand(fs)
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create archive: %v\n", err)
+		os.Exit(1)
+	}
+
+	registry := storage.NewRegistryWithDriver(ctx, driver, nil)
+	exported, err := storage.ExportRepository(ctx, registry, driver, name, tag, f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+
+	if err != nil {
+		os.Remove(*output)
+		fmt.Fprintf(os.Stderr, "failed to export %s: %v\n", reference, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("export: %d manifests, %d tags and %d blobs (%d bytes) from %s to %s\n", exported.Manifests, exported.Tags, exported.Blobs, exported.Bytes, reference, *output)
+}
+
+// importRepository restores a repository from an archive written by export,
+// verifying the digests of the blobs and the signatures of the manifests, as
+// well as the trust policies of the configuration.
+func importRepository(args []string) {
+	fs := newCommandFlagSet("import", "<archive> <config>")
+	input := parseCommandArgument(fs, args, "archive")
+
+	ctx, config, driver := setupCommand(fs)
+
+	policies, err := handlers.TrustPolicies(config)
+	if err != nil {
+		commandFatalf(fs, "configuration error: %v", err)
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open archive: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	registry := storage.NewRegistryWithDriver(ctx, driver, nil, storage.RequireTrustedSignatures(policies))
+	imported, err := storage.ImportRepository(ctx, registry, f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to import %s: %v\n", input, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("import: %d manifests, %d tags and %d blobs (%d bytes) from %s to %s\n", imported.Manifests, imported.Tags, imported.Blobs, imported.Bytes, input, imported.Repository)
+}
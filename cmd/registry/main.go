@@ -22,6 +22,7 @@ import (
 	_ "github.com/docker/distribution/registry/auth/token"
 	"github.com/docker/distribution/registry/handlers"
 	"github.com/docker/distribution/registry/listener"
+	_ "github.com/docker/distribution/registry/middleware/repository/immutabletags"
 	_ "github.com/docker/distribution/registry/storage/driver/azure"
 	_ "github.com/docker/distribution/registry/storage/driver/filesystem"
 	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
@@ -48,11 +49,19 @@ func main() {
 		return
 	}
 
+	// 运行子命令，而不是启动 registry
+	if flag.NArg() > 0 {
+		if cmd, ok := lookupCommand(flag.Arg(0)); ok {
+			cmd.run(flag.Args()[1:])
+			return
+		}
+	}
+
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, "version", version.Version)
 	
 	// 解析配置文件
-	config, err := resolveConfiguration()
+	config, err := resolveConfiguration(flag.Args())
 	if err != nil {
 		fatalf("configuration error: %v", err)
 	}
@@ -68,6 +77,12 @@ func main() {
 	handler = gorhandlers.CombinedLoggingHandler(os.Stdout, handler)
 
 	if config.HTTP.Debug.Addr != "" {
+		// Allow read-only mode to be toggled without a restart.
+		http.Handle("/debug/maintenance/readonly", app.ReadOnlyHandler())
+		http.Handle("/debug/storage/usage", app.UsageHandler())
+		http.Handle("/debug/repositories/copy", app.CopyRepositoryHandler())
+		http.Handle("/debug/repositories/rename", app.RenameRepositoryHandler())
+		http.Handle("/debug/uploads", app.UploadsHandler())
 		go debugServer(config.HTTP.Debug.Addr)
 	}
 	
@@ -132,7 +147,13 @@ func main() {
 
 func usage() {
 	fmt.Fprintln(os.Stderr, "usage:", os.Args[0], "<config>")
+	fmt.Fprintln(os.Stderr, "       "+os.Args[0], "<command> [options] <config>")
 	flag.PrintDefaults()
+
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.name, cmd.description)
+	}
 }
 
 func fatalf(format string, args ...interface{}) {
@@ -141,11 +162,13 @@ func fatalf(format string, args ...interface{}) {
 	os.Exit(1)
 }
 
-func resolveConfiguration() (*configuration.Configuration, error) {
+// resolveConfiguration parses the configuration file named by the first
+// argument, falling back to REGISTRY_CONFIGURATION_PATH.
+func resolveConfiguration(args []string) (*configuration.Configuration, error) {
 	var configurationPath string
 
-	if flag.NArg() > 0 {
-		configurationPath = flag.Arg(0)
+	if len(args) > 0 {
+		configurationPath = args[0]
 	} else if os.Getenv("REGISTRY_CONFIGURATION_PATH") != "" {
 		configurationPath = os.Getenv("REGISTRY_CONFIGURATION_PATH")
 	}
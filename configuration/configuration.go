@@ -95,6 +95,22 @@ type Configuration struct {
 	// registry events are dispatched.
 	Notifications Notifications `yaml:"notifications,omitempty"`
 
+	// Proxy configures the registry as a pull through cache of a remote
+	// registry.
+	Proxy Proxy `yaml:"proxy,omitempty"`
+
+	// Quotas limits the bytes stored by the repositories matching name
+	// patterns.
+	Quotas []Quota `yaml:"quotas,omitempty"`
+
+	// DigestAlgorithms selects the digest algorithm of the blobs uploaded to
+	// the repositories matching name patterns.
+	DigestAlgorithms []DigestAlgorithm `yaml:"digestalgorithms,omitempty"`
+
+	// Trust requires the manifests put in the repositories matching name
+	// patterns to be signed by trusted certificates.
+	Trust []Trust `yaml:"trust,omitempty"`
+
 	// Redis configures the redis pool available to the registry webapp.
 	Redis struct {
 		// Addr specifies the the redis instance available to the application.
@@ -124,6 +140,24 @@ type Configuration struct {
 			IdleTimeout time.Duration `yaml:"idletimeout,omitempty"`
 		} `yaml:"pool,omitempty"`
 	} `yaml:"redis,omitempty"`
+
+	// Retention configures the background pruning of tags selected by
+	// retention policies.
+	Retention Retention `yaml:"retention,omitempty"`
+
+	// Scrub configures the background verification of the blob data.
+	Scrub Scrub `yaml:"scrub,omitempty"`
+
+	// Compatibility configures the handling of older manifest formats.
+	Compatibility struct {
+		// Schema1 configures the handling of schema1 manifests.
+		Schema1 struct {
+			// SigningKeyFile is the path of the private key used to sign the
+			// schema1 manifests rewritten by the registry, such as when a
+			// repository is copied. A key is generated at startup if unset.
+			SigningKeyFile string `yaml:"signingkeyfile,omitempty"`
+		} `yaml:"schema1,omitempty"`
+	} `yaml:"compatibility,omitempty"`
 }
 
 // v0_1Configuration is a Version 0.1 Configuration struct
@@ -338,6 +372,115 @@ type Endpoint struct {
 	Backoff   time.Duration `yaml:"backoff"`   // backoff duration
 }
 
+// Proxy configures the registry as a pull through cache. When RemoteURL is
+// set, content missing from local storage is fetched from the remote
+// registry and stored locally.
+type Proxy struct {
+	// RemoteURL is the URL of the remote registry, without the /v2/ path.
+	RemoteURL string `yaml:"remoteurl"`
+
+	// Username, if set, is used to authenticate against the remote registry
+	// with basic auth.
+	Username string `yaml:"username,omitempty"`
+
+	// Password is used along with Username.
+	Password string `yaml:"password,omitempty"`
+
+	// TTL is the time for which a manifest fetched by tag is served from
+	// local storage before it is revalidated against the remote registry.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+}
+
+// Quota limits the number of bytes stored by the repositories whose names
+// match a pattern. A blob counts once towards each repository it is linked
+// into.
+type Quota struct {
+	// Repository is a pattern, in the syntax of path.Match, matched against
+	// repository names. The limit applies to the combined usage of all
+	// matching repositories.
+	Repository string `yaml:"repository"`
+
+	// Limit is the maximum number of bytes.
+	Limit int64 `yaml:"limit"`
+}
+
+// DigestAlgorithm selects the algorithm of the canonical digest of the blobs
+// uploaded to the repositories whose names match a pattern.
+type DigestAlgorithm struct {
+	// Repository is a pattern, in the syntax of path.Match, matched against
+	// repository names. Only the first matching entry applies.
+	Repository string `yaml:"repository"`
+
+	// Algorithm is one of sha256, sha384 or sha512.
+	Algorithm string `yaml:"algorithm"`
+}
+
+// Trust lists the certificate authorities trusted to sign the manifests of
+// the repositories whose names match a pattern.
+type Trust struct {
+	// Repository is a pattern, in the syntax of path.Match, matched against
+	// repository names. Only the first matching entry applies.
+	Repository string `yaml:"repository"`
+
+	// RootCertBundles are the paths of PEM encoded certificate bundles
+	// holding the trusted root certificates.
+	RootCertBundles []string `yaml:"rootcertbundles"`
+}
+
+// Retention configures the background job pruning the tags selected by the
+// retention policies.
+type Retention struct {
+	// Interval is the time between runs of the job.
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// DryRun logs the tags that would be pruned, without removing them.
+	DryRun bool `yaml:"dryrun,omitempty"`
+
+	// Policies select the tags to prune. Only the first policy matching a
+	// repository name applies to it.
+	Policies []RetentionPolicy `yaml:"policies,omitempty"`
+}
+
+// RetentionPolicy selects the tags to prune from the repositories whose names
+// match a pattern.
+type RetentionPolicy struct {
+	// Repository is a pattern, in the syntax of path.Match, matched against
+	// repository names.
+	Repository string `yaml:"repository"`
+
+	// Tags is a regular expression selecting the tags subject to the
+	// policy. All tags are subject to it if empty.
+	Tags string `yaml:"tags,omitempty"`
+
+	// KeepLast is the number of most recently updated tags to keep. Zero
+	// keeps all of them.
+	KeepLast int `yaml:"keeplast,omitempty"`
+
+	// MaxAge prunes tags not updated within the duration. Zero disables
+	// pruning by age.
+	MaxAge time.Duration `yaml:"maxage,omitempty"`
+
+	// Protect lists regular expressions of tags that are never pruned.
+	Protect []string `yaml:"protect,omitempty"`
+}
+
+// Scrub configures the background job verifying that the data of each blob
+// hashes to its digest.
+type Scrub struct {
+	// Enabled starts the job with the registry.
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// Interval is the time between runs of the job.
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// RateLimit is the maximum number of bytes read per second. Zero reads
+	// as fast as the storage allows.
+	RateLimit int64 `yaml:"ratelimit,omitempty"`
+
+	// Quarantine moves corrupt blobs out of the blob store.
+	Quarantine bool `yaml:"quarantine,omitempty"`
+}
+
 // Reporting defines error reporting methods.
 type Reporting struct {
 	// Bugsnag configures error reporting for Bugsnag (bugsnag.com).
@@ -130,10 +130,7 @@ func (d Digest) Validate() error {
 		return ErrDigestInvalidFormat
 	}
 
-	switch s[:i] {
-	case "sha256", "sha384", "sha512":
-		break
-	default:
+	if !AlgorithmAvailable(s[:i]) {
 		return ErrDigestUnsupported
 	}
 
@@ -2,9 +2,29 @@ package digest
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
 	"hash"
 )
 
+// CanonicalAlgorithm is the digest algorithm used by default, such as for
+// the content addresses of manifests.
+const CanonicalAlgorithm = "sha256"
+
+// hashAlgorithms maps the supported hash algorithms to their hash
+// constructors.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// AlgorithmAvailable returns true if alg names a supported hash algorithm.
+// Tarsum is not a hash algorithm and is not reported as available.
+func AlgorithmAvailable(alg string) bool {
+	_, ok := hashAlgorithms[alg]
+	return ok
+}
+
 // Digester calculates the digest of written data. It is functionally
 // equivalent to hash.Hash but provides methods for returning the Digest type
 // rather than raw bytes.
@@ -22,10 +42,21 @@ func NewDigester(alg string, h hash.Hash) Digester {
 	}
 }
 
+// NewDigesterForAlgorithm returns a Digester for the named hash algorithm,
+// one of sha256, sha384 or sha512.
+func NewDigesterForAlgorithm(alg string) (Digester, error) {
+	newHash, ok := hashAlgorithms[alg]
+	if !ok {
+		return Digester{}, ErrDigestUnsupported
+	}
+
+	return NewDigester(alg, newHash()), nil
+}
+
 // NewCanonicalDigester is a convenience function to create a new Digester with
 // our default settings.
 func NewCanonicalDigester() Digester {
-	return NewDigester("sha256", sha256.New())
+	return NewDigester(CanonicalAlgorithm, sha256.New())
 }
 
 // Digest returns the current digest for this digester.
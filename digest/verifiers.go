@@ -1,8 +1,6 @@
 package digest
 
 import (
-	"crypto/sha256"
-	"crypto/sha512"
 	"hash"
 	"io"
 	"io/ioutil"
@@ -30,8 +28,8 @@ func NewDigestVerifier(d Digest) (Verifier, error) {
 	}
 
 	alg := d.Algorithm()
-	switch alg {
-	case "sha256", "sha384", "sha512":
+	switch {
+	case AlgorithmAvailable(alg):
 		return hashVerifier{
 			hash:   newHash(alg),
 			digest: d,
@@ -96,16 +94,12 @@ func (lv *lengthVerifier) Verified() bool {
 }
 
 func newHash(name string) hash.Hash {
-	switch name {
-	case "sha256":
-		return sha256.New()
-	case "sha384":
-		return sha512.New384()
-	case "sha512":
-		return sha512.New()
-	default:
+	newHash, ok := hashAlgorithms[name]
+	if !ok {
 		panic("unsupport algorithm: " + name)
 	}
+
+	return newHash()
 }
 
 type hashVerifier struct {
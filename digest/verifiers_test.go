@@ -69,6 +69,46 @@ func TestDigestVerifier(t *testing.T) {
 	}
 }
 
+// TestDigestVerifierAlgorithms checks that content digested with each of the
+// supported hash algorithms is verified.
+func TestDigestVerifierAlgorithms(t *testing.T) {
+	p := make([]byte, 1<<16)
+	rand.Read(p)
+
+	for _, alg := range []string{"sha256", "sha384", "sha512"} {
+		digester, err := NewDigesterForAlgorithm(alg)
+		if err != nil {
+			t.Fatalf("unexpected error creating %s digester: %v", alg, err)
+		}
+
+		digester.Write(p)
+		digest := digester.Digest()
+
+		if digest.Algorithm() != alg {
+			t.Fatalf("unexpected algorithm: %q != %q", digest.Algorithm(), alg)
+		}
+
+		if err := digest.Validate(); err != nil {
+			t.Fatalf("unexpected error validating %s digest: %v", alg, err)
+		}
+
+		verifier, err := NewDigestVerifier(digest)
+		if err != nil {
+			t.Fatalf("unexpected error getting digest verifier: %s", err)
+		}
+
+		io.Copy(verifier, bytes.NewReader(p))
+
+		if !verifier.Verified() {
+			t.Fatalf("bytes not verified with %s", alg)
+		}
+	}
+
+	if _, err := NewDigesterForAlgorithm("md5"); err != ErrDigestUnsupported {
+		t.Fatalf("expected unsupported digest error, got %v", err)
+	}
+}
+
 // TestVerifierUnsupportedDigest ensures that unsupported digest validation is
 // flowing through verifier creation.
 func TestVerifierUnsupportedDigest(t *testing.T) {
@@ -1,12 +1,17 @@
 package distribution
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/docker/distribution/digest"
 )
 
+// ErrUnsupported is returned when an operation is not supported by the
+// service, such as a write to a read-only pull through cache.
+var ErrUnsupported = errors.New("operation unsupported")
+
 // ErrRepositoryUnknown is returned if the named repository is not known by
 // the registry.
 type ErrRepositoryUnknown struct {
@@ -17,6 +22,16 @@ func (err ErrRepositoryUnknown) Error() string {
 	return fmt.Sprintf("unknown respository name=%s", err.Name)
 }
 
+// ErrRepositoryExists is returned when content would be copied into a
+// repository that already exists.
+type ErrRepositoryExists struct {
+	Name string
+}
+
+func (err ErrRepositoryExists) Error() string {
+	return fmt.Sprintf("repository name=%s already exists", err.Name)
+}
+
 // ErrRepositoryNameInvalid should be used to denote an invalid repository
 // name. Reason may set, indicating the cause of invalidity.
 type ErrRepositoryNameInvalid struct {
@@ -58,6 +73,16 @@ func (ErrManifestUnverified) Error() string {
 	return fmt.Sprintf("unverified manifest")
 }
 
+// ErrManifestUntrusted is returned when a repository requires trusted
+// signatures and the manifest is not signed by a trusted certificate.
+type ErrManifestUntrusted struct {
+	Reason string
+}
+
+func (err ErrManifestUntrusted) Error() string {
+	return fmt.Sprintf("untrusted manifest: %s", err.Reason)
+}
+
 // ErrManifestVerification provides a type to collect errors encountered
 // during manifest verification. Currently, it accepts errors of all types,
 // but it may be narrowed to those involving manifest verification.
@@ -80,3 +105,40 @@ type ErrManifestBlobUnknown struct {
 func (err ErrManifestBlobUnknown) Error() string {
 	return fmt.Sprintf("unknown blob %v on manifest", err.Digest)
 }
+
+// ErrManifestMediaTypeUnknown is returned when a manifest is provided with a
+// media type that does not correspond to a registered manifest format.
+type ErrManifestMediaTypeUnknown struct {
+	MediaType string
+}
+
+func (err ErrManifestMediaTypeUnknown) Error() string {
+	return fmt.Sprintf("unknown manifest media type %q", err.MediaType)
+}
+
+// ErrQuotaExceeded is returned when a write to the named repository would
+// take the repositories matching a quota pattern over the quota limit.
+type ErrQuotaExceeded struct {
+	Name    string
+	Pattern string
+	Limit   int64
+	Used    int64
+	Size    int64
+}
+
+func (err ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("quota exceeded for repository %s: %d bytes used of %d allowed for %q, cannot add %d bytes",
+		err.Name, err.Used, err.Limit, err.Pattern, err.Size)
+}
+
+// ErrTagImmutable is returned when a write would move or remove a tag that
+// is protected from changes once pushed.
+type ErrTagImmutable struct {
+	Name     string
+	Tag      string
+	Revision digest.Digest
+}
+
+func (err ErrTagImmutable) Error() string {
+	return fmt.Sprintf("tag %s in repository %s is immutable and references revision %s", err.Tag, err.Name, err.Revision)
+}
@@ -2,7 +2,9 @@ package manifest
 
 import (
 	"encoding/json"
+	"strings"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/libtrust"
 )
@@ -15,8 +17,32 @@ const (
 	// that for schema version 1, the the media is optionally
 	// "application/json".
 	ManifestMediaType = "application/vnd.docker.distribution.manifest.v1+json"
+
+	// ManifestSignedMediaType is the mediaType of a signed schema version 1
+	// manifest, as defined in the schema1 specification.
+	ManifestSignedMediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
 )
 
+func init() {
+	unmarshal := func(b []byte) (distribution.Manifest, error) {
+		sm := new(SignedManifest)
+		if err := json.Unmarshal(b, sm); err != nil {
+			return nil, err
+		}
+
+		return sm, nil
+	}
+
+	// Clients predating schema2 send the manifest without a content type or
+	// as plain json. Registering the empty media type also makes schema1 the
+	// format for content types that are not registered.
+	for _, mediaType := range []string{ManifestMediaType, ManifestSignedMediaType, "application/json", ""} {
+		if err := distribution.RegisterManifestSchema(mediaType, unmarshal); err != nil {
+			panic(err)
+		}
+	}
+}
+
 // Versioned provides a struct with just the manifest schemaVersion. Incoming
 // content with unknown schema version can be decoded against this struct to
 // check the version.
@@ -111,6 +137,50 @@ func (sm *SignedManifest) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&sm.Manifest)
 }
 
+// Descriptor returns the descriptor of the manifest payload, which excludes
+// the signatures. The payload is the content addressable form of a schema1
+// manifest.
+func (sm *SignedManifest) Descriptor() (distribution.Descriptor, error) {
+	p, err := sm.Payload()
+	if err != nil {
+		if !strings.Contains(err.Error(), "missing signature key") {
+			return distribution.Descriptor{}, err
+		}
+
+		// NOTE(stevvooe): There are no signatures but we still have a
+		// payload. Verification will fail later but this is not the
+		// responsibility of this part of the code.
+		p = sm.Raw
+	}
+
+	dgst, err := digest.FromBytes(p)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	return distribution.Descriptor{
+		MediaType: ManifestMediaType,
+		Length:    int64(len(p)),
+		Digest:    dgst,
+	}, nil
+}
+
+// Content returns the signed manifest, as served to clients.
+func (sm *SignedManifest) Content() []byte {
+	return sm.Raw
+}
+
+// References returns the filesystem layers of the manifest. Schema1 does not
+// record layer lengths, so only the digests are populated.
+func (sm *SignedManifest) References() []distribution.Descriptor {
+	references := make([]distribution.Descriptor, len(sm.FSLayers))
+	for i, fsLayer := range sm.FSLayers {
+		references[i] = distribution.Descriptor{Digest: fsLayer.BlobSum}
+	}
+
+	return references
+}
+
 // FSLayer is a container struct for BlobSums defined in an image manifest
 type FSLayer struct {
 	// BlobSum is the tarsum of the referenced filesystem image layer
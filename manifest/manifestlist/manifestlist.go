@@ -0,0 +1,166 @@
+package manifestlist
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+// MediaTypeManifestList specifies the mediaType for manifest lists.
+const MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+// SchemaVersion is the manifest list schema implemented by this package.
+const SchemaVersion = 2
+
+func init() {
+	unmarshal := func(b []byte) (distribution.Manifest, error) {
+		dml := new(DeserializedManifestList)
+		if err := dml.UnmarshalJSON(b); err != nil {
+			return nil, err
+		}
+
+		return dml, nil
+	}
+
+	if err := distribution.RegisterManifestSchema(MediaTypeManifestList, unmarshal); err != nil {
+		panic(err)
+	}
+}
+
+// PlatformSpec describes the platform that an image runs on.
+type PlatformSpec struct {
+	// Architecture is the CPU architecture, such as amd64 or ppc64le.
+	Architecture string `json:"architecture"`
+
+	// OS is the operating system, such as linux.
+	OS string `json:"os"`
+
+	// Variant is an optional variant of the CPU, such as v7 for arm.
+	Variant string `json:"variant,omitempty"`
+}
+
+// ManifestDescriptor references a platform specific manifest.
+type ManifestDescriptor struct {
+	schema2.Descriptor
+
+	// Platform describes the platform the referenced manifest is built for.
+	Platform PlatformSpec `json:"platform"`
+}
+
+// ManifestList references manifests for different platforms, allowing a
+// single tag to serve an image built for several of them.
+// 多平台 manifest 列表，按 digest 引用各平台的 manifest
+type ManifestList struct {
+	// SchemaVersion is the manifest list schema that this list follows.
+	SchemaVersion int `json:"schemaVersion"`
+
+	// MediaType is the media type of this manifest list.
+	MediaType string `json:"mediaType"`
+
+	// Manifests references the platform specific manifests.
+	Manifests []ManifestDescriptor `json:"manifests"`
+}
+
+// References returns the descriptors of the referenced manifests.
+func (ml ManifestList) References() []distribution.Descriptor {
+	references := make([]distribution.Descriptor, len(ml.Manifests))
+	for i, m := range ml.Manifests {
+		references[i] = m.BlobDescriptor()
+	}
+
+	return references
+}
+
+// DeserializedManifestList wraps ManifestList with the exact bytes it was
+// decoded from, which the digest is calculated over.
+type DeserializedManifestList struct {
+	ManifestList
+
+	// canonical is the serialized form of the manifest list.
+	canonical []byte
+}
+
+var _ distribution.Manifest = &DeserializedManifestList{}
+
+// FromDescriptors creates a serialized manifest list referencing the provided
+// manifests.
+func FromDescriptors(descriptors []ManifestDescriptor) (*DeserializedManifestList, error) {
+	ml := ManifestList{
+		SchemaVersion: SchemaVersion,
+		MediaType:     MediaTypeManifestList,
+		Manifests:     descriptors,
+	}
+
+	canonical, err := json.MarshalIndent(&ml, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+
+	var dml DeserializedManifestList
+	if err := dml.UnmarshalJSON(canonical); err != nil {
+		return nil, err
+	}
+
+	return &dml, nil
+}
+
+// UnmarshalJSON populates the manifest list from b, keeping a copy of b as
+// the canonical form.
+func (m *DeserializedManifestList) UnmarshalJSON(b []byte) error {
+	var ml ManifestList
+	if err := json.Unmarshal(b, &ml); err != nil {
+		return err
+	}
+
+	if ml.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("unsupported schema version %d for manifest list", ml.SchemaVersion)
+	}
+
+	if ml.MediaType != MediaTypeManifestList {
+		return fmt.Errorf("mediaType in manifest list should be %q not %q", MediaTypeManifestList, ml.MediaType)
+	}
+
+	for _, md := range ml.Manifests {
+		if err := md.Digest.Validate(); err != nil {
+			return fmt.Errorf("invalid manifest digest %q in manifest list: %v", md.Digest, err)
+		}
+	}
+
+	m.ManifestList = ml
+	m.canonical = make([]byte, len(b), len(b))
+	copy(m.canonical, b)
+
+	return nil
+}
+
+// MarshalJSON returns the canonical bytes. If they are not available, the
+// inner manifest list is marshaled.
+func (m *DeserializedManifestList) MarshalJSON() ([]byte, error) {
+	if len(m.canonical) > 0 {
+		return m.canonical, nil
+	}
+
+	return json.Marshal(&m.ManifestList)
+}
+
+// Descriptor returns the descriptor of the canonical bytes.
+func (m *DeserializedManifestList) Descriptor() (distribution.Descriptor, error) {
+	dgst, err := digest.FromBytes(m.canonical)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	return distribution.Descriptor{
+		MediaType: MediaTypeManifestList,
+		Length:    int64(len(m.canonical)),
+		Digest:    dgst,
+	}, nil
+}
+
+// Content returns the canonical bytes of the manifest list.
+func (m *DeserializedManifestList) Content() []byte {
+	return m.canonical
+}
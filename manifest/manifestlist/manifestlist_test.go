@@ -0,0 +1,107 @@
+package manifestlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+func makeTestManifestList(t *testing.T) *DeserializedManifestList {
+	dml, err := FromDescriptors([]ManifestDescriptor{
+		{
+			Descriptor: schema2.Descriptor{
+				MediaType: "application/vnd.docker.distribution.manifest.v2+json",
+				Size:      985,
+				Digest:    "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+			},
+			Platform: PlatformSpec{
+				Architecture: "amd64",
+				OS:           "linux",
+			},
+		},
+		{
+			Descriptor: schema2.Descriptor{
+				MediaType: "application/vnd.docker.distribution.manifest.v2+json",
+				Size:      2392,
+				Digest:    "sha256:6346340964309634683409684360934680934608934608934608934068934608",
+			},
+			Platform: PlatformSpec{
+				Architecture: "arm64",
+				OS:           "linux",
+				Variant:      "v8",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating manifest list: %v", err)
+	}
+
+	return dml
+}
+
+func TestManifestListMarshaling(t *testing.T) {
+	dml := makeTestManifestList(t)
+
+	p, err := dml.MarshalJSON()
+	if err != nil {
+		t.Fatalf("error marshaling manifest list: %v", err)
+	}
+
+	if !bytes.Equal(p, dml.Content()) {
+		t.Fatalf("manifest list bytes not equal: %q != %q", string(dml.Content()), string(p))
+	}
+
+	desc, err := dml.Descriptor()
+	if err != nil {
+		t.Fatalf("error getting descriptor: %v", err)
+	}
+
+	if desc.MediaType != MediaTypeManifestList || desc.Length != int64(len(p)) {
+		t.Fatalf("unexpected descriptor: %#v", desc)
+	}
+
+	references := dml.References()
+	if len(references) != 2 {
+		t.Fatalf("unexpected number of references: %d", len(references))
+	}
+
+	if references[1].Length != 2392 || !bytes.Contains(p, []byte(`"size": 2392`)) {
+		t.Fatalf("unexpected size of referenced manifest: %#v", references[1])
+	}
+
+	for i, reference := range references {
+		if reference != dml.Manifests[i].BlobDescriptor() {
+			t.Fatalf("unexpected reference: %#v != %#v", reference, dml.Manifests[i].Descriptor)
+		}
+	}
+}
+
+func TestManifestListUnmarshaling(t *testing.T) {
+	dml := makeTestManifestList(t)
+
+	m, err := distribution.UnmarshalManifest(MediaTypeManifestList, dml.Content())
+	if err != nil {
+		t.Fatalf("error unmarshaling manifest list: %v", err)
+	}
+
+	if !reflect.DeepEqual(m, dml) {
+		t.Fatalf("manifest lists are different after unmarshaling: %v != %v", m, dml)
+	}
+
+	invalid := dml.ManifestList
+	invalid.Manifests = []ManifestDescriptor{dml.Manifests[0]}
+	invalid.Manifests[0].Digest = "sha256:nothex"
+
+	p, err := json.Marshal(&invalid)
+	if err != nil {
+		t.Fatalf("error marshaling manifest list: %v", err)
+	}
+
+	if _, err := distribution.UnmarshalManifest(MediaTypeManifestList, p); err == nil {
+		t.Fatalf("expected error unmarshaling manifest list with invalid digest")
+	}
+}
@@ -0,0 +1,185 @@
+package schema2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+)
+
+const (
+	// MediaTypeManifest specifies the mediaType for the current version.
+	MediaTypeManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// MediaTypeConfig specifies the mediaType for the image configuration.
+	MediaTypeConfig = "application/vnd.docker.container.image.v1+json"
+
+	// MediaTypeLayer is the mediaType used for layers referenced by the
+	// manifest.
+	MediaTypeLayer = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// SchemaVersion is the image manifest schema implemented by this package.
+const SchemaVersion = 2
+
+func init() {
+	unmarshal := func(b []byte) (distribution.Manifest, error) {
+		dm := new(DeserializedManifest)
+		if err := dm.UnmarshalJSON(b); err != nil {
+			return nil, err
+		}
+
+		return dm, nil
+	}
+
+	if err := distribution.RegisterManifestSchema(MediaTypeManifest, unmarshal); err != nil {
+		panic(err)
+	}
+}
+
+// Descriptor references a blob from a schema2 manifest or a manifest list.
+// The format carries the size of the blob in the size field, unlike
+// distribution.Descriptor.
+type Descriptor struct {
+	// MediaType is the media type of the referenced content.
+	MediaType string `json:"mediaType,omitempty"`
+
+	// Size is the length in bytes of the referenced content.
+	Size int64 `json:"size"`
+
+	// Digest identifies the referenced content.
+	Digest digest.Digest `json:"digest"`
+}
+
+// NewDescriptor returns the schema2 descriptor of the blob described by desc.
+func NewDescriptor(desc distribution.Descriptor) Descriptor {
+	return Descriptor{
+		MediaType: desc.MediaType,
+		Size:      desc.Length,
+		Digest:    desc.Digest,
+	}
+}
+
+// BlobDescriptor returns the descriptor of the referenced blob, with its
+// length set to the size.
+func (d Descriptor) BlobDescriptor() distribution.Descriptor {
+	return distribution.Descriptor{
+		MediaType: d.MediaType,
+		Length:    d.Size,
+		Digest:    d.Digest,
+	}
+}
+
+// Manifest defines a schema2 manifest. Unlike schema1, the manifest is not
+// signed: it is identified by the digest of its serialized content.
+// schema2 manifest 不带签名，以内容的 digest 寻址
+type Manifest struct {
+	// SchemaVersion is the image manifest schema that this image follows.
+	SchemaVersion int `json:"schemaVersion"`
+
+	// MediaType is the media type of this manifest.
+	MediaType string `json:"mediaType"`
+
+	// Config references the image configuration as a blob.
+	Config Descriptor `json:"config"`
+
+	// Layers lists the descriptors of the layers referenced by the manifest,
+	// base layer first.
+	Layers []Descriptor `json:"layers"`
+}
+
+// References returns the descriptors of the configuration and the layers.
+func (m Manifest) References() []distribution.Descriptor {
+	references := make([]distribution.Descriptor, 0, 1+len(m.Layers))
+	references = append(references, m.Config.BlobDescriptor())
+	for _, layer := range m.Layers {
+		references = append(references, layer.BlobDescriptor())
+	}
+	return references
+}
+
+// DeserializedManifest wraps Manifest with the exact bytes it was decoded
+// from. The bytes are what the digest is calculated over, so they must be
+// stored and served unchanged.
+type DeserializedManifest struct {
+	Manifest
+
+	// canonical is the serialized form of the manifest.
+	canonical []byte
+}
+
+var _ distribution.Manifest = &DeserializedManifest{}
+
+// FromStruct serializes the manifest, returning a DeserializedManifest
+// holding the canonical bytes.
+func FromStruct(m Manifest) (*DeserializedManifest, error) {
+	canonical, err := json.MarshalIndent(&m, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+
+	var dm DeserializedManifest
+	if err := dm.UnmarshalJSON(canonical); err != nil {
+		return nil, err
+	}
+
+	return &dm, nil
+}
+
+// UnmarshalJSON populates the manifest from b, keeping a copy of b as the
+// canonical form.
+func (m *DeserializedManifest) UnmarshalJSON(b []byte) error {
+	var mfst Manifest
+	if err := json.Unmarshal(b, &mfst); err != nil {
+		return err
+	}
+
+	if mfst.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("unsupported schema version %d for schema2 manifest", mfst.SchemaVersion)
+	}
+
+	if mfst.MediaType != MediaTypeManifest {
+		return fmt.Errorf("mediaType in manifest should be %q not %q", MediaTypeManifest, mfst.MediaType)
+	}
+
+	if mfst.Config.Digest == "" {
+		return errors.New("schema2 manifest is missing the config descriptor")
+	}
+
+	m.Manifest = mfst
+	m.canonical = make([]byte, len(b), len(b))
+	copy(m.canonical, b)
+
+	return nil
+}
+
+// MarshalJSON returns the canonical bytes. If they are not available, the
+// inner manifest is marshaled.
+func (m *DeserializedManifest) MarshalJSON() ([]byte, error) {
+	if len(m.canonical) > 0 {
+		return m.canonical, nil
+	}
+
+	return json.Marshal(&m.Manifest)
+}
+
+// Descriptor returns the descriptor of the canonical bytes.
+func (m *DeserializedManifest) Descriptor() (distribution.Descriptor, error) {
+	dgst, err := digest.FromBytes(m.canonical)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	return distribution.Descriptor{
+		MediaType: MediaTypeManifest,
+		Length:    int64(len(m.canonical)),
+		Digest:    dgst,
+	}, nil
+}
+
+// Content returns the canonical bytes of the manifest.
+func (m *DeserializedManifest) Content() []byte {
+	return m.canonical
+}
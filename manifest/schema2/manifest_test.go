@@ -0,0 +1,152 @@
+package schema2
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+)
+
+func makeTestManifest(t *testing.T) *DeserializedManifest {
+	dm, err := FromStruct(Manifest{
+		SchemaVersion: SchemaVersion,
+		MediaType:     MediaTypeManifest,
+		Config: Descriptor{
+			MediaType: MediaTypeConfig,
+			Size:      985,
+			Digest:    "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+		},
+		Layers: []Descriptor{
+			{
+				MediaType: MediaTypeLayer,
+				Size:      153263,
+				Digest:    "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating manifest: %v", err)
+	}
+
+	return dm
+}
+
+func TestManifestMarshaling(t *testing.T) {
+	dm := makeTestManifest(t)
+
+	// The marshaled form must be the canonical bytes, which the digest is
+	// calculated over. Note that json.Marshal would compact the output.
+	p, err := dm.MarshalJSON()
+	if err != nil {
+		t.Fatalf("error marshaling manifest: %v", err)
+	}
+
+	if !bytes.Equal(p, dm.Content()) {
+		t.Fatalf("manifest bytes not equal: %q != %q", string(dm.Content()), string(p))
+	}
+
+	desc, err := dm.Descriptor()
+	if err != nil {
+		t.Fatalf("error getting descriptor: %v", err)
+	}
+
+	expected, err := digest.FromBytes(p)
+	if err != nil {
+		t.Fatalf("error digesting manifest: %v", err)
+	}
+
+	if desc.Digest != expected || desc.Length != int64(len(p)) || desc.MediaType != MediaTypeManifest {
+		t.Fatalf("unexpected descriptor: %#v", desc)
+	}
+
+	references := dm.References()
+	if len(references) != 2 || references[0] != dm.Config.BlobDescriptor() || references[1] != dm.Layers[0].BlobDescriptor() {
+		t.Fatalf("unexpected references: %#v", references)
+	}
+
+	if references[0].Length != 985 || references[1].Length != 153263 {
+		t.Fatalf("unexpected reference lengths: %#v", references)
+	}
+
+	// Sizes are serialized in the size field of the format.
+	if !bytes.Contains(p, []byte(`"size": 153263`)) {
+		t.Fatalf("layer size missing from manifest: %s", string(p))
+	}
+}
+
+// TestManifestSizes ensures that the sizes of a manifest produced by other
+// implementations are read.
+func TestManifestSizes(t *testing.T) {
+	p := []byte(`{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/vnd.docker.container.image.v1+json",
+      "size": 1470,
+      "digest": "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+         "size": 2789669,
+         "digest": "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b"
+      }
+   ]
+}`)
+
+	m, err := distribution.UnmarshalManifest(MediaTypeManifest, p)
+	if err != nil {
+		t.Fatalf("error unmarshaling manifest: %v", err)
+	}
+
+	references := m.References()
+	if len(references) != 2 || references[0].Length != 1470 || references[1].Length != 2789669 {
+		t.Fatalf("unexpected references: %#v", references)
+	}
+}
+
+func TestManifestUnmarshaling(t *testing.T) {
+	dm := makeTestManifest(t)
+
+	m, err := distribution.UnmarshalManifest(MediaTypeManifest+"; charset=utf-8", dm.Content())
+	if err != nil {
+		t.Fatalf("error unmarshaling manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(m, dm) {
+		t.Fatalf("manifests are different after unmarshaling: %v != %v", m, dm)
+	}
+
+	for _, testcase := range []struct {
+		description string
+		mutate      func(m *Manifest)
+	}{
+		{
+			description: "wrong schema version",
+			mutate:      func(m *Manifest) { m.SchemaVersion = 1 },
+		},
+		{
+			description: "wrong media type",
+			mutate:      func(m *Manifest) { m.MediaType = "application/json" },
+		},
+		{
+			description: "missing config",
+			mutate:      func(m *Manifest) { m.Config = Descriptor{} },
+		},
+	} {
+		invalid := dm.Manifest
+		testcase.mutate(&invalid)
+
+		p, err := json.Marshal(&invalid)
+		if err != nil {
+			t.Fatalf("error marshaling manifest: %v", err)
+		}
+
+		if _, err := distribution.UnmarshalManifest(MediaTypeManifest, p); err == nil {
+			t.Fatalf("expected error unmarshaling manifest with %s", testcase.description)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package distribution
+
+import (
+	"fmt"
+	"mime"
+	"sync"
+)
+
+// Manifest represents a registry object describing a set of content, such as
+// the layers of an image. Each manifest format, identified by its media type,
+// provides an implementation.
+// 各种格式的 manifest 都实现此接口
+type Manifest interface {
+	// Descriptor returns the media type of the manifest, along with the
+	// length and digest of its content addressable form.
+	Descriptor() (Descriptor, error)
+
+	// Content returns the serialized manifest, as stored by the registry and
+	// served to clients.
+	Content() []byte
+
+	// References returns the descriptors of the content referenced by the
+	// manifest. The registry ensures that referenced content is present
+	// before accepting the manifest.
+	References() []Descriptor
+}
+
+// UnmarshalFunc decodes the serialized form of a manifest format.
+type UnmarshalFunc func(p []byte) (Manifest, error)
+
+var (
+	manifestSchemasMu sync.RWMutex
+	manifestSchemas   = make(map[string]UnmarshalFunc)
+)
+
+// RegisterManifestSchema registers the unmarshal function for manifests of
+// the given media type. Manifest formats should call this from an init
+// function. Registering a media type twice is an error. The function
+// registered for the empty media type also decodes manifests whose media type
+// is not registered.
+func RegisterManifestSchema(mediaType string, u UnmarshalFunc) error {
+	manifestSchemasMu.Lock()
+	defer manifestSchemasMu.Unlock()
+
+	if _, ok := manifestSchemas[mediaType]; ok {
+		return fmt.Errorf("manifest media type registration would overwrite existing: %q", mediaType)
+	}
+
+	manifestSchemas[mediaType] = u
+	return nil
+}
+
+// UnmarshalManifest decodes p using the format registered for the media type
+// contained in contentType, which may carry parameters such as a charset.
+// 根据 Content-Type 解析 manifest
+func UnmarshalManifest(contentType string, p []byte) (Manifest, error) {
+	mediaType := contentType
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manifestSchemasMu.RLock()
+	unmarshal, ok := manifestSchemas[mediaType]
+	if !ok {
+		// Older clients send whatever content type their tooling picks,
+		// so fall back to the default format.
+		unmarshal, ok = manifestSchemas[""]
+	}
+	manifestSchemasMu.RUnlock()
+
+	if !ok {
+		return nil, ErrManifestMediaTypeUnknown{MediaType: mediaType}
+	}
+
+	return unmarshal(p)
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
-	"github.com/docker/distribution/manifest"
 )
 
 type bridge struct {
@@ -25,6 +24,7 @@ var _ Listener = &bridge{}
 type URLBuilder interface {
 	BuildManifestURL(name, tag string) (string, error)
 	BuildBlobURL(name string, dgst digest.Digest) (string, error)
+	BuildSignaturesURL(name string, dgst digest.Digest) (string, error)
 }
 
 // NewBridge returns a notification listener that writes records to sink,
@@ -53,16 +53,16 @@ func NewRequestRecord(id string, r *http.Request) RequestRecord {
 	}
 }
 
-func (b *bridge) ManifestPushed(repo distribution.Repository, sm *manifest.SignedManifest) error {
-	return b.createManifestEventAndWrite(EventActionPush, repo, sm)
+func (b *bridge) ManifestPushed(repo distribution.Repository, m distribution.Manifest) error {
+	return b.createManifestEventAndWrite(EventActionPush, repo, m)
 }
 
-func (b *bridge) ManifestPulled(repo distribution.Repository, sm *manifest.SignedManifest) error {
-	return b.createManifestEventAndWrite(EventActionPull, repo, sm)
+func (b *bridge) ManifestPulled(repo distribution.Repository, m distribution.Manifest) error {
+	return b.createManifestEventAndWrite(EventActionPull, repo, m)
 }
 
-func (b *bridge) ManifestDeleted(repo distribution.Repository, sm *manifest.SignedManifest) error {
-	return b.createManifestEventAndWrite(EventActionDelete, repo, sm)
+func (b *bridge) ManifestDeleted(repo distribution.Repository, m distribution.Manifest) error {
+	return b.createManifestEventAndWrite(EventActionDelete, repo, m)
 }
 
 func (b *bridge) BlobPushed(repo distribution.Repository, desc distribution.Descriptor) error {
@@ -77,35 +77,63 @@ func (b *bridge) BlobDeleted(repo distribution.Repository, desc distribution.Des
 	return b.createBlobEventAndWrite(EventActionDelete, repo, desc)
 }
 
-func (b *bridge) createManifestEventAndWrite(action string, repo distribution.Repository, sm *manifest.SignedManifest) error {
-	manifestEvent, err := b.createManifestEvent(action, repo, sm)
+func (b *bridge) TagDeleted(repo distribution.Repository, tag string, m distribution.Manifest) error {
+	event, err := b.createManifestEvent(EventActionUntag, repo, m)
 	if err != nil {
 		return err
 	}
 
-	return b.sink.Write(*manifestEvent)
+	// The URL references the revision the tag pointed at, since the tag no
+	// longer resolves.
+	event.Target.Tag = tag
+	return b.sink.Write(*event)
 }
 
-func (b *bridge) createManifestEvent(action string, repo distribution.Repository, sm *manifest.SignedManifest) (*Event, error) {
-	event := b.createEvent(action)
-	event.Target.MediaType = manifest.ManifestMediaType
-	event.Target.Repository = repo.Name()
+func (b *bridge) TagBlocked(repo distribution.Repository, tag string, m distribution.Manifest) error {
+	event, err := b.createManifestEvent(EventActionBlocked, repo, m)
+	if err != nil {
+		return err
+	}
+
+	event.Target.Tag = tag
 
-	p, err := sm.Payload()
+	// The manifest was refused, so there is no content to link to.
+	event.Target.URL = ""
+
+	return b.sink.Write(*event)
+}
+
+func (b *bridge) SignaturesPushed(repo distribution.Repository, dgst digest.Digest, signatures [][]byte) error {
+	return b.createSignaturesEventAndWrite(EventActionPush, repo, dgst, signatures)
+}
+
+func (b *bridge) SignaturesPulled(repo distribution.Repository, dgst digest.Digest, signatures [][]byte) error {
+	return b.createSignaturesEventAndWrite(EventActionPull, repo, dgst, signatures)
+}
+
+func (b *bridge) createManifestEventAndWrite(action string, repo distribution.Repository, m distribution.Manifest) error {
+	manifestEvent, err := b.createManifestEvent(action, repo, m)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	event.Target.Length = int64(len(p))
+	return b.sink.Write(*manifestEvent)
+}
+
+func (b *bridge) createManifestEvent(action string, repo distribution.Repository, m distribution.Manifest) (*Event, error) {
+	event := b.createEvent(action)
+	event.Target.Repository = repo.Name()
 
-	event.Target.Digest, err = digest.FromBytes(p)
+	desc, err := m.Descriptor()
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO(stevvooe): Currently, the is the "tag" url: once the digest url is
-	// implemented, this should be replaced.
-	event.Target.URL, err = b.ub.BuildManifestURL(sm.Name, sm.Tag)
+	event.Target.Descriptor = desc
+
+	// Not every manifest format carries a tag, so the event references the
+	// manifest by digest.
+	event.Target.URL, err = b.ub.BuildManifestURL(repo.Name(), desc.Digest.String())
 	if err != nil {
 		return nil, err
 	}
@@ -136,6 +164,28 @@ func (b *bridge) createBlobEvent(action string, repo distribution.Repository, de
 	return event, nil
 }
 
+// createSignaturesEventAndWrite writes an event targeting the signatures of
+// the manifest revision identified by dgst. The target carries the digest of
+// the revision and the combined length of the signatures.
+func (b *bridge) createSignaturesEventAndWrite(action string, repo distribution.Repository, dgst digest.Digest, signatures [][]byte) error {
+	event := b.createEvent(action)
+	event.Target.Repository = repo.Name()
+	event.Target.MediaType = signaturesMediaType
+	event.Target.Digest = dgst
+
+	for _, signature := range signatures {
+		event.Target.Length += int64(len(signature))
+	}
+
+	var err error
+	event.Target.URL, err = b.ub.BuildSignaturesURL(repo.Name(), dgst)
+	if err != nil {
+		return err
+	}
+
+	return b.sink.Write(*event)
+}
+
 // createEvent creates an event with actor and source populated.
 func (b *bridge) createEvent(action string) *Event {
 	event := createEvent(action)
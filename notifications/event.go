@@ -12,6 +12,14 @@ const (
 	EventActionPull   = "pull"
 	EventActionPush   = "push"
 	EventActionDelete = "delete"
+
+	// EventActionUntag is used when a tag is removed while the manifest
+	// revision it referenced is left in place.
+	EventActionUntag = "untag"
+
+	// EventActionBlocked is used for pushes refused by the registry, such
+	// as a push moving an immutable tag.
+	EventActionBlocked = "blocked"
 )
 
 const (
@@ -22,6 +30,9 @@ const (
 	// LayerMediaType is the media type for image rootfs diffs (aka "layers")
 	// used by Docker. We don't expect this to change for quite a while.
 	layerMediaType = "application/vnd.docker.container.image.rootfs.diff+x-gtar"
+	// signaturesMediaType is the media type of the JWS signatures attached
+	// to a manifest revision.
+	signaturesMediaType = "application/jose+json"
 )
 
 // Envelope defines the fields of a json event envelope message that can hold
@@ -57,6 +68,10 @@ type Event struct {
 		// Repository identifies the named repository.
 		Repository string `json:"repository,omitempty"`
 
+		// Tag identifies the tag affected by the event. It is only set for
+		// tag events, such as the deletion of a tag.
+		Tag string `json:"tag,omitempty"`
+
 		// URL provides a direct link to the content.
 		URL string `json:"url,omitempty"`
 	} `json:"target,omitempty"`
@@ -7,18 +7,17 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
-	"github.com/docker/distribution/manifest"
 )
 
 // ManifestListener describes a set of methods for listening to events related to manifests.
 type ManifestListener interface {
-	ManifestPushed(repo distribution.Repository, sm *manifest.SignedManifest) error
-	ManifestPulled(repo distribution.Repository, sm *manifest.SignedManifest) error
+	ManifestPushed(repo distribution.Repository, m distribution.Manifest) error
+	ManifestPulled(repo distribution.Repository, m distribution.Manifest) error
 
 	// TODO(stevvooe): Please note that delete support is still a little shaky
 	// and we'll need to propagate these in the future.
 
-	ManifestDeleted(repo distribution.Repository, sm *manifest.SignedManifest) error
+	ManifestDeleted(repo distribution.Repository, m distribution.Manifest) error
 }
 
 // BlobListener describes a listener that can respond to layer related events.
@@ -32,10 +31,35 @@ type BlobListener interface {
 	BlobDeleted(repo distribution.Repository, desc distribution.Descriptor) error
 }
 
+// TagListener describes a listener that can respond to tag related events.
+type TagListener interface {
+	// TagDeleted is called when tag is removed from the repository. The
+	// manifest that the tag referenced, which is left in place, is provided.
+	TagDeleted(repo distribution.Repository, tag string, m distribution.Manifest) error
+
+	// TagBlocked is called when a push of the manifest to the tag is refused
+	// because the tag is immutable. The manifest was not stored.
+	TagBlocked(repo distribution.Repository, tag string, m distribution.Manifest) error
+}
+
+// SignatureListener describes a listener that can respond to events related
+// to the detached signatures of manifest revisions.
+type SignatureListener interface {
+	// SignaturesPushed is called when signatures are attached to the
+	// manifest revision identified by dgst.
+	SignaturesPushed(repo distribution.Repository, dgst digest.Digest, signatures [][]byte) error
+
+	// SignaturesPulled is called when the signatures of the manifest
+	// revision identified by dgst are fetched on their own.
+	SignaturesPulled(repo distribution.Repository, dgst digest.Digest, signatures [][]byte) error
+}
+
 // Listener combines all repository events into a single interface.
 type Listener interface {
 	ManifestListener
 	BlobListener
+	TagListener
+	SignatureListener
 }
 
 type repositoryListener struct {
@@ -65,43 +89,120 @@ func (rl *repositoryListener) Blobs(ctx context.Context) distribution.BlobStore
 	}
 }
 
+func (rl *repositoryListener) Signatures() distribution.SignatureService {
+	return &signatureServiceListener{
+		SignatureService: rl.Repository.Signatures(),
+		parent:           rl,
+	}
+}
+
 type manifestServiceListener struct {
 	distribution.ManifestService
 	parent *repositoryListener
 }
 
-func (msl *manifestServiceListener) Get(dgst digest.Digest) (*manifest.SignedManifest, error) {
-	sm, err := msl.ManifestService.Get(dgst)
+func (msl *manifestServiceListener) Get(dgst digest.Digest) (distribution.Manifest, error) {
+	m, err := msl.ManifestService.Get(dgst)
 	if err == nil {
-		if err := msl.parent.listener.ManifestPulled(msl.parent.Repository, sm); err != nil {
+		if err := msl.parent.listener.ManifestPulled(msl.parent.Repository, m); err != nil {
 			logrus.Errorf("error dispatching manifest pull to listener: %v", err)
 		}
 	}
 
-	return sm, err
+	return m, err
 }
 
-func (msl *manifestServiceListener) Put(sm *manifest.SignedManifest) error {
-	err := msl.ManifestService.Put(sm)
+func (msl *manifestServiceListener) Put(m distribution.Manifest, tag string) error {
+	err := msl.ManifestService.Put(m, tag)
 
-	if err == nil {
-		if err := msl.parent.listener.ManifestPushed(msl.parent.Repository, sm); err != nil {
+	switch err.(type) {
+	case nil:
+		if err := msl.parent.listener.ManifestPushed(msl.parent.Repository, m); err != nil {
 			logrus.Errorf("error dispatching manifest push to listener: %v", err)
 		}
+	case distribution.ErrTagImmutable:
+		if err := msl.parent.listener.TagBlocked(msl.parent.Repository, tag, m); err != nil {
+			logrus.Errorf("error dispatching blocked tag push to listener: %v", err)
+		}
 	}
 
 	return err
 }
 
-func (msl *manifestServiceListener) GetByTag(tag string) (*manifest.SignedManifest, error) {
-	sm, err := msl.ManifestService.GetByTag(tag)
+func (msl *manifestServiceListener) Delete(dgst digest.Digest) error {
+	// Resolve the manifest before removing it, so that the event can carry
+	// its details.
+	m, err := msl.ManifestService.Get(dgst)
+	if err != nil {
+		return err
+	}
+
+	if err := msl.ManifestService.Delete(dgst); err != nil {
+		return err
+	}
+
+	if err := msl.parent.listener.ManifestDeleted(msl.parent.Repository, m); err != nil {
+		logrus.Errorf("error dispatching manifest delete to listener: %v", err)
+	}
+
+	return nil
+}
+
+func (msl *manifestServiceListener) GetByTag(tag string) (distribution.Manifest, error) {
+	m, err := msl.ManifestService.GetByTag(tag)
 	if err == nil {
-		if err := msl.parent.listener.ManifestPulled(msl.parent.Repository, sm); err != nil {
+		if err := msl.parent.listener.ManifestPulled(msl.parent.Repository, m); err != nil {
 			logrus.Errorf("error dispatching manifest pull to listener: %v", err)
 		}
 	}
 
-	return sm, err
+	return m, err
+}
+
+func (msl *manifestServiceListener) DeleteByTag(tag string) error {
+	// Resolve the manifest before removing the tag, so that the event can
+	// reference the revision that was untagged.
+	m, err := msl.ManifestService.GetByTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if err := msl.ManifestService.DeleteByTag(tag); err != nil {
+		return err
+	}
+
+	if err := msl.parent.listener.TagDeleted(msl.parent.Repository, tag, m); err != nil {
+		logrus.Errorf("error dispatching tag delete to listener: %v", err)
+	}
+
+	return nil
+}
+
+type signatureServiceListener struct {
+	distribution.SignatureService
+	parent *repositoryListener
+}
+
+func (ssl *signatureServiceListener) Get(dgst digest.Digest) ([][]byte, error) {
+	signatures, err := ssl.SignatureService.Get(dgst)
+	if err == nil {
+		if err := ssl.parent.listener.SignaturesPulled(ssl.parent.Repository, dgst, signatures); err != nil {
+			logrus.Errorf("error dispatching signatures pull to listener: %v", err)
+		}
+	}
+
+	return signatures, err
+}
+
+func (ssl *signatureServiceListener) Put(dgst digest.Digest, signatures ...[]byte) error {
+	err := ssl.SignatureService.Put(dgst, signatures...)
+	if err == nil {
+		if err := ssl.parent.listener.SignaturesPushed(ssl.parent.Repository, dgst, signatures); err != nil {
+			logrus.Errorf("error dispatching signatures push to listener: %v", err)
+		}
+	}
+
+	return err
 }
 
 type blobServiceListener struct {
@@ -167,6 +268,25 @@ func (bsl *blobServiceListener) Put(ctx context.Context, mediaType string, p []b
 	return desc, err
 }
 
+func (bsl *blobServiceListener) Delete(ctx context.Context, dgst digest.Digest) error {
+	// Resolve the descriptor before removing the blob, so that the event can
+	// carry its details.
+	desc, err := bsl.BlobStore.Stat(ctx, dgst)
+	if err != nil {
+		return err
+	}
+
+	if err := bsl.BlobStore.Delete(ctx, dgst); err != nil {
+		return err
+	}
+
+	if err := bsl.parent.listener.BlobDeleted(bsl.parent.Repository, desc); err != nil {
+		context.GetLogger(ctx).Errorf("error dispatching layer delete to listener: %v", err)
+	}
+
+	return nil
+}
+
 func (bsl *blobServiceListener) Create(ctx context.Context) (distribution.BlobWriter, error) {
 	wr, err := bsl.BlobStore.Create(ctx)
 	return bsl.decorateWriter(wr), err
@@ -177,6 +297,19 @@ func (bsl *blobServiceListener) Resume(ctx context.Context, id string) (distribu
 	return bsl.decorateWriter(wr), err
 }
 
+func (bsl *blobServiceListener) Mount(ctx context.Context, sourceRepo string, dgst digest.Digest) (distribution.Descriptor, error) {
+	desc, err := bsl.BlobStore.Mount(ctx, sourceRepo, dgst)
+	if err == nil {
+		// A mounted blob becomes available in the repository just as if it
+		// had been pushed.
+		if err := bsl.parent.listener.BlobPushed(bsl.parent.Repository, desc); err != nil {
+			context.GetLogger(ctx).Errorf("error dispatching layer mount to listener: %v", err)
+		}
+	}
+
+	return desc, err
+}
+
 func (bsl *blobServiceListener) decorateWriter(wr distribution.BlobWriter) distribution.BlobWriter {
 	return &blobWriterListener{
 		BlobWriter: wr,
@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"bytes"
 	"io"
 	"reflect"
 	"testing"
@@ -33,12 +34,15 @@ func TestListener(t *testing.T) {
 	checkExerciseRepository(t, repository)
 
 	expectedOps := map[string]int{
-		"manifest:push": 1,
-		"manifest:pull": 2,
-		// "manifest:delete": 0, // deletes not supported for now
-		"layer:push": 2,
-		"layer:pull": 2,
-		// "layer:delete":    0, // deletes not supported for now
+		"manifest:push":   1,
+		"manifest:pull":   2,
+		"manifest:delete": 1,
+		"layer:push":      2,
+		"layer:pull":      2,
+		"layer:delete":    1,
+		"tag:delete":      1,
+		"signatures:push": 1,
+		"signatures:pull": 1,
 	}
 
 	if !reflect.DeepEqual(tl.ops, expectedOps) {
@@ -47,26 +51,85 @@ func TestListener(t *testing.T) {
 
 }
 
+func TestListenerTagBlocked(t *testing.T) {
+	tl := &testListener{
+		ops: make(map[string]int),
+	}
+
+	repository := Listen(&blockingRepository{}, tl)
+
+	if err := repository.Manifests().Put(nil, "v1"); err == nil {
+		t.Fatalf("expected error pushing to immutable tag")
+	}
+
+	expectedOps := map[string]int{
+		"tag:blocked": 1,
+	}
+
+	if !reflect.DeepEqual(tl.ops, expectedOps) {
+		t.Fatalf("counts do not match:\n%v\n !=\n%v", tl.ops, expectedOps)
+	}
+}
+
+// blockingRepository refuses every manifest push as if the tag was
+// immutable.
+type blockingRepository struct {
+	distribution.Repository
+	distribution.ManifestService
+}
+
+func (br *blockingRepository) Name() string {
+	return "foo/bar"
+}
+
+func (br *blockingRepository) Manifests() distribution.ManifestService {
+	return br
+}
+
+func (br *blockingRepository) Put(m distribution.Manifest, tag string) error {
+	return distribution.ErrTagImmutable{Name: br.Name(), Tag: tag}
+}
+
 type testListener struct {
 	ops map[string]int
 }
 
-func (tl *testListener) ManifestPushed(repo distribution.Repository, sm *manifest.SignedManifest) error {
+func (tl *testListener) ManifestPushed(repo distribution.Repository, m distribution.Manifest) error {
 	tl.ops["manifest:push"]++
 
 	return nil
 }
 
-func (tl *testListener) ManifestPulled(repo distribution.Repository, sm *manifest.SignedManifest) error {
+func (tl *testListener) ManifestPulled(repo distribution.Repository, m distribution.Manifest) error {
 	tl.ops["manifest:pull"]++
 	return nil
 }
 
-func (tl *testListener) ManifestDeleted(repo distribution.Repository, sm *manifest.SignedManifest) error {
+func (tl *testListener) ManifestDeleted(repo distribution.Repository, m distribution.Manifest) error {
 	tl.ops["manifest:delete"]++
 	return nil
 }
 
+func (tl *testListener) TagDeleted(repo distribution.Repository, tag string, m distribution.Manifest) error {
+	tl.ops["tag:delete"]++
+	return nil
+}
+
+func (tl *testListener) TagBlocked(repo distribution.Repository, tag string, m distribution.Manifest) error {
+	tl.ops["tag:blocked"]++
+	return nil
+}
+
+func (tl *testListener) SignaturesPushed(repo distribution.Repository, dgst digest.Digest, signatures [][]byte) error {
+	tl.ops["signatures:push"]++
+	return nil
+}
+
+func (tl *testListener) SignaturesPulled(repo distribution.Repository, dgst digest.Digest, signatures [][]byte) error {
+	tl.ops["signatures:pull"]++
+	return nil
+}
+
 func (tl *testListener) BlobPushed(repo distribution.Repository, desc distribution.Descriptor) error {
 	tl.ops["layer:push"]++
 	return nil
@@ -148,7 +211,7 @@ func checkExerciseRepository(t *testing.T, repository distribution.Repository) {
 
 	manifests := repository.Manifests()
 
-	if err := manifests.Put(sm); err != nil {
+	if err := manifests.Put(sm, tag); err != nil {
 		t.Fatalf("unexpected error putting the manifest: %v", err)
 	}
 
@@ -167,7 +230,7 @@ func checkExerciseRepository(t *testing.T, repository distribution.Repository) {
 		t.Fatalf("unexpected error fetching manifest: %v", err)
 	}
 
-	if fetchedByManifest.Tag != sm.Tag {
+	if !bytes.Equal(fetchedByManifest.Content(), sm.Raw) {
 		t.Fatalf("retrieved unexpected manifest: %v", err)
 	}
 
@@ -176,7 +239,48 @@ func checkExerciseRepository(t *testing.T, repository distribution.Repository) {
 		t.Fatalf("unexpected error fetching manifest: %v", err)
 	}
 
-	if fetched.Tag != fetchedByManifest.Tag {
+	if !bytes.Equal(fetched.Content(), fetchedByManifest.Content()) {
 		t.Fatalf("retrieved unexpected manifest: %v", err)
 	}
+
+	// Attach the signature of another key to the revision.
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+
+	otherSigned, err := manifest.Sign(&m, otherKey)
+	if err != nil {
+		t.Fatalf("unexpected error signing manifest: %v", err)
+	}
+
+	otherSignatures, err := otherSigned.Signatures()
+	if err != nil {
+		t.Fatalf("unexpected error getting signatures: %v", err)
+	}
+
+	if err := repository.Signatures().Put(dgst, otherSignatures...); err != nil {
+		t.Fatalf("unexpected error attaching signatures: %v", err)
+	}
+
+	signatures, err := repository.Signatures().Get(dgst)
+	if err != nil {
+		t.Fatalf("unexpected error fetching signatures: %v", err)
+	}
+
+	if len(signatures) != 2 {
+		t.Fatalf("unexpected number of signatures: %d != 2", len(signatures))
+	}
+
+	if err := manifests.DeleteByTag(tag); err != nil {
+		t.Fatalf("unexpected error deleting tag: %v", err)
+	}
+
+	if err := manifests.Delete(dgst); err != nil {
+		t.Fatalf("unexpected error deleting manifest: %v", err)
+	}
+
+	if err := blobs.Delete(ctx, m.FSLayers[0].BlobSum); err != nil {
+		t.Fatalf("unexpected error deleting layer: %v", err)
+	}
 }
@@ -3,7 +3,6 @@ package distribution
 import (
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
-	"github.com/docker/distribution/manifest"
 )
 
 // Scope defines the set of items that match a namespace.
@@ -38,6 +37,21 @@ type Namespace interface {
 	// reference.
 	// 一定会返回一个命名的 registry 的引用 ?
 	Repository(ctx context.Context, name string) (Repository, error)
+
+	// Catalog returns a reference to a service that lists the repositories
+	// in the namespace.
+	// 返回列出所有 repository 的服务
+	Catalog(ctx context.Context) CatalogService
+}
+
+// CatalogService provides a way of listing the names of the repositories in a
+// namespace.
+type CatalogService interface {
+	// Get returns up to n repository names, in lexical order, starting after
+	// last. If n is less than one, all remaining names are returned. The
+	// returned boolean is true if there are further names after those
+	// returned.
+	Get(n int, last string) (repos []string, more bool, err error)
 }
 
 // Repository is a named collection of manifests and layers.
@@ -74,15 +88,16 @@ type ManifestService interface {
 
 	// Get retrieves the identified by the digest, if it exists.
 	// 通过 digest 获取 manifest
-	Get(dgst digest.Digest) (*manifest.SignedManifest, error)
+	Get(dgst digest.Digest) (Manifest, error)
 
 	// Delete removes the manifest, if it exists.
-	// 删除 manifest 不支持操作
+	// 删除 manifest 及指向它的 tag
 	Delete(dgst digest.Digest) error
 
-	// Put creates or updates the manifest.
-	// 创建或者更新一个 manifest
-	Put(manifest *manifest.SignedManifest) error
+	// Put creates or updates the manifest. If tag is not empty, the tag is
+	// updated to reference the manifest.
+	// 创建或者更新一个 manifest，并可选地打上 tag
+	Put(manifest Manifest, tag string) error
 
 	// TODO(stevvooe): The methods after this message should be moved to a
 	// discrete TagService, per active proposals.
@@ -97,7 +112,12 @@ type ManifestService interface {
 
 	// GetByTag retrieves the named manifest, if it exists.
 	// 通过 tag 获得 manifest
-	GetByTag(tag string) (*manifest.SignedManifest, error)
+	GetByTag(tag string) (Manifest, error)
+
+	// DeleteByTag removes the tag, leaving the manifest revision it
+	// referenced in place.
+	// 删除 tag，但保留其指向的 manifest
+	DeleteByTag(tag string) error
 
 	// TODO(stevvooe): There are several changes that need to be done to this
 	// interface:
@@ -109,8 +129,6 @@ type ManifestService interface {
 	//       the manifest entries.
 	//	4. Long-term: break out concept of signing from manifests. This is
 	//       really a part of the distribution sprint.
-	//	5. Long-term: Manifest should be an interface. This code shouldn't
-	//       really be concerned with the storage format.
 }
 
 // SignatureService provides operations on signatures.
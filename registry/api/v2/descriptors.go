@@ -86,6 +86,106 @@ var (
 		Format:      "<digest>",
 	}
 
+	manifestAcceptHeader = ParameterDescriptor{
+		Name:        "Accept",
+		Type:        "string",
+		Description: "Manifest media types supported by the client. Schema1 manifests are always returned. Manifests of other formats, such as `application/vnd.docker.distribution.manifest.v2+json` or the manifest list type `application/vnd.docker.distribution.manifest.list.v2+json`, are only returned if their media type is listed. Otherwise the request fails with `406 Not Acceptable`.",
+		Format:      "<media type>, ...",
+		Examples:    []string{"application/vnd.docker.distribution.manifest.v2+json, application/vnd.docker.distribution.manifest.list.v2+json"},
+	}
+
+	manifestContentTypeHeader = ParameterDescriptor{
+		Name:        "Content-Type",
+		Type:        "string",
+		Description: "The media type of the manifest, which selects its format. Use `application/vnd.docker.distribution.manifest.v2+json` for schema2 manifests and `application/vnd.docker.distribution.manifest.list.v2+json` for manifest lists. Any other media type, including `application/vnd.docker.distribution.manifest.v1+prettyjws` or none, reads the manifest as schema1.",
+		Format:      "<media type>",
+	}
+
+	paginationParameters = []ParameterDescriptor{
+		{
+			Name:        "n",
+			Type:        "integer",
+			Description: "Limit the number of entries in each response. If not present, all entries will be returned.",
+			Format:      "<integer>",
+			Required:    false,
+		},
+		{
+			Name:        "last",
+			Type:        "string",
+			Description: "Result set will include values lexically after last.",
+			Format:      "<string>",
+			Required:    false,
+		},
+	}
+
+	linkHeader = ParameterDescriptor{
+		Name:        "Link",
+		Type:        "link",
+		Description: "RFC5988 compliant rel='next' with URL to next result set, if available",
+		Format:      `<<url>?n=<last n value>&last=<last entry from response>>; rel="next"`,
+	}
+
+	paginationNumberInvalidResponse = ResponseDescriptor{
+		Description: "The value of `n` is not a valid, non-negative integer.",
+		StatusCode:  http.StatusBadRequest,
+		Body: BodyDescriptor{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+		ErrorCodes: []ErrorCode{
+			ErrorCodePaginationNumberInvalid,
+		},
+	}
+
+	retryAfterHeader = ParameterDescriptor{
+		Name:        "Retry-After",
+		Type:        "integer",
+		Description: "The number of seconds after which the client may retry the request.",
+		Format:      "<seconds>",
+	}
+
+	readOnlyResponse = ResponseDescriptor{
+		Name:        "Read-Only Mode",
+		Description: "The registry is in read-only maintenance mode and does not accept writes. The request may be retried after the period given in the `Retry-After` header.",
+		StatusCode:  http.StatusServiceUnavailable,
+		Headers: []ParameterDescriptor{
+			retryAfterHeader,
+		},
+		ErrorCodes: []ErrorCode{
+			ErrorCodeReadOnly,
+		},
+		Body: BodyDescriptor{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+	}
+
+	quotaExceededResponse = ResponseDescriptor{
+		Name:        "Quota Exceeded",
+		Description: "The write would take the repository over a configured storage quota. Nothing was linked into the repository.",
+		StatusCode:  http.StatusInsufficientStorage,
+		ErrorCodes: []ErrorCode{
+			ErrorCodeQuotaExceeded,
+		},
+		Body: BodyDescriptor{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+	}
+
+	tagImmutableResponse = ResponseDescriptor{
+		Name:        "Tag Immutable",
+		Description: "The tag is immutable and the request would change the revision it references.",
+		StatusCode:  http.StatusConflict,
+		ErrorCodes: []ErrorCode{
+			ErrorCodeTagImmutable,
+		},
+		Body: BodyDescriptor{
+			ContentType: "application/json; charset=utf-8",
+			Format:      errorsBody,
+		},
+	}
+
 	unauthorizedResponse = ResponseDescriptor{
 		Description: "The client does not have access to the repository.",
 		StatusCode:  http.StatusUnauthorized,
@@ -130,6 +230,30 @@ var (
 )
 
 const (
+	signaturesBody = `{
+    "name": <name>,
+    "digest": <digest>,
+    "signatures": [
+        {
+            "header": <JWS header>,
+            "signature": <JWS signature>,
+            "protected": <JWS protected header>
+        },
+        ...
+    ]
+}`
+
+	signaturesRequestBody = `{
+    "signatures": [
+        {
+            "header": <JWS header>,
+            "signature": <JWS signature>,
+            "protected": <JWS protected header>
+        },
+        ...
+    ]
+}`
+
 	manifestBody = `{
    "name": <name>,
    "tag": <tag>,
@@ -144,6 +268,42 @@ const (
    "signature": <JWS>
 }`
 
+	schema2ManifestBody = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/vnd.docker.container.image.v1+json",
+      "size": <size>,
+      "digest": "<digest>"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+         "size": <size>,
+         "digest": "<digest>"
+      },
+      ...
+   ]
+}`
+
+	manifestListBody = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+   "manifests": [
+      {
+         "mediaType": "<media type>",
+         "size": <size>,
+         "digest": "<digest>",
+         "platform": {
+            "architecture": "<architecture>",
+            "os": "<os>",
+            "variant": "<variant>"
+         }
+      },
+      ...
+   ]
+}`
+
 	errorsBody = `{
 	"errors:" [
 	    {
@@ -399,6 +559,8 @@ var routeDescriptors = []RouteDescriptor{
 				Description: "Fetch the tags under the repository identified by `name`.",
 				Requests: []RequestDescriptor{
 					{
+						Name:        "Tags",
+						Description: "Return all tags for the repository",
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
@@ -455,6 +617,56 @@ var routeDescriptors = []RouteDescriptor{
 							},
 						},
 					},
+					{
+						Name:            "Tags Paginated",
+						Description:     "Return a portion of the tags for the specified repository.",
+						PathParameters:  []ParameterDescriptor{nameParameterDescriptor},
+						QueryParameters: paginationParameters,
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode:  http.StatusOK,
+								Description: "A list of tags for the named repository.",
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+									linkHeader,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format: `{
+    "name": <name>,
+    "tags": [
+        <tag>,
+        ...
+    ]
+}`,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							paginationNumberInvalidResponse,
+							{
+								StatusCode:  http.StatusNotFound,
+								Description: "The repository is not known to the registry.",
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameUnknown,
+								},
+							},
+							unauthorizedResponse,
+						},
+					},
 				},
 			},
 		},
@@ -473,6 +685,7 @@ var routeDescriptors = []RouteDescriptor{
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
+							manifestAcceptHeader,
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -490,6 +703,30 @@ var routeDescriptors = []RouteDescriptor{
 									Format:      manifestBody,
 								},
 							},
+							{
+								Name:        "Schema2 Manifest",
+								Description: "The schema2 manifest identified by `name` and `reference`, returned when the client accepts it.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									digestHeader,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/vnd.docker.distribution.manifest.v2+json",
+									Format:      schema2ManifestBody,
+								},
+							},
+							{
+								Name:        "Manifest List",
+								Description: "The manifest list identified by `name` and `reference`, returned when the client accepts it. Each entry references a platform specific manifest by digest.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									digestHeader,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/vnd.docker.distribution.manifest.list.v2+json",
+									Format:      manifestListBody,
+								},
+							},
 						},
 						Failures: []ResponseDescriptor{
 							{
@@ -527,18 +764,30 @@ var routeDescriptors = []RouteDescriptor{
 									Format:      errorsBody,
 								},
 							},
+							{
+								Description: "The manifest exists, but it is not a schema1 manifest and its media type is not listed in the `Accept` header.",
+								StatusCode:  http.StatusNotAcceptable,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeManifestNotAcceptable,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
 						},
 					},
 				},
 			},
 			{
 				Method:      "PUT",
-				Description: "Put the manifest identified by `name` and `reference` where `reference` can be a tag or digest.",
+				Description: "Put the manifest identified by `name` and `reference` where `reference` can be a tag or digest. The format of the manifest is selected by the `Content-Type` header.",
 				Requests: []RequestDescriptor{
 					{
 						Headers: []ParameterDescriptor{
 							hostHeader,
 							authHeader,
+							manifestContentTypeHeader,
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
@@ -565,6 +814,9 @@ var routeDescriptors = []RouteDescriptor{
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							quotaExceededResponse,
+							tagImmutableResponse,
 							{
 								Name:        "Invalid Manifest",
 								Description: "The received manifest was invalid in some way, as described by the error codes. The client should resolve the issue and retry the request.",
@@ -578,6 +830,7 @@ var routeDescriptors = []RouteDescriptor{
 									ErrorCodeTagInvalid,
 									ErrorCodeManifestInvalid,
 									ErrorCodeManifestUnverified,
+									ErrorCodeManifestUnknown,
 									ErrorCodeBlobUnknown,
 								},
 							},
@@ -638,8 +891,213 @@ var routeDescriptors = []RouteDescriptor{
 				},
 			},
 			{
-				Method:      "DELETE",
-				Description: "Delete the manifest identified by `name` and `reference` where `reference` can be a tag or digest.",
+				Method:      "DELETE",
+				Description: "Delete the manifest or tag identified by `name` and `reference`. A delete by `digest` removes the manifest revision and any tags referencing it. A delete by `tag` removes only the tag, leaving the manifest revision in place.",
+				Requests: []RequestDescriptor{
+					{
+						Name:        "Delete Manifest",
+						Description: "Delete the manifest revision identified by `digest`, along with any tags referencing it.",
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+							digestPathParameter,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The manifest has been deleted from the repository.",
+								StatusCode:  http.StatusAccepted,
+							},
+						},
+						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							tagImmutableResponse,
+							{
+								Name:        "Invalid Name or Reference",
+								Description: "The specified `name` or `reference` were invalid and the delete was unable to proceed.",
+								StatusCode:  http.StatusBadRequest,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameInvalid,
+									ErrorCodeTagInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							{
+								StatusCode: http.StatusUnauthorized,
+								Headers: []ParameterDescriptor{
+									authChallengeHeader,
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON error response body.",
+										Format:      "<length>",
+									},
+								},
+								ErrorCodes: []ErrorCode{
+									ErrorCodeUnauthorized,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							{
+								Name:        "Unknown Manifest",
+								Description: "The specified `name` or `tag` are unknown to the registry and the delete was unable to proceed. Clients can assume the manifest was already deleted if this response is returned.",
+								StatusCode:  http.StatusNotFound,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameUnknown,
+									ErrorCodeManifestUnknown,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+						},
+					},
+					{
+						Name:        "Delete Tag",
+						Description: "Delete the tag identified by `reference`. Only the tag is removed: the manifest revision it referenced remains available by digest.",
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+							tagParameterDescriptor,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The tag has been removed from the repository.",
+								StatusCode:  http.StatusAccepted,
+							},
+						},
+						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							tagImmutableResponse,
+							{
+								Name:        "Invalid Name or Reference",
+								Description: "The specified `name` or `reference` were invalid and the delete was unable to proceed.",
+								StatusCode:  http.StatusBadRequest,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameInvalid,
+									ErrorCodeTagInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							{
+								StatusCode: http.StatusUnauthorized,
+								Headers: []ParameterDescriptor{
+									authChallengeHeader,
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON error response body.",
+										Format:      "<length>",
+									},
+								},
+								ErrorCodes: []ErrorCode{
+									ErrorCodeUnauthorized,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							{
+								Name:        "Unknown Tag",
+								Description: "The specified `name` or `tag` are unknown to the registry and the delete was unable to proceed. Clients can assume the tag was already deleted if this response is returned.",
+								StatusCode:  http.StatusNotFound,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameUnknown,
+									ErrorCodeManifestUnknown,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+
+	{
+		Name:        RouteNameSignatures,
+		Path:        "/v2/{name:" + RepositoryNameRegexp.String() + "}/manifests/{digest:" + digest.DigestRegexp.String() + "}/signatures",
+		Entity:      "Signatures",
+		Description: "Retrieve and attach the detached signatures of a schema1 manifest revision. Each signature is a JWS signature object over the payload of the manifest, as found in the `signatures` array of a signed manifest. Attached signatures are returned along with the manifest when it is fetched.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      "GET",
+				Description: "Fetch the signatures of the manifest revision identified by `name` and `digest`.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+							digestPathParameter,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The signatures of the manifest revision. Manifest formats other than schema1 are unsigned, so their list is empty.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									digestHeader,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      signaturesBody,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Description: "The `name` or `digest` were invalid.",
+								StatusCode:  http.StatusBadRequest,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameInvalid,
+									ErrorCodeDigestInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							{
+								Description: "The manifest revision is unknown to the registry.",
+								StatusCode:  http.StatusNotFound,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameUnknown,
+									ErrorCodeManifestUnknown,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							unauthorizedResponse,
+						},
+					},
+				},
+			},
+			{
+				Method:      "PUT",
+				Description: "Attach signatures to the manifest revision identified by `name` and `digest`, without pushing the manifest again. Each signature must verify against the payload of the manifest. Signatures already attached are kept.",
 				Requests: []RequestDescriptor{
 					{
 						Headers: []ParameterDescriptor{
@@ -648,40 +1106,39 @@ var routeDescriptors = []RouteDescriptor{
 						},
 						PathParameters: []ParameterDescriptor{
 							nameParameterDescriptor,
-							tagParameterDescriptor,
+							digestPathParameter,
+						},
+						Body: BodyDescriptor{
+							ContentType: "application/json; charset=utf-8",
+							Format:      signaturesRequestBody,
 						},
 						Successes: []ResponseDescriptor{
 							{
-								StatusCode: http.StatusAccepted,
+								Description: "The signatures have been attached to the manifest revision.",
+								StatusCode:  http.StatusCreated,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Location",
+										Type:        "url",
+										Description: "The location of the signatures of the manifest revision.",
+										Format:      "<url>",
+									},
+									contentLengthZeroHeader,
+									digestHeader,
+								},
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
 							{
-								Name:        "Invalid Name or Tag",
-								Description: "The specified `name` or `tag` were invalid and the delete was unable to proceed.",
+								Name:        "Invalid Signatures",
+								Description: "The signatures were invalid, did not verify against the payload of the manifest, or the manifest format is unsigned.",
 								StatusCode:  http.StatusBadRequest,
 								ErrorCodes: []ErrorCode{
 									ErrorCodeNameInvalid,
-									ErrorCodeTagInvalid,
-								},
-								Body: BodyDescriptor{
-									ContentType: "application/json; charset=utf-8",
-									Format:      errorsBody,
-								},
-							},
-							{
-								StatusCode: http.StatusUnauthorized,
-								Headers: []ParameterDescriptor{
-									authChallengeHeader,
-									{
-										Name:        "Content-Length",
-										Type:        "integer",
-										Description: "Length of the JSON error response body.",
-										Format:      "<length>",
-									},
-								},
-								ErrorCodes: []ErrorCode{
-									ErrorCodeUnauthorized,
+									ErrorCodeDigestInvalid,
+									ErrorCodeManifestInvalid,
+									ErrorCodeManifestUnverified,
 								},
 								Body: BodyDescriptor{
 									ContentType: "application/json; charset=utf-8",
@@ -689,8 +1146,7 @@ var routeDescriptors = []RouteDescriptor{
 								},
 							},
 							{
-								Name:        "Unknown Manifest",
-								Description: "The specified `name` or `tag` are unknown to the registry and the delete was unable to proceed. Clients can assume the manifest was already deleted if this response is returned.",
+								Description: "The manifest revision is unknown to the registry.",
 								StatusCode:  http.StatusNotFound,
 								ErrorCodes: []ErrorCode{
 									ErrorCodeNameUnknown,
@@ -701,6 +1157,7 @@ var routeDescriptors = []RouteDescriptor{
 									Format:      errorsBody,
 								},
 							},
+							unauthorizedResponsePush,
 						},
 					},
 				},
@@ -712,7 +1169,7 @@ var routeDescriptors = []RouteDescriptor{
 		Name:        RouteNameBlob,
 		Path:        "/v2/{name:" + RepositoryNameRegexp.String() + "}/blobs/{digest:" + digest.DigestRegexp.String() + "}",
 		Entity:      "Blob",
-		Description: "Fetch the blob identified by `name` and `digest`. Used to fetch layers by digest.",
+		Description: "Operations on blobs identified by `name` and `digest`. Used to fetch or delete layers by digest.",
 		Methods: []MethodDescriptor{
 
 			{
@@ -863,6 +1320,60 @@ var routeDescriptors = []RouteDescriptor{
 					},
 				},
 			},
+			{
+				Method:      "DELETE",
+				Description: "Delete the blob identified by `name` and `digest`. Only the link from the repository is removed: the blob remains available to other repositories that reference it.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+							digestPathParameter,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The blob has been removed from the repository.",
+								StatusCode:  http.StatusAccepted,
+								Headers: []ParameterDescriptor{
+									contentLengthZeroHeader,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							{
+								Name:       "Invalid Name or Digest",
+								StatusCode: http.StatusBadRequest,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeDigestInvalid,
+									ErrorCodeNameInvalid,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+							},
+							unauthorizedResponse,
+							{
+								Description: "The blob, identified by `name` and `digest`, is unknown to the registry.",
+								StatusCode:  http.StatusNotFound,
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []ErrorCode{
+									ErrorCodeNameUnknown,
+									ErrorCodeBlobUnknown,
+								},
+							},
+						},
+					},
+				},
+			},
+
 			// TODO(stevvooe): We may want to add a PUT request here to
 			// kickoff an upload of a blob, integrated with the blob upload
 			// API.
@@ -909,7 +1420,7 @@ var routeDescriptors = []RouteDescriptor{
 						},
 						Successes: []ResponseDescriptor{
 							{
-								Description: "The blob has been created in the registry and is available at the provided location.",
+								Description: "The blob has been created in the registry and is available at the provided location. The `Docker-Content-Digest` header carries the canonical digest of the blob, which may differ from the provided digest.",
 								StatusCode:  http.StatusCreated,
 								Headers: []ParameterDescriptor{
 									{
@@ -918,17 +1429,21 @@ var routeDescriptors = []RouteDescriptor{
 										Format: "<blob location>",
 									},
 									contentLengthZeroHeader,
-									dockerUploadUUIDHeader,
+									digestHeader,
 								},
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							quotaExceededResponse,
 							{
-								Name:       "Invalid Name or Digest",
-								StatusCode: http.StatusBadRequest,
+								Name:        "Invalid Name or Digest",
+								Description: "The `name` or `digest` were invalid, or the request body did not match the provided digest. Nothing was stored.",
+								StatusCode:  http.StatusBadRequest,
 								ErrorCodes: []ErrorCode{
 									ErrorCodeDigestInvalid,
 									ErrorCodeNameInvalid,
+									ErrorCodeBlobUploadInvalid,
 								},
 							},
 							unauthorizedResponsePush,
@@ -967,6 +1482,63 @@ var routeDescriptors = []RouteDescriptor{
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							{
+								Name:       "Invalid Name or Digest",
+								StatusCode: http.StatusBadRequest,
+								ErrorCodes: []ErrorCode{
+									ErrorCodeDigestInvalid,
+									ErrorCodeNameInvalid,
+								},
+							},
+							unauthorizedResponsePush,
+						},
+					},
+					{
+						Name:        "Mount Blob",
+						Description: "Mount a blob identified by the `mount` parameter from another repository.",
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+							contentLengthZeroHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+						},
+						QueryParameters: []ParameterDescriptor{
+							{
+								Name:        "mount",
+								Type:        "query",
+								Format:      "<digest>",
+								Regexp:      digest.DigestRegexp,
+								Description: `Digest of blob to mount from the source repository.`,
+							},
+							{
+								Name:        "from",
+								Type:        "query",
+								Format:      "<repository name>",
+								Regexp:      RepositoryNameRegexp,
+								Description: `Name of the source repository.`,
+							},
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The blob has been mounted in the repository and is available at the provided location. If the blob cannot be mounted, a regular upload session is started instead, as described for `Initiate Resumable Blob Upload`.",
+								StatusCode:  http.StatusCreated,
+								Headers: []ParameterDescriptor{
+									{
+										Name:   "Location",
+										Type:   "url",
+										Format: "<blob location>",
+									},
+									contentLengthZeroHeader,
+									digestHeader,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							quotaExceededResponse,
 							{
 								Name:       "Invalid Name or Digest",
 								StatusCode: http.StatusBadRequest,
@@ -1093,6 +1665,7 @@ var routeDescriptors = []RouteDescriptor{
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
 							{
 								Description: "There was an error processing the upload and it must be restarted.",
 								StatusCode:  http.StatusBadRequest,
@@ -1172,6 +1745,7 @@ var routeDescriptors = []RouteDescriptor{
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
 							{
 								Description: "There was an error processing the upload and it must be restarted.",
 								StatusCode:  http.StatusBadRequest,
@@ -1263,6 +1837,8 @@ var routeDescriptors = []RouteDescriptor{
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
+							quotaExceededResponse,
 							{
 								Description: "There was an error processing the upload and it must be restarted.",
 								StatusCode:  http.StatusBadRequest,
@@ -1318,6 +1894,7 @@ var routeDescriptors = []RouteDescriptor{
 							},
 						},
 						Failures: []ResponseDescriptor{
+							readOnlyResponse,
 							{
 								Description: "An error was encountered processing the delete. The client may ignore this error.",
 								StatusCode:  http.StatusBadRequest,
@@ -1348,6 +1925,137 @@ var routeDescriptors = []RouteDescriptor{
 			},
 		},
 	},
+	{
+		Name:        RouteNameCatalog,
+		Path:        "/v2/_catalog",
+		Entity:      "Catalog",
+		Description: "List a set of available repositories in the local registry cluster. Does not provide any indication of what may be available upstream. Applications can only determine if a repository is available but not if it is not available.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      "GET",
+				Description: "Retrieve a sorted, json list of repositories available in the registry.",
+				Requests: []RequestDescriptor{
+					{
+						Name:        "Catalog Fetch Complete",
+						Description: "Request an unabridged list of repositories available.",
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "Returns the unabridged list of repositories as a json response.",
+								StatusCode:  http.StatusOK,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format: `{
+	"repositories": [
+		<name>,
+		...
+	]
+}`,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							unauthorizedResponse,
+						},
+					},
+					{
+						Name:            "Catalog Fetch Paginated",
+						Description:     "Return the specified portion of repositories.",
+						QueryParameters: paginationParameters,
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode: http.StatusOK,
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format: `{
+	"repositories": [
+		<name>,
+		...
+	]
+}`,
+								},
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+									linkHeader,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							paginationNumberInvalidResponse,
+							unauthorizedResponse,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameQuota,
+		Path:        "/v2/{name:" + RepositoryNameRegexp.String() + "}/quota",
+		Entity:      "Quota",
+		Description: "Retrieve the storage usage of a repository and the quotas that apply to it.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      "GET",
+				Description: "Fetch the storage usage of the repository identified by `name`. A blob counts once towards each repository it is linked into.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{
+							hostHeader,
+							authHeader,
+						},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+						},
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode:  http.StatusOK,
+								Description: "The bytes used by the repository and, for each quota matching the repository name, the limit and the bytes used by all repositories matching the quota pattern.",
+								Body: BodyDescriptor{
+									ContentType: "application/json; charset=utf-8",
+									Format: `{
+    "name": <name>,
+    "used": <bytes>,
+    "quotas": [
+        {
+            "pattern": <pattern>,
+            "limit": <bytes>,
+            "used": <bytes>
+        },
+        ...
+    ]
+}`,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							unauthorizedResponse,
+						},
+					},
+				},
+			},
+		},
+	},
 }
 
 // ErrorDescriptors provides a list of HTTP API Error codes that may be
@@ -1472,6 +2180,54 @@ var errorDescriptors = []ErrorDescriptor{
 		longer proceed.`,
 		HTTPStatusCodes: []int{http.StatusNotFound},
 	},
+	{
+		Code:    ErrorCodePaginationNumberInvalid,
+		Value:   "PAGINATION_NUMBER_INVALID",
+		Message: "invalid number of results requested",
+		Description: `Returned when the "n" parameter (number of results
+		to return) is not an integer, or "n" is negative.`,
+		HTTPStatusCodes: []int{http.StatusBadRequest},
+	},
+	{
+		Code:    ErrorCodeReadOnly,
+		Value:   "READ_ONLY",
+		Message: "registry is in read-only mode",
+		Description: `Returned when a write is attempted while the registry
+		is in read-only maintenance mode. Pulls continue to work. The request
+		may be retried later.`,
+		HTTPStatusCodes: []int{http.StatusServiceUnavailable},
+	},
+	{
+		Code:    ErrorCodeQuotaExceeded,
+		Value:   "QUOTA_EXCEEDED",
+		Message: "storage quota exceeded",
+		Description: `Returned when a blob upload, blob mount or manifest
+		put would take the repository over a configured storage quota. The
+		detail contains the quota pattern, its limit and the current usage.
+		The request will not succeed until content is removed from the
+		repositories sharing the quota.`,
+		HTTPStatusCodes: []int{http.StatusInsufficientStorage},
+	},
+	{
+		Code:    ErrorCodeTagImmutable,
+		Value:   "TAG_IMMUTABLE",
+		Message: "tag is immutable",
+		Description: `Returned when a manifest put would move a tag that is
+		configured as immutable to a different revision, or when a delete would
+		remove such a tag. The detail contains the tag and the revision it
+		references.`,
+		HTTPStatusCodes: []int{http.StatusConflict},
+	},
+	{
+		Code:    ErrorCodeManifestNotAcceptable,
+		Value:   "MANIFEST_NOT_ACCEPTABLE",
+		Message: "manifest media type not accepted",
+		Description: `Returned when the manifest exists but its media type,
+		such as the schema2 manifest or manifest list type, is not listed in
+		the Accept header of the request. The detail contains the media type.
+		The registry does not convert such manifests to schema1.`,
+		HTTPStatusCodes: []int{http.StatusNotAcceptable},
+	},
 }
 
 var errorCodeToDescriptors map[ErrorCode]ErrorDescriptor
@@ -60,6 +60,26 @@ const (
 
 	// ErrorCodeBlobUploadInvalid is returned when an upload is invalid.
 	ErrorCodeBlobUploadInvalid
+
+	// ErrorCodePaginationNumberInvalid is returned when the `n` parameter is
+	// not an integer, or `n` is negative.
+	ErrorCodePaginationNumberInvalid
+
+	// ErrorCodeReadOnly is returned when a write is attempted while the
+	// registry is in read-only maintenance mode.
+	ErrorCodeReadOnly
+
+	// ErrorCodeQuotaExceeded is returned when a write would take a
+	// repository over its storage quota.
+	ErrorCodeQuotaExceeded
+
+	// ErrorCodeTagImmutable is returned when a push or delete would change
+	// an immutable tag.
+	ErrorCodeTagImmutable
+
+	// ErrorCodeManifestNotAcceptable is returned when a manifest is fetched
+	// by a client that does not accept its media type.
+	ErrorCodeManifestNotAcceptable
 )
 
 // ParseErrorCode attempts to parse the error code string, returning
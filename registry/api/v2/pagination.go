@@ -0,0 +1,23 @@
+package v2
+
+import "sort"
+
+// Paginate returns up to n of the lexically sorted entries that follow last,
+// as requested with the "n" and "last" parameters of the catalog and tags
+// endpoints. If n is zero, all remaining entries are returned. more is true
+// if entries remain after the returned page.
+// 按 n 和 last 参数分页
+func Paginate(entries []string, n int, last string) (page []string, more bool) {
+	// skip past the last entry returned
+	start := sort.SearchStrings(entries, last)
+	if start < len(entries) && entries[start] == last {
+		start++
+	}
+	entries = entries[start:]
+
+	if n > 0 && len(entries) > n {
+		return entries[:n], true
+	}
+
+	return entries, false
+}
@@ -0,0 +1,33 @@
+package v2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	entries := []string{"a", "b", "c", "d"}
+
+	for _, testcase := range []struct {
+		n    int
+		last string
+		page []string
+		more bool
+	}{
+		{n: 0, last: "", page: []string{"a", "b", "c", "d"}},
+		{n: 2, last: "", page: []string{"a", "b"}, more: true},
+		{n: 2, last: "b", page: []string{"c", "d"}},
+		{n: 4, last: "", page: []string{"a", "b", "c", "d"}},
+		{n: 1, last: "c", page: []string{"d"}},
+		// last need not be one of the entries.
+		{n: 2, last: "bb", page: []string{"c", "d"}},
+		{n: 2, last: "d", page: []string{}},
+		{n: 2, last: "z", page: []string{}},
+	} {
+		page, more := Paginate(entries, testcase.n, testcase.last)
+		if !reflect.DeepEqual(page, testcase.page) || more != testcase.more {
+			t.Fatalf("unexpected page for n=%d last=%q: %v, %v != %v, %v",
+				testcase.n, testcase.last, page, more, testcase.page, testcase.more)
+		}
+	}
+}
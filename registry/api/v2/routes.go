@@ -7,18 +7,24 @@ import "github.com/gorilla/mux"
 const (
 	RouteNameBase            = "base"
 	RouteNameManifest        = "manifest"
+	RouteNameSignatures      = "signatures"
 	RouteNameTags            = "tags"
 	RouteNameBlob            = "blob"
 	RouteNameBlobUpload      = "blob-upload"
 	RouteNameBlobUploadChunk = "blob-upload-chunk"
+	RouteNameCatalog         = "catalog"
+	RouteNameQuota           = "quota"
 )
 
 var allEndpoints = []string{
 	RouteNameManifest,
+	RouteNameSignatures,
 	RouteNameTags,
 	RouteNameBlob,
 	RouteNameBlobUpload,
 	RouteNameBlobUploadChunk,
+	RouteNameCatalog,
+	RouteNameQuota,
 }
 
 // Router builds a gorilla router with named routes for the various API
@@ -35,6 +35,11 @@ func TestRouter(t *testing.T) {
 			RequestURI: "/v2/",
 			Vars:       map[string]string{},
 		},
+		{
+			RouteName:  RouteNameCatalog,
+			RequestURI: "/v2/_catalog",
+			Vars:       map[string]string{},
+		},
 		{
 			RouteName:  RouteNameManifest,
 			RequestURI: "/v2/foo/manifests/bar",
@@ -59,6 +64,14 @@ func TestRouter(t *testing.T) {
 				"reference": "sha256:abcdef01234567890",
 			},
 		},
+		{
+			RouteName:  RouteNameSignatures,
+			RequestURI: "/v2/foo/bar/manifests/sha256:abcdef01234567890/signatures",
+			Vars: map[string]string{
+				"name":   "foo/bar",
+				"digest": "sha256:abcdef01234567890",
+			},
+		},
 		{
 			RouteName:  RouteNameTags,
 			RequestURI: "/v2/foo/bar/tags/list",
@@ -66,6 +79,13 @@ func TestRouter(t *testing.T) {
 				"name": "foo/bar",
 			},
 		},
+		{
+			RouteName:  RouteNameQuota,
+			RequestURI: "/v2/foo/bar/quota",
+			Vars: map[string]string{
+				"name": "foo/bar",
+			},
+		},
 		{
 			RouteName:  RouteNameBlob,
 			RequestURI: "/v2/foo/bar/blobs/tarsum.dev+foo:abcdef0919234",
@@ -100,8 +100,22 @@ func (ub *URLBuilder) BuildBaseURL() (string, error) {
 	return baseURL.String(), nil
 }
 
-// BuildTagsURL constructs a url to list the tags in the named repository.
-func (ub *URLBuilder) BuildTagsURL(name string) (string, error) {
+// BuildCatalogURL constructs a url get a catalog of repositories, with
+// optional pagination values.
+func (ub *URLBuilder) BuildCatalogURL(values ...url.Values) (string, error) {
+	route := ub.cloneRoute(RouteNameCatalog)
+
+	catalogURL, err := route.URL()
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(catalogURL, values...).String(), nil
+}
+
+// BuildTagsURL constructs a url to list the tags in the named repository,
+// with optional pagination values.
+func (ub *URLBuilder) BuildTagsURL(name string, values ...url.Values) (string, error) {
 	route := ub.cloneRoute(RouteNameTags)
 
 	tagsURL, err := route.URL("name", name)
@@ -109,7 +123,20 @@ func (ub *URLBuilder) BuildTagsURL(name string) (string, error) {
 		return "", err
 	}
 
-	return tagsURL.String(), nil
+	return appendValuesURL(tagsURL, values...).String(), nil
+}
+
+// BuildQuotaURL constructs a url to query the storage usage and quotas of
+// the repository identified by name.
+func (ub *URLBuilder) BuildQuotaURL(name string) (string, error) {
+	route := ub.cloneRoute(RouteNameQuota)
+
+	quotaURL, err := route.URL("name", name)
+	if err != nil {
+		return "", err
+	}
+
+	return quotaURL.String(), nil
 }
 
 // BuildManifestURL constructs a url for the manifest identified by name and
@@ -125,6 +152,19 @@ func (ub *URLBuilder) BuildManifestURL(name, reference string) (string, error) {
 	return manifestURL.String(), nil
 }
 
+// BuildSignaturesURL constructs a url for the detached signatures of the
+// manifest revision identified by name and dgst.
+func (ub *URLBuilder) BuildSignaturesURL(name string, dgst digest.Digest) (string, error) {
+	route := ub.cloneRoute(RouteNameSignatures)
+
+	signaturesURL, err := route.URL("name", name, "digest", dgst.String())
+	if err != nil {
+		return "", err
+	}
+
+	return signaturesURL.String(), nil
+}
+
 // BuildBlobURL constructs the url for the blob identified by name and dgst.
 func (ub *URLBuilder) BuildBlobURL(name string, dgst digest.Digest) (string, error) {
 	route := ub.cloneRoute(RouteNameBlob)
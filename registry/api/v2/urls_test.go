@@ -19,6 +19,23 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 			expectedPath: "/v2/",
 			build:        urlBuilder.BuildBaseURL,
 		},
+		{
+			description:  "test catalog url",
+			expectedPath: "/v2/_catalog",
+			build: func() (string, error) {
+				return urlBuilder.BuildCatalogURL()
+			},
+		},
+		{
+			description:  "test catalog url with pagination",
+			expectedPath: "/v2/_catalog?last=foo%2Fbar&n=10",
+			build: func() (string, error) {
+				return urlBuilder.BuildCatalogURL(url.Values{
+					"n":    []string{"10"},
+					"last": []string{"foo/bar"},
+				})
+			},
+		},
 		{
 			description:  "test tags url",
 			expectedPath: "/v2/foo/bar/tags/list",
@@ -26,6 +43,23 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 				return urlBuilder.BuildTagsURL("foo/bar")
 			},
 		},
+		{
+			description:  "test tags url with pagination",
+			expectedPath: "/v2/foo/bar/tags/list?last=v1&n=10",
+			build: func() (string, error) {
+				return urlBuilder.BuildTagsURL("foo/bar", url.Values{
+					"n":    []string{"10"},
+					"last": []string{"v1"},
+				})
+			},
+		},
+		{
+			description:  "test quota url",
+			expectedPath: "/v2/foo/bar/quota",
+			build: func() (string, error) {
+				return urlBuilder.BuildQuotaURL("foo/bar")
+			},
+		},
 		{
 			description:  "test manifest url",
 			expectedPath: "/v2/foo/bar/manifests/tag",
@@ -33,6 +67,13 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 				return urlBuilder.BuildManifestURL("foo/bar", "tag")
 			},
 		},
+		{
+			description:  "test signatures url",
+			expectedPath: "/v2/foo/bar/manifests/sha256:abcdef0123456789/signatures",
+			build: func() (string, error) {
+				return urlBuilder.BuildSignaturesURL("foo/bar", "sha256:abcdef0123456789")
+			},
+		},
 		{
 			description:  "build blob url",
 			expectedPath: "/v2/foo/bar/blobs/tarsum.v1+sha256:abcdef0123456789",
@@ -84,6 +84,13 @@ var (
 // given base endpoint
 // This endpoint should not include /v2/ or any part of the url after this.
 func New(endpoint string) (Client, error) {
+	return NewWithTransport(endpoint, nil)
+}
+
+// NewWithTransport returns a new Client which issues all requests through the
+// provided transport, allowing callers to add authorization or other
+// headers. If transport is nil, http.DefaultTransport is used.
+func NewWithTransport(endpoint string, transport http.RoundTripper) (Client, error) {
 	ub, err := v2.NewURLBuilderFromString(endpoint)
 	if err != nil {
 		return nil, err
@@ -92,6 +99,7 @@ func New(endpoint string) (Client, error) {
 	return &clientImpl{
 		endpoint: endpoint,
 		ub:       ub,
+		client:   &http.Client{Transport: transport},
 	}, nil
 }
 
@@ -99,6 +107,7 @@ func New(endpoint string) (Client, error) {
 type clientImpl struct {
 	endpoint string
 	ub       *v2.URLBuilder
+	client   *http.Client
 }
 
 // TODO(bbland): use consistent route generation between server and client
@@ -109,7 +118,7 @@ func (r *clientImpl) GetImageManifest(name, tag string) (*manifest.SignedManifes
 		return nil, err
 	}
 
-	response, err := http.Get(manifestURL)
+	response, err := r.client.Get(manifestURL)
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +164,7 @@ func (r *clientImpl) PutImageManifest(name, tag string, manifest *manifest.Signe
 		return err
 	}
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.client.Do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -190,7 +199,7 @@ func (r *clientImpl) DeleteImage(name, tag string) error {
 		return err
 	}
 
-	response, err := http.DefaultClient.Do(deleteRequest)
+	response, err := r.client.Do(deleteRequest)
 	if err != nil {
 		return err
 	}
@@ -223,7 +232,7 @@ func (r *clientImpl) ListImageTags(name string) ([]string, error) {
 		return nil, err
 	}
 
-	response, err := http.Get(tagsURL)
+	response, err := r.client.Get(tagsURL)
 	if err != nil {
 		return nil, err
 	}
@@ -266,7 +275,7 @@ func (r *clientImpl) BlobLength(name string, dgst digest.Digest) (int, error) {
 		return -1, err
 	}
 
-	response, err := http.Head(blobURL)
+	response, err := r.client.Head(blobURL)
 	if err != nil {
 		return -1, err
 	}
@@ -307,15 +316,17 @@ func (r *clientImpl) GetBlob(name string, dgst digest.Digest, byteOffset int) (i
 		return nil, 0, err
 	}
 
-	getRequest.Header.Add("Range", fmt.Sprintf("%d-", byteOffset))
-	response, err := http.DefaultClient.Do(getRequest)
+	if byteOffset > 0 {
+		getRequest.Header.Add("Range", fmt.Sprintf("bytes=%d-", byteOffset))
+	}
+	response, err := r.client.Do(getRequest)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// TODO(bbland): handle other status codes, like 5xx errors
 	switch {
-	case response.StatusCode == http.StatusOK:
+	case response.StatusCode == http.StatusOK, response.StatusCode == http.StatusPartialContent:
 		lengthHeader := response.Header.Get("Content-Length")
 		length, err := strconv.ParseInt(lengthHeader, 10, 0)
 		if err != nil {
@@ -350,7 +361,7 @@ func (r *clientImpl) InitiateBlobUpload(name string) (string, error) {
 		return "", err
 	}
 
-	response, err := http.DefaultClient.Do(postRequest)
+	response, err := r.client.Do(postRequest)
 	if err != nil {
 		return "", err
 	}
@@ -376,7 +387,7 @@ func (r *clientImpl) InitiateBlobUpload(name string) (string, error) {
 }
 
 func (r *clientImpl) GetBlobUploadStatus(location string) (int, int, error) {
-	response, err := http.Get(location)
+	response, err := r.client.Get(location)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -416,7 +427,7 @@ func (r *clientImpl) UploadBlob(location string, blob io.ReadCloser, length int,
 	putRequest.Header.Set("Content-Type", "application/octet-stream")
 	putRequest.Header.Set("Content-Length", fmt.Sprint(length))
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.client.Do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -456,7 +467,7 @@ func (r *clientImpl) UploadBlobChunk(location string, blobChunk io.ReadCloser, l
 	putRequest.Header.Set("Content-Range",
 		fmt.Sprintf("%d-%d/%d", startByte, endByte, endByte))
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.client.Do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -506,7 +517,7 @@ func (r *clientImpl) FinishChunkedBlobUpload(location string, length int, dgst d
 	putRequest.Header.Set("Content-Range",
 		fmt.Sprintf("%d-%d/%d", length, length, length))
 
-	response, err := http.DefaultClient.Do(putRequest)
+	response, err := r.client.Do(putRequest)
 	if err != nil {
 		return err
 	}
@@ -537,7 +548,7 @@ func (r *clientImpl) CancelBlobUpload(location string) error {
 		return err
 	}
 
-	response, err := http.DefaultClient.Do(deleteRequest)
+	response, err := r.client.Do(deleteRequest)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+)
+
+// adminFunc serves an authorized request to an administrative endpoint.
+type adminFunc func(context *Context, w http.ResponseWriter, r *http.Request)
+
+// adminHandler returns a handler for an administrative endpoint, such as
+// those served on the debug server. Requests with a method other than those
+// listed are refused. Like the API routes, the request is checked against
+// the access controller, with the access records returned by access, and
+// writes are rejected while the registry is in read-only mode.
+// 管理接口的认证和只读检查
+func (app *App) adminHandler(methods []string, access func(r *http.Request) []auth.Access, serve adminFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		allowed := false
+		for _, method := range methods {
+			allowed = allowed || r.Method == method
+		}
+
+		if !allowed {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := defaultContextManager.context(app, w, r)
+		defer defaultContextManager.release(ctx)
+
+		context := app.context(w, r)
+		if err := app.authorizeAccess(w, r, context, access(r)...); err != nil {
+			ctxu.GetLogger(context).Errorf("error authorizing admin request: %v", err)
+			return
+		}
+
+		// Add username to request logging
+		context.Context = ctxu.WithLogger(context.Context, ctxu.GetLogger(context.Context, "auth.user.name"))
+
+		if app.rejectReadOnly(context, w, r) {
+			return
+		}
+
+		serve(context, w, r)
+	})
+}
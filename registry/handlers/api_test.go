@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,11 +17,17 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/registry/api/v2"
+	_ "github.com/docker/distribution/registry/middleware/repository/immutabletags"
+	"github.com/docker/distribution/registry/storage"
 	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/docker/distribution/testutil"
 	"github.com/docker/libtrust"
@@ -312,6 +320,175 @@ func TestBlobAPI(t *testing.T) {
 	//       ensure the content remains uncorrupted.
 }
 
+func TestMonolithicBlobUpload(t *testing.T) {
+	env := newTestEnv(t)
+	imageName := "foo/bar"
+
+	content := []byte(`{"architecture": "amd64", "os": "linux"}`)
+	dgst, err := digest.FromBytes(content)
+	checkErr(t, err, "digesting blob")
+
+	postBlob := func(msg string, dgst string, body []byte) *http.Response {
+		uploadURL, err := env.builder.BuildBlobUploadURL(imageName, url.Values{
+			"digest": []string{dgst},
+		})
+		checkErr(t, err, "building upload url")
+
+		resp, err := http.Post(uploadURL, "application/octet-stream", bytes.NewReader(body))
+		checkErr(t, err, msg)
+		return resp
+	}
+
+	resp := postBlob("uploading blob in a single request", dgst.String(), content)
+	defer resp.Body.Close()
+	checkResponse(t, "uploading blob in a single request", resp, http.StatusCreated)
+
+	blobURL, err := env.builder.BuildBlobURL(imageName, dgst)
+	checkErr(t, err, "building blob url")
+
+	checkHeaders(t, resp, http.Header{
+		"Location":              []string{blobURL},
+		"Content-Length":        []string{"0"},
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
+	resp, err = http.Get(blobURL)
+	checkErr(t, err, "fetching blob")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching blob", resp, http.StatusOK)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	checkErr(t, err, "reading blob")
+	if !bytes.Equal(body, content) {
+		t.Fatalf("unexpected blob content: %q != %q", body, content)
+	}
+
+	// Content not matching the digest is refused.
+	resp = postBlob("uploading blob with mismatched digest", dgst.String(), []byte("other content"))
+	defer resp.Body.Close()
+	checkResponse(t, "uploading blob with mismatched digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "uploading blob with mismatched digest", resp, v2.ErrorCodeDigestInvalid)
+
+	resp = postBlob("uploading blob with invalid digest", "sha256:invalid", content)
+	defer resp.Body.Close()
+	checkResponse(t, "uploading blob with invalid digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "uploading blob with invalid digest", resp, v2.ErrorCodeDigestInvalid)
+}
+
+func TestBlobDelete(t *testing.T) {
+	env := newTestEnv(t)
+
+	imageName := "foo/bar"
+	layerFile, tarSumStr, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("error creating random layer file: %v", err)
+	}
+
+	layerDigest := digest.Digest(tarSumStr)
+
+	layerURL, err := env.builder.BuildBlobURL(imageName, layerDigest)
+	if err != nil {
+		t.Fatalf("error building url: %v", err)
+	}
+
+	// -----------------------------------
+	// Deleting non-existent content fails
+	resp, err := httpDelete(layerURL)
+	checkErr(t, err, "deleting non-existent layer")
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting non-existent layer", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "deleting non-existent layer", resp, v2.ErrorCodeBlobUnknown)
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+	pushLayer(t, env.builder, imageName, layerDigest, uploadURLBase, layerFile)
+
+	// -----------------------------------
+	// Delete the layer
+	resp, err = httpDelete(layerURL)
+	checkErr(t, err, "deleting layer")
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting layer", resp, http.StatusAccepted)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length": []string{"0"},
+	})
+
+	resp, err = http.Head(layerURL)
+	checkErr(t, err, "checking head on deleted layer")
+	defer resp.Body.Close()
+
+	checkResponse(t, "checking head on deleted layer", resp, http.StatusNotFound)
+}
+
+func TestBlobMount(t *testing.T) {
+	env := newTestEnv(t)
+
+	sourceName := "foo/source"
+	targetName := "foo/target"
+
+	layerFile, tarSumStr, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("error creating random layer file: %v", err)
+	}
+	layerDigest := digest.Digest(tarSumStr)
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, sourceName)
+	pushLayer(t, env.builder, sourceName, layerDigest, uploadURLBase, layerFile)
+
+	// --------------------------------------------------
+	// Mounting from a repository without the blob falls back to an upload
+	mountURL, err := env.builder.BuildBlobUploadURL(targetName, url.Values{
+		"mount": []string{layerDigest.String()},
+		"from":  []string{"foo/unknown"},
+	})
+	checkErr(t, err, "building mount url")
+
+	resp, err := http.Post(mountURL, "", nil)
+	checkErr(t, err, "mounting unknown blob")
+	defer resp.Body.Close()
+
+	checkResponse(t, "mounting unknown blob", resp, http.StatusAccepted)
+	if resp.Header.Get("Docker-Upload-UUID") == "" {
+		t.Fatalf("expected an upload session when mount is not possible")
+	}
+
+	// -------------------------------
+	// Mount the blob from the source
+	mountURL, err = env.builder.BuildBlobUploadURL(targetName, url.Values{
+		"mount": []string{layerDigest.String()},
+		"from":  []string{sourceName},
+	})
+	checkErr(t, err, "building mount url")
+
+	resp, err = http.Post(mountURL, "", nil)
+	checkErr(t, err, "mounting blob")
+	defer resp.Body.Close()
+
+	checkResponse(t, "mounting blob", resp, http.StatusCreated)
+
+	// The response refers to the blob by its canonical digest.
+	canonical, err := digest.ParseDigest(resp.Header.Get("Docker-Content-Digest"))
+	checkErr(t, err, "parsing mounted blob digest")
+
+	canonicalURL, err := env.builder.BuildBlobURL(targetName, canonical)
+	checkErr(t, err, "building blob url")
+
+	checkHeaders(t, resp, http.Header{
+		"Location":       []string{canonicalURL},
+		"Content-Length": []string{"0"},
+	})
+
+	layerURL, err := env.builder.BuildBlobURL(targetName, layerDigest)
+	checkErr(t, err, "building blob url")
+
+	resp, err = http.Head(layerURL)
+	checkErr(t, err, "checking head on mounted layer")
+	defer resp.Body.Close()
+
+	checkResponse(t, "checking head on mounted layer", resp, http.StatusOK)
+}
+
 func TestManifestAPI(t *testing.T) {
 	env := newTestEnv(t)
 
@@ -437,6 +614,23 @@ func TestManifestAPI(t *testing.T) {
 		"Docker-Content-Digest": []string{dgst.String()},
 	})
 
+	// --------------------
+	// Schema1 clients label the manifest with the signed media type or
+	// with whatever their tooling defaults to.
+	for _, contentType := range []string{manifest.ManifestSignedMediaType, "application/x-www-form-urlencoded"} {
+		req, err := http.NewRequest("PUT", manifestURL, bytes.NewReader(signedManifest.Raw))
+		checkErr(t, err, "creating put request")
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err = http.DefaultClient.Do(req)
+		checkErr(t, err, "putting signed manifest with content type "+contentType)
+		checkResponse(t, "putting signed manifest with content type "+contentType, resp, http.StatusAccepted)
+		checkHeaders(t, resp, http.Header{
+			"Location":              []string{manifestDigestURL},
+			"Docker-Content-Digest": []string{dgst.String()},
+		})
+	}
+
 	// ------------------
 	// Fetch by tag name
 	resp, err = http.Get(manifestURL)
@@ -509,76 +703,1313 @@ func TestManifestAPI(t *testing.T) {
 	if tagsResponse.Tags[0] != tag {
 		t.Fatalf("tag not as expected: %q != %q", tagsResponse.Tags[0], tag)
 	}
-}
 
-type testEnv struct {
-	pk      libtrust.PrivateKey
-	ctx     context.Context
-	config  configuration.Configuration
-	app     *App
-	server  *httptest.Server
-	builder *v2.URLBuilder
+	// ------------------
+	// Delete by tag only removes the tag
+	resp, err = httpDelete(manifestURL)
+	checkErr(t, err, "deleting tag")
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting tag", resp, http.StatusAccepted)
+
+	resp, err = http.Get(manifestURL)
+	checkErr(t, err, "fetching manifest by deleted tag")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching manifest by deleted tag", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "fetching manifest by deleted tag", resp, v2.ErrorCodeManifestUnknown)
+
+	resp, err = http.Get(manifestDigestURL)
+	checkErr(t, err, "fetching untagged manifest by digest")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching untagged manifest by digest", resp, http.StatusOK)
+
+	resp, err = httpDelete(manifestURL)
+	checkErr(t, err, "deleting unknown tag")
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting unknown tag", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "deleting unknown tag", resp, v2.ErrorCodeManifestUnknown)
+
+	// ------------------
+	// Delete by digest
+	resp, err = httpDelete(manifestDigestURL)
+	checkErr(t, err, "deleting manifest by digest")
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting manifest by digest", resp, http.StatusAccepted)
+
+	// The manifest should no longer be available by digest or tag.
+	resp, err = http.Get(manifestDigestURL)
+	checkErr(t, err, "fetching deleted manifest by digest")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching deleted manifest by digest", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "fetching deleted manifest by digest", resp, v2.ErrorCodeManifestUnknown)
+
+	resp, err = http.Get(manifestURL)
+	checkErr(t, err, "fetching deleted manifest by tag")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching deleted manifest by tag", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "fetching deleted manifest by tag", resp, v2.ErrorCodeManifestUnknown)
+
+	// Deleting again should report an unknown manifest.
+	resp, err = httpDelete(manifestDigestURL)
+	checkErr(t, err, "deleting unknown manifest")
+	defer resp.Body.Close()
+
+	checkResponse(t, "deleting unknown manifest", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "deleting unknown manifest", resp, v2.ErrorCodeManifestUnknown)
 }
 
-func newTestEnv(t *testing.T) *testEnv {
-	config := configuration.Configuration{
-		Storage: configuration.Storage{
-			"inmemory": configuration.Parameters{},
-		},
+// TestCatalogAPI pushes a few repositories and ensures that the catalog
+// endpoint lists them, following the Link header across pages.
+// TestSchema2ManifestAPI pushes a schema2 manifest and checks that it is
+// only served to clients accepting its media type.
+func TestSchema2ManifestAPI(t *testing.T) {
+	env := newTestEnv(t)
+	imageName := "foo/schema2"
+	tag := "thetag"
+
+	// Push the config and a layer, using their canonical digests.
+	var references []schema2.Descriptor
+	for _, content := range [][]byte{[]byte(`{"architecture": "amd64"}`), []byte("not really a layer")} {
+		dgst, err := digest.FromBytes(content)
+		checkErr(t, err, "digesting content")
+
+		uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+		pushLayer(t, env.builder, imageName, dgst, uploadURLBase, bytes.NewReader(content))
+
+		references = append(references, schema2.Descriptor{
+			Size:   int64(len(content)),
+			Digest: dgst,
+		})
 	}
 
-	return newTestEnvWithConfig(t, &config)
+	references[0].MediaType = schema2.MediaTypeConfig
+	references[1].MediaType = schema2.MediaTypeLayer
+
+	dm, err := schema2.FromStruct(schema2.Manifest{
+		SchemaVersion: schema2.SchemaVersion,
+		MediaType:     schema2.MediaTypeManifest,
+		Config:        references[0],
+		Layers:        references[1:],
+	})
+	checkErr(t, err, "creating schema2 manifest")
+
+	desc, err := dm.Descriptor()
+	checkErr(t, err, "getting manifest descriptor")
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, tag)
+	checkErr(t, err, "building manifest url")
+
+	manifestDigestURL, err := env.builder.BuildManifestURL(imageName, desc.Digest.String())
+	checkErr(t, err, "building manifest url")
+
+	// Without the schema2 content type, the manifest is read as schema1.
+	resp := putManifest(t, "putting schema2 manifest without content type", manifestURL, dm)
+	defer resp.Body.Close()
+	checkResponse(t, "putting schema2 manifest without content type", resp, http.StatusBadRequest)
+
+	req, err := http.NewRequest("PUT", manifestURL, bytes.NewReader(dm.Content()))
+	checkErr(t, err, "creating put request")
+	req.Header.Set("Content-Type", schema2.MediaTypeManifest)
+
+	resp, err = http.DefaultClient.Do(req)
+	checkErr(t, err, "putting schema2 manifest")
+	defer resp.Body.Close()
+
+	checkResponse(t, "putting schema2 manifest", resp, http.StatusAccepted)
+	checkHeaders(t, resp, http.Header{
+		"Location":              []string{manifestDigestURL},
+		"Docker-Content-Digest": []string{desc.Digest.String()},
+	})
+
+	for _, u := range []string{manifestURL, manifestDigestURL} {
+		// Clients that don't accept schema2 can't fetch the manifest.
+		resp, err = http.Get(u)
+		checkErr(t, err, "fetching schema2 manifest without accept header")
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching schema2 manifest without accept header", resp, http.StatusNotAcceptable)
+		checkBodyHasErrorCodes(t, "fetching schema2 manifest without accept header", resp, v2.ErrorCodeManifestNotAcceptable)
+
+		req, err = http.NewRequest("GET", u, nil)
+		checkErr(t, err, "creating get request")
+		req.Header.Set("Accept", manifest.ManifestMediaType+", "+schema2.MediaTypeManifest)
+
+		resp, err = http.DefaultClient.Do(req)
+		checkErr(t, err, "fetching schema2 manifest")
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching schema2 manifest", resp, http.StatusOK)
+		checkHeaders(t, resp, http.Header{
+			"Content-Type":          []string{schema2.MediaTypeManifest},
+			"Docker-Content-Digest": []string{desc.Digest.String()},
+		})
+
+		p, err := ioutil.ReadAll(resp.Body)
+		checkErr(t, err, "reading manifest")
+
+		if !bytes.Equal(p, dm.Content()) {
+			t.Fatalf("manifests do not match: %q != %q", p, dm.Content())
+		}
+	}
 }
 
-func newTestEnvWithConfig(t *testing.T, config *configuration.Configuration) *testEnv {
-	ctx := context.Background()
+// TestManifestListAPI pushes a manifest list referencing a platform specific
+// manifest and fetches it back by tag.
+func TestManifestListAPI(t *testing.T) {
+	env := newTestEnv(t)
+	imageName := "foo/multiarch"
+	tag := "latest"
 
-	app := NewApp(ctx, *config)
-	server := httptest.NewServer(handlers.CombinedLoggingHandler(os.Stderr, app))
-	builder, err := v2.NewURLBuilderFromString(server.URL + config.HTTP.Prefix)
+	sm := createRepository(env, t, imageName, "amd64")
+	desc, err := sm.Descriptor()
+	checkErr(t, err, "getting manifest descriptor")
 
-	if err != nil {
-		t.Fatalf("error creating url builder: %v", err)
+	amd64 := manifestlist.ManifestDescriptor{
+		Descriptor: schema2.NewDescriptor(desc),
+		Platform:   manifestlist.PlatformSpec{Architecture: "amd64", OS: "linux"},
 	}
 
-	pk, err := libtrust.GenerateECP256PrivateKey()
-	if err != nil {
-		t.Fatalf("unexpected error generating private key: %v", err)
+	manifestURL, err := env.builder.BuildManifestURL(imageName, tag)
+	checkErr(t, err, "building manifest url")
+
+	putManifestList := func(msg string, dml *manifestlist.DeserializedManifestList) *http.Response {
+		req, err := http.NewRequest("PUT", manifestURL, bytes.NewReader(dml.Content()))
+		checkErr(t, err, "creating put request")
+		req.Header.Set("Content-Type", manifestlist.MediaTypeManifestList)
+
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(t, err, msg)
+		return resp
 	}
 
-	return &testEnv{
-		pk:      pk,
-		ctx:     ctx,
-		config:  *config,
-		app:     app,
-		server:  server,
-		builder: builder,
+	// Referencing a manifest that is not in the repository fails.
+	unknownDigest, err := digest.FromBytes([]byte("not a manifest"))
+	checkErr(t, err, "digesting content")
+
+	arm64 := manifestlist.ManifestDescriptor{
+		Descriptor: schema2.Descriptor{MediaType: manifest.ManifestMediaType, Digest: unknownDigest},
+		Platform:   manifestlist.PlatformSpec{Architecture: "arm64", OS: "linux"},
 	}
-}
 
-func putManifest(t *testing.T, msg, url string, v interface{}) *http.Response {
-	var body []byte
-	if sm, ok := v.(*manifest.SignedManifest); ok {
-		body = sm.Raw
-	} else {
-		var err error
-		body, err = json.MarshalIndent(v, "", "   ")
-		if err != nil {
-			t.Fatalf("unexpected error marshaling %v: %v", v, err)
-		}
+	invalid, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{amd64, arm64})
+	checkErr(t, err, "creating manifest list")
+
+	resp := putManifestList("putting manifest list with unknown manifest", invalid)
+	defer resp.Body.Close()
+	checkResponse(t, "putting manifest list with unknown manifest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "putting manifest list with unknown manifest", resp, v2.ErrorCodeManifestUnknown)
+
+	dml, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{amd64})
+	checkErr(t, err, "creating manifest list")
+
+	listDesc, err := dml.Descriptor()
+	checkErr(t, err, "getting manifest list descriptor")
+
+	resp = putManifestList("putting manifest list", dml)
+	defer resp.Body.Close()
+	checkResponse(t, "putting manifest list", resp, http.StatusAccepted)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Digest": []string{listDesc.Digest.String()},
+	})
+
+	// Clients that don't accept manifest lists can't fetch it.
+	resp, err = http.Get(manifestURL)
+	checkErr(t, err, "fetching manifest list without accept header")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching manifest list without accept header", resp, http.StatusNotAcceptable)
+	checkBodyHasErrorCodes(t, "fetching manifest list without accept header", resp, v2.ErrorCodeManifestNotAcceptable)
+
+	req, err := http.NewRequest("GET", manifestURL, nil)
+	checkErr(t, err, "creating get request")
+	req.Header.Set("Accept", manifestlist.MediaTypeManifestList)
+
+	resp, err = http.DefaultClient.Do(req)
+	checkErr(t, err, "fetching manifest list")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching manifest list", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Type":          []string{manifestlist.MediaTypeManifestList},
+		"Docker-Content-Digest": []string{listDesc.Digest.String()},
+	})
+
+	var fetched manifestlist.DeserializedManifestList
+	if err := json.NewDecoder(resp.Body).Decode(&fetched); err != nil {
+		t.Fatalf("error decoding fetched manifest list: %v", err)
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewReader(body))
-	if err != nil {
-		t.Fatalf("error creating request for %s: %v", msg, err)
+	if len(fetched.Manifests) != 1 || fetched.Manifests[0].Digest != desc.Digest || fetched.Manifests[0].Platform != amd64.Platform {
+		t.Fatalf("unexpected manifest list: %#v", fetched.ManifestList)
 	}
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		t.Fatalf("error doing put request while %s: %v", msg, err)
+// TestReadOnlyMode checks that writes are rejected while the registry is in
+// read-only mode, and that the mode can be toggled at runtime.
+func TestReadOnlyMode(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"maintenance": configuration.Parameters{
+				"readonly": map[interface{}]interface{}{
+					"enabled": true,
+				},
+			},
+		},
 	}
 
-	return resp
+	env := newTestEnvWithConfig(t, &config)
+	imageName := "foo/bar"
+
+	layerUploadURL, err := env.builder.BuildBlobUploadURL(imageName)
+	checkErr(t, err, "building upload url")
+
+	resp, err := http.Post(layerUploadURL, "", nil)
+	checkErr(t, err, "starting layer upload")
+	defer resp.Body.Close()
+
+	checkResponse(t, "starting layer upload in read-only mode", resp, http.StatusServiceUnavailable)
+	checkHeaders(t, resp, http.Header{
+		"Retry-After": []string{"300"},
+	})
+	checkBodyHasErrorCodes(t, "starting layer upload in read-only mode", resp, v2.ErrorCodeReadOnly)
+
+	// Reads are still served.
+	tagsURL, err := env.builder.BuildTagsURL(imageName)
+	checkErr(t, err, "building tags url")
+
+	resp, err = http.Get(tagsURL)
+	checkErr(t, err, "fetching tags")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching tags in read-only mode", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "fetching tags in read-only mode", resp, v2.ErrorCodeNameUnknown)
+
+	// Turn read-only mode off through the debug handler.
+	debugServer := httptest.NewServer(env.app.ReadOnlyHandler())
+	defer debugServer.Close()
+
+	req, err := http.NewRequest("PUT", debugServer.URL+"?enabled=false", nil)
+	checkErr(t, err, "creating read-only toggle request")
+
+	resp, err = http.DefaultClient.Do(req)
+	checkErr(t, err, "toggling read-only mode")
+	defer resp.Body.Close()
+	checkResponse(t, "toggling read-only mode", resp, http.StatusOK)
+
+	var state struct {
+		ReadOnly bool `json:"readonly"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatalf("error decoding read-only state: %v", err)
+	}
+
+	if state.ReadOnly || env.app.ReadOnly() {
+		t.Fatalf("expected read-only mode to be disabled")
+	}
+
+	resp, err = http.Post(layerUploadURL, "", nil)
+	checkErr(t, err, "starting layer upload")
+	defer resp.Body.Close()
+	checkResponse(t, "starting layer upload", resp, http.StatusAccepted)
+}
+
+func TestQuotaAPI(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Quotas: []configuration.Quota{
+			{Repository: "foo/*", Limit: 100},
+		},
+	}
+
+	env := newTestEnvWithConfig(t, &config)
+
+	content := bytes.Repeat([]byte("a"), 60)
+	dgst, err := digest.FromBytes(content)
+	checkErr(t, err, "digesting layer")
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, "foo/bar")
+	pushLayer(t, env.builder, "foo/bar", dgst, uploadURLBase, bytes.NewReader(content))
+
+	// The blob counts again when mounted into another repository matching
+	// the quota.
+	layerUploadURL, err := env.builder.BuildBlobUploadURL("foo/baz", url.Values{
+		"mount": []string{dgst.String()},
+		"from":  []string{"foo/bar"},
+	})
+	checkErr(t, err, "building upload url")
+
+	resp, err := http.Post(layerUploadURL, "", nil)
+	checkErr(t, err, "mounting layer")
+	defer resp.Body.Close()
+	checkResponse(t, "mounting layer over quota", resp, http.StatusInsufficientStorage)
+	checkBodyHasErrorCodes(t, "mounting layer over quota", resp, v2.ErrorCodeQuotaExceeded)
+
+	content = bytes.Repeat([]byte("b"), 50)
+	dgst, err = digest.FromBytes(content)
+	checkErr(t, err, "digesting layer")
+
+	uploadURLBase, _ = startPushLayer(t, env.builder, "foo/baz")
+	resp, err = doPushLayer(t, env.builder, "foo/baz", dgst, uploadURLBase, bytes.NewReader(content))
+	checkErr(t, err, "pushing layer")
+	defer resp.Body.Close()
+	checkResponse(t, "pushing layer over quota", resp, http.StatusInsufficientStorage)
+	checkBodyHasErrorCodes(t, "pushing layer over quota", resp, v2.ErrorCodeQuotaExceeded)
+
+	quotaURL, err := env.builder.BuildQuotaURL("foo/baz")
+	checkErr(t, err, "building quota url")
+
+	resp, err = http.Get(quotaURL)
+	checkErr(t, err, "fetching quota")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching quota", resp, http.StatusOK)
+
+	var usage quotaAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		t.Fatalf("error decoding quota response: %v", err)
+	}
+
+	expected := quotaAPIResponse{
+		Name: "foo/baz",
+		Used: 0,
+		Quotas: []quotaUsageAPIResponse{
+			{Pattern: "foo/*", Limit: 100, Used: 60},
+		},
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Fatalf("unexpected quota response: %#v != %#v", usage, expected)
+	}
+}
+
+func TestDigestAlgorithmsAPI(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		DigestAlgorithms: []configuration.DigestAlgorithm{
+			{Repository: "secure/*", Algorithm: "sha512"},
+		},
+	}
+
+	env := newTestEnvWithConfig(t, &config)
+
+	content := bytes.Repeat([]byte("a"), 64)
+	digestOf := func(alg string) digest.Digest {
+		digester, err := digest.NewDigesterForAlgorithm(alg)
+		checkErr(t, err, "creating digester")
+		digester.Write(content)
+		return digester.Digest()
+	}
+
+	for _, testcase := range []struct {
+		name     string
+		provided digest.Digest
+		expected digest.Digest
+	}{
+		{name: "secure/app", provided: digestOf("sha512"), expected: digestOf("sha512")},
+		{name: "foo/bar", provided: digestOf("sha512"), expected: digestOf("sha256")},
+	} {
+		uploadURLBase, _ := startPushLayer(t, env.builder, testcase.name)
+		resp, err := doPushLayer(t, env.builder, testcase.name, testcase.provided, uploadURLBase, bytes.NewReader(content))
+		checkErr(t, err, "pushing layer")
+		defer resp.Body.Close()
+		checkResponse(t, "pushing layer with "+testcase.provided.Algorithm(), resp, http.StatusCreated)
+
+		if dgst := resp.Header.Get("Docker-Content-Digest"); dgst != testcase.expected.String() {
+			t.Fatalf("unexpected digest for %s: %s != %s", testcase.name, dgst, testcase.expected)
+		}
+
+		// The layer is available under the provided digest.
+		layerURL, err := env.builder.BuildBlobURL(testcase.name, testcase.provided)
+		checkErr(t, err, "building layer url")
+
+		resp, err = http.Get(layerURL)
+		checkErr(t, err, "fetching layer")
+		defer resp.Body.Close()
+		checkResponse(t, "fetching layer by "+testcase.provided.Algorithm(), resp, http.StatusOK)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		checkErr(t, err, "reading layer")
+		if !bytes.Equal(body, content) {
+			t.Fatalf("unexpected layer content for %s", testcase.name)
+		}
+	}
+}
+
+func TestImmutableTagsAPI(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Middleware: map[string][]configuration.Middleware{
+			"repository": {
+				{
+					Name: "immutabletags",
+					Options: configuration.Parameters{
+						"tags": []interface{}{"v*"},
+					},
+				},
+			},
+		},
+	}
+
+	env := newTestEnvWithConfig(t, &config)
+	imageName := "foo/bar"
+
+	createRepository(env, t, imageName, "v1")
+	latest := createRepository(env, t, imageName, "latest")
+
+	// Moving the immutable tag to the revision of latest is refused.
+	unsignedManifest := latest.Manifest
+	unsignedManifest.Tag = "v1"
+	signedManifest, err := manifest.Sign(&unsignedManifest, env.pk)
+	checkErr(t, err, "signing manifest")
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, "v1")
+	checkErr(t, err, "building manifest url")
+
+	resp := putManifest(t, "moving immutable tag", manifestURL, signedManifest)
+	defer resp.Body.Close()
+	checkResponse(t, "moving immutable tag", resp, http.StatusConflict)
+	checkBodyHasErrorCodes(t, "moving immutable tag", resp, v2.ErrorCodeTagImmutable)
+
+	resp, err = httpDelete(manifestURL)
+	checkErr(t, err, "deleting immutable tag")
+	defer resp.Body.Close()
+	checkResponse(t, "deleting immutable tag", resp, http.StatusConflict)
+	checkBodyHasErrorCodes(t, "deleting immutable tag", resp, v2.ErrorCodeTagImmutable)
+}
+
+func TestManifestTrustAPI(t *testing.T) {
+	rootKey, err := libtrust.GenerateECP256PrivateKey()
+	checkErr(t, err, "generating root key")
+
+	rootCert, err := libtrust.GenerateCACert(rootKey, rootKey)
+	checkErr(t, err, "generating root certificate")
+
+	bundle, err := ioutil.TempFile("", "trust-bundle")
+	checkErr(t, err, "creating root certificate bundle")
+	defer os.Remove(bundle.Name())
+
+	err = pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: rootCert.Raw})
+	checkErr(t, err, "writing root certificate bundle")
+	bundle.Close()
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Trust: []configuration.Trust{
+			{Repository: "prod/*", RootCertBundles: []string{bundle.Name()}},
+		},
+	}
+
+	env := newTestEnvWithConfig(t, &config)
+	imageName := "prod/app"
+
+	unsignedManifest := &manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: imageName,
+		Tag:  "latest",
+	}
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, "latest")
+	checkErr(t, err, "building manifest url")
+
+	// A signature without a certificate chain is not trusted.
+	signedManifest, err := manifest.Sign(unsignedManifest, env.pk)
+	checkErr(t, err, "signing manifest")
+
+	resp := putManifest(t, "putting untrusted manifest", manifestURL, signedManifest)
+	defer resp.Body.Close()
+	checkResponse(t, "putting untrusted manifest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "putting untrusted manifest", resp, v2.ErrorCodeManifestUnverified)
+
+	signingCert, err := libtrust.GenerateCACert(rootKey, env.pk.PublicKey())
+	checkErr(t, err, "generating signing certificate")
+
+	signedManifest, err = manifest.SignWithChain(unsignedManifest, env.pk, []*x509.Certificate{signingCert})
+	checkErr(t, err, "signing manifest with chain")
+
+	resp = putManifest(t, "putting trusted manifest", manifestURL, signedManifest)
+	defer resp.Body.Close()
+	checkResponse(t, "putting trusted manifest", resp, http.StatusAccepted)
+}
+
+func TestManifestSignaturesAPI(t *testing.T) {
+	env := newTestEnv(t)
+	imageName := "foo/bar"
+
+	signedManifest := createRepository(env, t, imageName, "latest")
+
+	payload, err := signedManifest.Payload()
+	checkErr(t, err, "getting manifest payload")
+
+	dgst, err := digest.FromBytes(payload)
+	checkErr(t, err, "digesting manifest payload")
+
+	signaturesURL, err := env.builder.BuildSignaturesURL(imageName, dgst)
+	checkErr(t, err, "building signatures url")
+
+	getSignatures := func(expected int) {
+		resp, err := http.Get(signaturesURL)
+		checkErr(t, err, "fetching signatures")
+		defer resp.Body.Close()
+		checkResponse(t, "fetching signatures", resp, http.StatusOK)
+		checkHeaders(t, resp, http.Header{
+			"Docker-Content-Digest": []string{dgst.String()},
+		})
+
+		var response signaturesAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("error decoding signatures response: %v", err)
+		}
+
+		if response.Name != imageName || response.Digest != dgst || len(response.Signatures) != expected {
+			t.Fatalf("unexpected signatures response: %#v", response)
+		}
+	}
+
+	putSignatures := func(msg, url string, signatures [][]byte) *http.Response {
+		var request signaturesAPIRequest
+		for _, signature := range signatures {
+			request.Signatures = append(request.Signatures, json.RawMessage(signature))
+		}
+
+		body, err := json.Marshal(request)
+		checkErr(t, err, "marshaling signatures")
+
+		req, err := http.NewRequest("PUT", url, bytes.NewReader(body))
+		checkErr(t, err, "creating signatures request")
+
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(t, err, msg)
+		return resp
+	}
+
+	getSignatures(1)
+
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	checkErr(t, err, "generating key")
+
+	otherSigned, err := manifest.Sign(&signedManifest.Manifest, otherKey)
+	checkErr(t, err, "signing manifest")
+
+	otherSignatures, err := otherSigned.Signatures()
+	checkErr(t, err, "getting signatures")
+
+	resp := putSignatures("attaching signatures", signaturesURL, otherSignatures)
+	defer resp.Body.Close()
+	checkResponse(t, "attaching signatures", resp, http.StatusCreated)
+	checkHeaders(t, resp, http.Header{
+		"Location":              []string{signaturesURL},
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
+	getSignatures(2)
+
+	// The attached signature is served along with the manifest.
+	manifestURL, err := env.builder.BuildManifestURL(imageName, dgst.String())
+	checkErr(t, err, "building manifest url")
+
+	resp, err = http.Get(manifestURL)
+	checkErr(t, err, "fetching manifest")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching manifest", resp, http.StatusOK)
+
+	var fetched manifest.SignedManifest
+	if err := json.NewDecoder(resp.Body).Decode(&fetched); err != nil {
+		t.Fatalf("error decoding fetched manifest: %v", err)
+	}
+
+	keys, err := manifest.Verify(&fetched)
+	checkErr(t, err, "verifying fetched manifest")
+	if len(keys) != 2 {
+		t.Fatalf("unexpected number of signing keys: %d != 2", len(keys))
+	}
+
+	// A signature over another payload does not verify.
+	unrelated := signedManifest.Manifest
+	unrelated.Tag = "other"
+	unrelatedSigned, err := manifest.Sign(&unrelated, otherKey)
+	checkErr(t, err, "signing manifest")
+
+	unrelatedSignatures, err := unrelatedSigned.Signatures()
+	checkErr(t, err, "getting signatures")
+
+	resp = putSignatures("attaching unrelated signatures", signaturesURL, unrelatedSignatures)
+	defer resp.Body.Close()
+	checkResponse(t, "attaching unrelated signatures", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "attaching unrelated signatures", resp, v2.ErrorCodeManifestUnverified)
+
+	getSignatures(2)
+
+	unknownURL, err := env.builder.BuildSignaturesURL(imageName, digest.Digest("sha256:"+strings.Repeat("0", 64)))
+	checkErr(t, err, "building signatures url")
+
+	resp = putSignatures("attaching signatures to unknown manifest", unknownURL, otherSignatures)
+	defer resp.Body.Close()
+	checkResponse(t, "attaching signatures to unknown manifest", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "attaching signatures to unknown manifest", resp, v2.ErrorCodeManifestUnknown)
+}
+
+// recordingSink keeps the events written to it.
+type recordingSink struct {
+	events []notifications.Event
+}
+
+func (rs *recordingSink) Write(events ...notifications.Event) error {
+	rs.events = append(rs.events, events...)
+	return nil
+}
+
+func (rs *recordingSink) Close() error {
+	return nil
+}
+
+func TestTagPruning(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Middleware: map[string][]configuration.Middleware{
+			"repository": {
+				{
+					Name: "immutabletags",
+					Options: configuration.Parameters{
+						"tags": []interface{}{"v*"},
+					},
+				},
+			},
+		},
+	}
+
+	env := newTestEnvWithConfig(t, &config)
+	imageName := "foo/bar"
+
+	createRepository(env, t, imageName, "v1")
+	createRepository(env, t, imageName, "build-1")
+	createRepository(env, t, imageName, "build-2")
+
+	policies, err := RetentionPolicies(configuration.Retention{
+		Policies: []configuration.RetentionPolicy{
+			{Repository: "foo/*", KeepLast: 1},
+		},
+	})
+	checkErr(t, err, "compiling retention policies")
+
+	sink := &recordingSink{}
+	registry := &retentionNamespace{
+		Namespace: env.app.registry,
+		app:       env.app,
+		listener: notifications.NewBridge(v2.NewURLBuilder(&url.URL{}), env.app.events.source,
+			notifications.ActorRecord{}, notifications.RequestRecord{}, sink),
+	}
+
+	// The immutable tag is selected, but kept by the middleware.
+	pruned, err := storage.PruneTags(env.ctx, registry, env.app.driver, policies, false)
+	checkErr(t, err, "pruning tags")
+
+	if len(pruned) != 1 || pruned[0].Tag != "build-1" {
+		t.Fatalf("unexpected pruned tags: %#v", pruned)
+	}
+
+	if len(sink.events) != 1 {
+		t.Fatalf("unexpected number of events: %#v", sink.events)
+	}
+
+	event := sink.events[0]
+	if event.Action != notifications.EventActionUntag || event.Target.Repository != imageName || event.Target.Tag != "build-1" {
+		t.Fatalf("unexpected event: %#v", event)
+	}
+
+	tagsURL, err := env.builder.BuildTagsURL(imageName)
+	checkErr(t, err, "building tags url")
+
+	resp, err := http.Get(tagsURL)
+	checkErr(t, err, "listing tags")
+	defer resp.Body.Close()
+	checkResponse(t, "listing tags", resp, http.StatusOK)
+
+	var tags struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		t.Fatalf("error decoding tags: %v", err)
+	}
+
+	if !reflect.DeepEqual(tags.Tags, []string{"build-2", "v1"}) {
+		t.Fatalf("unexpected tags: %v", tags.Tags)
+	}
+
+	if _, err := RetentionPolicies(configuration.Retention{
+		Policies: []configuration.RetentionPolicy{
+			{Repository: "foo/*", Protect: []string{"("}},
+		},
+	}); err == nil {
+		t.Fatalf("expected error compiling invalid protect expression")
+	}
+}
+
+func TestRepositoryCopyAPI(t *testing.T) {
+	env := newTestEnv(t)
+	createRepository(env, t, "foo/bar", "latest")
+
+	mux := http.NewServeMux()
+	mux.Handle("/copy", env.app.CopyRepositoryHandler())
+	mux.Handle("/rename", env.app.RenameRepositoryHandler())
+	admin := httptest.NewServer(mux)
+	defer admin.Close()
+
+	copyRepository := func(action, from, to string, expectedStatus int) {
+		resp, err := http.PostForm(admin.URL+"/"+action, url.Values{"from": {from}, "to": {to}})
+		checkErr(t, err, action+" repository")
+		defer resp.Body.Close()
+		checkResponse(t, action+" repository", resp, expectedStatus)
+	}
+
+	checkManifest := func(name string, expectedStatus int) {
+		manifestURL, err := env.builder.BuildManifestURL(name, "latest")
+		checkErr(t, err, "building manifest url")
+
+		resp, err := http.Get(manifestURL)
+		checkErr(t, err, "fetching manifest")
+		defer resp.Body.Close()
+		checkResponse(t, "fetching manifest of "+name, resp, expectedStatus)
+
+		if expectedStatus != http.StatusOK {
+			return
+		}
+
+		var fetched manifest.SignedManifest
+		if err := json.NewDecoder(resp.Body).Decode(&fetched); err != nil {
+			t.Fatalf("error decoding fetched manifest: %v", err)
+		}
+
+		if fetched.Name != name {
+			t.Fatalf("unexpected name in manifest of %s: %q", name, fetched.Name)
+		}
+	}
+
+	sink := &recordingSink{}
+	env.app.events.sink = sink
+
+	checkEvents := func(expected ...string) {
+		var actual []string
+		for _, event := range sink.events {
+			actual = append(actual, event.Action+" "+event.Target.Repository)
+		}
+		sink.events = nil
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("unexpected events: %v != %v", actual, expected)
+		}
+	}
+
+	copyRepository("copy", "foo/bar", "foo/baz", http.StatusOK)
+	checkEvents("push foo/baz")
+	checkManifest("foo/bar", http.StatusOK)
+	checkManifest("foo/baz", http.StatusOK)
+
+	// Copies are only made on POST.
+	for _, method := range []string{"GET", "PUT"} {
+		req, err := http.NewRequest(method, admin.URL+"/copy?"+url.Values{"from": {"foo/bar"}, "to": {"foo/other"}}.Encode(), nil)
+		checkErr(t, err, "creating request")
+
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(t, err, method+" on copy")
+		resp.Body.Close()
+		checkResponse(t, method+" on copy", resp, http.StatusMethodNotAllowed)
+	}
+
+	copyRepository("copy", "foo/bar", "foo/baz", http.StatusConflict)
+	copyRepository("copy", "foo/unknown", "foo/other", http.StatusNotFound)
+	copyRepository("copy", "foo/bar", "Foo", http.StatusBadRequest)
+
+	sink.events = nil
+	copyRepository("rename", "foo/baz", "foo/qux", http.StatusOK)
+	checkEvents("delete foo/baz", "push foo/qux")
+	checkManifest("foo/baz", http.StatusNotFound)
+	checkManifest("foo/qux", http.StatusOK)
+
+	env.app.SetReadOnly(true)
+	resp, err := http.PostForm(admin.URL+"/rename", url.Values{"from": {"foo/qux"}, "to": {"foo/quux"}})
+	checkErr(t, err, "renaming repository")
+	defer resp.Body.Close()
+	checkResponse(t, "renaming repository in read-only mode", resp, http.StatusServiceUnavailable)
+	checkBodyHasErrorCodes(t, "renaming repository in read-only mode", resp, v2.ErrorCodeReadOnly)
+}
+
+func TestUploadsAPI(t *testing.T) {
+	env := newTestEnv(t)
+
+	admin := httptest.NewServer(env.app.UploadsHandler())
+	defer admin.Close()
+
+	listUploads := func(name string) []storage.UploadInfo {
+		resp, err := http.Get(admin.URL + "?" + url.Values{"name": {name}}.Encode())
+		checkErr(t, err, "listing uploads")
+		defer resp.Body.Close()
+		checkResponse(t, "listing uploads", resp, http.StatusOK)
+
+		var uploads []storage.UploadInfo
+		if err := json.NewDecoder(resp.Body).Decode(&uploads); err != nil {
+			t.Fatalf("error decoding uploads: %v", err)
+		}
+
+		return uploads
+	}
+
+	cancelUpload := func(name, id string, expectedStatus int) {
+		resp, err := httpDelete(admin.URL + "?" + url.Values{"name": {name}, "id": {id}}.Encode())
+		checkErr(t, err, "cancelling upload")
+		defer resp.Body.Close()
+		checkResponse(t, "cancelling upload", resp, expectedStatus)
+	}
+
+	if uploads := listUploads(""); len(uploads) != 0 {
+		t.Fatalf("unexpected uploads: %#v", uploads)
+	}
+
+	uploadURLBase, uploadUUID := startPushLayer(t, env.builder, "foo/bar")
+	startPushLayer(t, env.builder, "foo/baz")
+
+	if uploads := listUploads(""); len(uploads) != 2 {
+		t.Fatalf("unexpected uploads: %#v", uploads)
+	}
+
+	uploads := listUploads("foo/bar")
+	if len(uploads) != 1 || uploads[0].ID != uploadUUID || uploads[0].Repository != "foo/bar" {
+		t.Fatalf("unexpected uploads of foo/bar: %#v", uploads)
+	}
+
+	cancelUpload("foo/bar", uploadUUID, http.StatusNoContent)
+	cancelUpload("foo/bar", uploadUUID, http.StatusNotFound)
+	cancelUpload("Foo", uploadUUID, http.StatusBadRequest)
+	cancelUpload("foo/bar", "", http.StatusBadRequest)
+
+	if uploads := listUploads("foo/bar"); len(uploads) != 0 {
+		t.Fatalf("unexpected uploads after cancel: %#v", uploads)
+	}
+
+	// The client sees the upload as unknown once it is cancelled.
+	resp, err := http.Get(uploadURLBase)
+	checkErr(t, err, "fetching cancelled upload status")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching cancelled upload status", resp, http.StatusNotFound)
+
+	env.app.SetReadOnly(true)
+	uploads = listUploads("foo/baz")
+	if len(uploads) != 1 {
+		t.Fatalf("unexpected uploads of foo/baz: %#v", uploads)
+	}
+
+	cancelUpload("foo/baz", uploads[0].ID, http.StatusServiceUnavailable)
+
+	resp, err = http.Post(admin.URL, "", nil)
+	checkErr(t, err, "posting to uploads")
+	defer resp.Body.Close()
+	checkResponse(t, "posting to uploads", resp, http.StatusMethodNotAllowed)
+}
+
+func TestCatalogAPI(t *testing.T) {
+	env := newTestEnv(t)
+
+	catalogURL, err := env.builder.BuildCatalogURL()
+	if err != nil {
+		t.Fatalf("unexpected error building catalog url: %v", err)
+	}
+
+	// ----------------------------
+	// Catalog of an empty registry
+	resp, err := http.Get(catalogURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing empty catalog api check", resp, http.StatusOK)
+
+	var ctlg catalogAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ctlg); err != nil {
+		t.Fatalf("error decoding fetched manifest: %v", err)
+	}
+
+	if len(ctlg.Repositories) != 0 {
+		t.Fatalf("repositories has unexpected values: %v", ctlg.Repositories)
+	}
+
+	if resp.Header.Get("Link") != "" {
+		t.Fatalf("repositories has more data when none expected")
+	}
+
+	images := []string{"foo/aaaa", "foo/bbbb", "foo/cccc"}
+	for _, image := range images {
+		createRepository(env, t, image, "sometag")
+	}
+
+	// -------------------------------
+	// Fetch the first page of results
+	values := url.Values{"n": []string{"2"}}
+	catalogURL, err = env.builder.BuildCatalogURL(values)
+	if err != nil {
+		t.Fatalf("unexpected error building catalog url: %v", err)
+	}
+
+	resp, err = http.Get(catalogURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing paginated catalog api check", resp, http.StatusOK)
+
+	ctlg = catalogAPIResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&ctlg); err != nil {
+		t.Fatalf("error decoding fetched manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(ctlg.Repositories, images[:2]) {
+		t.Fatalf("unexpected first page: %v != %v", ctlg.Repositories, images[:2])
+	}
+
+	nextURL, err := env.builder.BuildCatalogURL(url.Values{
+		"n":    []string{"2"},
+		"last": []string{images[1]},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building catalog url: %v", err)
+	}
+
+	checkHeaders(t, resp, http.Header{
+		"Link": []string{fmt.Sprintf("<%s>; rel=\"next\"", nextURL)},
+	})
+
+	// -------------------------------------
+	// Follow the link to the remaining page
+	resp, err = http.Get(nextURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing next page catalog api check", resp, http.StatusOK)
+
+	ctlg = catalogAPIResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&ctlg); err != nil {
+		t.Fatalf("error decoding fetched manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(ctlg.Repositories, images[2:]) {
+		t.Fatalf("unexpected last page: %v != %v", ctlg.Repositories, images[2:])
+	}
+
+	if resp.Header.Get("Link") != "" {
+		t.Fatalf("unexpected Link header on last page: %q", resp.Header.Get("Link"))
+	}
+
+	// ------------------------
+	// Invalid page size values
+	catalogURL, err = env.builder.BuildCatalogURL(url.Values{"n": []string{"-1"}})
+	if err != nil {
+		t.Fatalf("unexpected error building catalog url: %v", err)
+	}
+
+	resp, err = http.Get(catalogURL)
+	if err != nil {
+		t.Fatalf("unexpected error issuing request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkResponse(t, "issuing invalid paginated catalog api check", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "invalid page size", resp, v2.ErrorCodePaginationNumberInvalid)
+}
+
+// TestTagsAPIPagination pushes several tags to a repository and pages
+// through the tag list using the Link header.
+func TestTagsAPIPagination(t *testing.T) {
+	env := newTestEnv(t)
+	imageName := "foo/bar"
+
+	tags := []string{"a", "b", "c", "d", "e"}
+	for _, tag := range []string{"c", "a", "e", "b", "d"} {
+		createRepository(env, t, imageName, tag)
+	}
+
+	tagsURL, err := env.builder.BuildTagsURL(imageName, url.Values{"n": []string{"2"}})
+	checkErr(t, err, "building tags url")
+
+	var paged []string
+	for pages := 0; tagsURL != ""; pages++ {
+		if pages > len(tags) {
+			t.Fatalf("too many pages following tags Link header")
+		}
+
+		resp, err := http.Get(tagsURL)
+		checkErr(t, err, "fetching tags page")
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching tags page", resp, http.StatusOK)
+
+		var tagsResponse tagsAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&tagsResponse); err != nil {
+			t.Fatalf("unexpected error decoding tags response: %v", err)
+		}
+
+		if len(tagsResponse.Tags) > 2 {
+			t.Fatalf("page exceeds requested size: %v", tagsResponse.Tags)
+		}
+		paged = append(paged, tagsResponse.Tags...)
+
+		tagsURL = ""
+		if link := resp.Header.Get("Link"); link != "" {
+			if !strings.HasPrefix(link, "<") || !strings.HasSuffix(link, ">; rel=\"next\"") {
+				t.Fatalf("unexpected Link header: %q", link)
+			}
+			tagsURL = link[1:strings.Index(link, ">")]
+		}
+	}
+
+	if !reflect.DeepEqual(paged, tags) {
+		t.Fatalf("unexpected paginated tags: %v != %v", paged, tags)
+	}
+
+	tagsURL, err = env.builder.BuildTagsURL(imageName, url.Values{"n": []string{"foo"}})
+	checkErr(t, err, "building tags url")
+
+	resp, err := http.Get(tagsURL)
+	checkErr(t, err, "fetching tags with invalid page size")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching tags with invalid page size", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "fetching tags with invalid page size", resp, v2.ErrorCodePaginationNumberInvalid)
+}
+
+// TestPullThroughCache runs a registry configured as a proxy of another
+// registry and ensures that content is fetched from the remote and cached.
+func TestPullThroughCache(t *testing.T) {
+	remoteEnv := newTestEnv(t)
+
+	imageName := "foo/bar"
+	tag := "latest"
+	signedManifest := createRepository(remoteEnv, t, imageName, tag)
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Proxy: configuration.Proxy{
+			RemoteURL: remoteEnv.server.URL,
+			TTL:       time.Hour,
+		},
+	}
+	env := newTestEnvWithConfig(t, &config)
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, tag)
+	checkErr(t, err, "building manifest url")
+
+	layerDigest := signedManifest.FSLayers[0].BlobSum
+	layerURL, err := env.builder.BuildBlobURL(imageName, layerDigest)
+	checkErr(t, err, "building layer url")
+
+	remoteLayerURL, err := remoteEnv.builder.BuildBlobURL(imageName, layerDigest)
+	checkErr(t, err, "building remote layer url")
+
+	resp, err := http.Get(remoteLayerURL)
+	checkErr(t, err, "fetching remote layer")
+	defer resp.Body.Close()
+
+	remoteLayer, err := ioutil.ReadAll(resp.Body)
+	checkErr(t, err, "reading remote layer")
+
+	// fetchContent checks that the manifest and layer are served by the
+	// proxy.
+	fetchContent := func(msg string) {
+		resp, err := http.Get(manifestURL)
+		checkErr(t, err, msg+": fetching manifest")
+		defer resp.Body.Close()
+
+		checkResponse(t, msg+": fetching manifest", resp, http.StatusOK)
+
+		var fetchedManifest manifest.SignedManifest
+		if err := json.NewDecoder(resp.Body).Decode(&fetchedManifest); err != nil {
+			t.Fatalf("%s: error decoding fetched manifest: %v", msg, err)
+		}
+
+		if !bytes.Equal(fetchedManifest.Raw, signedManifest.Raw) {
+			t.Fatalf("%s: manifests do not match", msg)
+		}
+
+		resp, err = http.Get(layerURL)
+		checkErr(t, err, msg+": fetching layer")
+		defer resp.Body.Close()
+
+		checkResponse(t, msg+": fetching layer", resp, http.StatusOK)
+
+		layer, err := ioutil.ReadAll(resp.Body)
+		checkErr(t, err, msg+": reading layer")
+
+		if !bytes.Equal(layer, remoteLayer) {
+			t.Fatalf("%s: layer content does not match remote", msg)
+		}
+	}
+
+	fetchContent("fetching through proxy")
+
+	// The layer is committed to local storage after the content has been
+	// sent to the client, so wait for it to land.
+	localRepo, err := storage.NewRegistryWithDriver(env.ctx, env.app.driver, nil).Repository(env.ctx, imageName)
+	checkErr(t, err, "getting local repository")
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if _, err := localRepo.Blobs(env.ctx).Stat(env.ctx, layerDigest); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("layer was not cached by the proxy: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// With the remote gone, content must be served from the cache.
+	remoteEnv.server.Close()
+	fetchContent("fetching from cache")
+
+	// The cache is read-only.
+	resp = putManifest(t, "putting manifest to proxy", manifestURL, signedManifest)
+	defer resp.Body.Close()
+
+	checkResponse(t, "putting manifest to proxy", resp, http.StatusMethodNotAllowed)
+	checkBodyHasErrorCodes(t, "putting manifest to proxy", resp, v2.ErrorCodeUnsupported)
+}
+
+// createRepository pushes a single layer and a signed manifest referencing it
+// under the given image name and tag, returning the manifest.
+func createRepository(env *testEnv, t *testing.T, imageName string, tag string) *manifest.SignedManifest {
+	unsignedManifest := &manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: imageName,
+		Tag:  tag,
+		FSLayers: []manifest.FSLayer{
+			{
+				BlobSum: "asdf",
+			},
+		},
+	}
+
+	for i := range unsignedManifest.FSLayers {
+		rs, dgstStr, err := testutil.CreateRandomTarFile()
+		if err != nil {
+			t.Fatalf("error creating random layer %d: %v", i, err)
+		}
+		dgst := digest.Digest(dgstStr)
+
+		unsignedManifest.FSLayers[i].BlobSum = dgst
+
+		uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+		pushLayer(t, env.builder, imageName, dgst, uploadURLBase, rs)
+	}
+
+	signedManifest, err := manifest.Sign(unsignedManifest, env.pk)
+	if err != nil {
+		t.Fatalf("unexpected error signing manifest: %v", err)
+	}
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, tag)
+	checkErr(t, err, "building manifest url")
+
+	resp := putManifest(t, "putting signed manifest", manifestURL, signedManifest)
+	checkResponse(t, "putting signed manifest", resp, http.StatusAccepted)
+
+	return signedManifest
+}
+
+type testEnv struct {
+	pk      libtrust.PrivateKey
+	ctx     context.Context
+	config  configuration.Configuration
+	app     *App
+	server  *httptest.Server
+	builder *v2.URLBuilder
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+
+	return newTestEnvWithConfig(t, &config)
+}
+
+func newTestEnvWithConfig(t *testing.T, config *configuration.Configuration) *testEnv {
+	ctx := context.Background()
+
+	app := NewApp(ctx, *config)
+	server := httptest.NewServer(handlers.CombinedLoggingHandler(os.Stderr, app))
+	builder, err := v2.NewURLBuilderFromString(server.URL + config.HTTP.Prefix)
+
+	if err != nil {
+		t.Fatalf("error creating url builder: %v", err)
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	return &testEnv{
+		pk:      pk,
+		ctx:     ctx,
+		config:  *config,
+		app:     app,
+		server:  server,
+		builder: builder,
+	}
+}
+
+func putManifest(t *testing.T, msg, url string, v interface{}) *http.Response {
+	var body []byte
+	if sm, ok := v.(*manifest.SignedManifest); ok {
+		body = sm.Raw
+	} else {
+		var err error
+		body, err = json.MarshalIndent(v, "", "   ")
+		if err != nil {
+			t.Fatalf("unexpected error marshaling %v: %v", v, err)
+		}
+	}
+
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("error creating request for %s: %v", msg, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error doing put request while %s: %v", msg, err)
+	}
+
+	return resp
+}
+
+func httpDelete(url string) (*http.Response, error) {
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.DefaultClient.Do(req)
 }
 
 func startPushLayer(t *testing.T, ub *v2.URLBuilder, name string) (location string, uuid string) {
@@ -1,20 +1,24 @@
 package handlers
 
 import (
+	"crypto/x509"
 	"expvar"
 	"fmt"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/configuration"
 	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/notifications"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/proxy"
 	registrymiddleware "github.com/docker/distribution/registry/middleware/registry"
 	repositorymiddleware "github.com/docker/distribution/registry/middleware/repository"
 	"github.com/docker/distribution/registry/storage"
@@ -22,6 +26,7 @@ import (
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/factory"
 	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	"github.com/docker/libtrust"
 	"github.com/garyburd/redigo/redis"
 	"github.com/gorilla/mux"
 	"golang.org/x/net/context"
@@ -52,6 +57,33 @@ type App struct {
 	
 	// redis 实例
 	redis *redis.Pool
+
+	// readOnly is set while the registry is in read-only maintenance mode.
+	// It can be toggled at runtime, so it is accessed atomically.
+	readOnly int32
+
+	// onlineGC makes the registry touch the blobs it links, so that garbage
+	// collection can run while it is serving.
+	onlineGC bool
+
+	// quotas limit the bytes stored by the repositories matching their
+	// patterns.
+	quotas []storage.Quota
+
+	// digestAlgorithms select the digest algorithm of the blobs uploaded to
+	// the repositories matching their patterns.
+	digestAlgorithms []storage.DigestAlgorithm
+
+	// trustPolicies require the manifests of the repositories matching
+	// their patterns to be signed by trusted certificates.
+	trustPolicies []storage.TrustPolicy
+
+	// signingKey signs the schema1 manifests rewritten by the registry.
+	signingKey libtrust.PrivateKey
+
+	// blobDescriptorCache is the descriptor cache of the registry, if one
+	// is configured.
+	blobDescriptorCache cache.BlobDescriptorCacheProvider
 }
 
 // NewApp takes a configuration and returns a configured app, ready to serve
@@ -72,10 +104,13 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 		return http.HandlerFunc(apiBase)
 	})
 	app.register(v2.RouteNameManifest, imageManifestDispatcher)
+	app.register(v2.RouteNameSignatures, signaturesDispatcher)
 	app.register(v2.RouteNameTags, tagsDispatcher)
 	app.register(v2.RouteNameBlob, blobDispatcher)
 	app.register(v2.RouteNameBlobUpload, blobUploadDispatcher)
 	app.register(v2.RouteNameBlobUploadChunk, blobUploadDispatcher)
+	app.register(v2.RouteNameCatalog, catalogDispatcher)
+	app.register(v2.RouteNameQuota, quotaDispatcher)
 	
 	// 创建 storage driver
 	var err error
@@ -93,12 +128,28 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 			switch k {
 			case "uploadpurging":
 				purgeConfig = v.(map[interface{}]interface{})
+			case "readonly":
+				readOnly, ok := v.(map[interface{}]interface{})
+				if !ok {
+					panic("readonly config key must contain additional keys")
+				}
+				if enabled, ok := readOnly["enabled"].(bool); ok {
+					app.SetReadOnly(enabled)
+				}
+			case "onlinegc":
+				onlineGC, ok := v.(map[interface{}]interface{})
+				if !ok {
+					panic("onlinegc config key must contain additional keys")
+				}
+				if enabled, ok := onlineGC["enabled"].(bool); ok {
+					app.onlineGC = enabled
+				}
 			}
 		}
 
 	}
 
-	startUploadPurger(app, app.driver, ctxu.GetLogger(app), purgeConfig)
+	startUploadPurger(app, app.driver, ctxu.GetLogger(app), purgeConfig, app.ReadOnly)
 	
 	// 创建 storage driver middleware
 	app.driver, err = applyStorageMiddleware(app.driver, configuration.Middleware["storage"])
@@ -110,6 +161,33 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 	app.configureEvents(&configuration)
 	// 配置 redis
 	app.configureRedis(&configuration)
+	// 配置存储配额
+	app.configureQuotas(&configuration)
+	quotaOption := storage.EnforceQuotas(app.quotas)
+	// 配置摘要算法
+	app.configureDigestAlgorithms(&configuration)
+	// 配置签名信任策略
+	app.configureTrust(&configuration)
+	registryOptions := []storage.RegistryOption{
+		quotaOption,
+		storage.UseDigestAlgorithms(app.digestAlgorithms),
+		storage.RequireTrustedSignatures(app.trustPolicies),
+	}
+	if app.onlineGC {
+		registryOptions = append(registryOptions, storage.EnableOnlineGC())
+	}
+
+	app.signingKey, err = SigningKey(&configuration)
+	if err != nil {
+		panic(fmt.Sprintf("unable to load schema1 signing key: %v", err))
+	}
+
+	// A pull through cache stores manifests before the layers they reference
+	// have been fetched.
+	newRegistry := storage.NewRegistryWithDriver
+	if configuration.Proxy.RemoteURL != "" {
+		newRegistry = storage.NewRegistryWithDriverSkipLayerVerification
+	}
 
 	// configure storage caches
 	// 配置缓存
@@ -125,10 +203,12 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 			if app.redis == nil {
 				panic("redis configuration required to use for layerinfo cache")
 			}
-			app.registry = storage.NewRegistryWithDriver(app, app.driver, cache.NewRedisBlobDescriptorCacheProvider(app.redis))
+			app.blobDescriptorCache = cache.NewRedisBlobDescriptorCacheProvider(app.redis)
+			app.registry = newRegistry(app, app.driver, app.blobDescriptorCache, registryOptions...)
 			ctxu.GetLogger(app).Infof("using redis blob descriptor cache")
 		case "inmemory":
-			app.registry = storage.NewRegistryWithDriver(app, app.driver, cache.NewInMemoryBlobDescriptorCacheProvider())
+			app.blobDescriptorCache = cache.NewInMemoryBlobDescriptorCacheProvider()
+			app.registry = newRegistry(app, app.driver, app.blobDescriptorCache, registryOptions...)
 			ctxu.GetLogger(app).Infof("using inmemory blob descriptor cache")
 		default:
 			if v != "" {
@@ -140,9 +220,18 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 	// 创建 registry
 	if app.registry == nil {
 		// configure the registry if no cache section is available.
-		app.registry = storage.NewRegistryWithDriver(app.Context, app.driver, nil)
+		app.registry = newRegistry(app.Context, app.driver, nil, registryOptions...)
 	}
 	
+	// 作为拉取缓存运行
+	if configuration.Proxy.RemoteURL != "" {
+		app.registry, err = proxy.NewRegistryPullThroughCache(app, app.registry, configuration.Proxy)
+		if err != nil {
+			panic(err.Error())
+		}
+		ctxu.GetLogger(app).Infof("registry configured as a proxy cache to %s", configuration.Proxy.RemoteURL)
+	}
+
 	// 创建 registry mdidleware, 然而有什么用？ 
 	app.registry, err = applyRegistryMiddleware(app.registry, configuration.Middleware["registry"])
 	if err != nil {
@@ -160,6 +249,12 @@ func NewApp(ctx context.Context, configuration configuration.Configuration) *App
 		app.accessController = accessController
 	}
 
+	// 按保留策略定时清理 tag
+	startTagPruner(app, configuration.Retention)
+
+	// 定时校验 blob 的完整性
+	startBlobScrubber(app, configuration.Scrub)
+
 	return app
 }
 
@@ -224,6 +319,105 @@ func (app *App) configureEvents(configuration *configuration.Configuration) {
 	}
 }
 
+// configureQuotas validates the configured quotas, panicking on an invalid
+// repository pattern.
+// 配置存储配额
+func (app *App) configureQuotas(configuration *configuration.Configuration) {
+	for _, quota := range configuration.Quotas {
+		if _, err := path.Match(quota.Repository, ""); err != nil {
+			panic(fmt.Sprintf("invalid quota repository pattern %q: %v", quota.Repository, err))
+		}
+
+		if quota.Limit < 0 {
+			panic(fmt.Sprintf("invalid quota limit %d for %q", quota.Limit, quota.Repository))
+		}
+
+		app.quotas = append(app.quotas, storage.Quota{
+			Pattern: quota.Repository,
+			Limit:   quota.Limit,
+		})
+	}
+
+	if len(app.quotas) > 0 {
+		ctxu.GetLogger(app).Infof("enforcing %d storage quotas", len(app.quotas))
+	}
+}
+
+// configureDigestAlgorithms validates the configured digest algorithms,
+// panicking on an invalid repository pattern or an unsupported algorithm.
+// 配置摘要算法
+func (app *App) configureDigestAlgorithms(configuration *configuration.Configuration) {
+	for _, algorithm := range configuration.DigestAlgorithms {
+		if _, err := path.Match(algorithm.Repository, ""); err != nil {
+			panic(fmt.Sprintf("invalid digest algorithm repository pattern %q: %v", algorithm.Repository, err))
+		}
+
+		if !digest.AlgorithmAvailable(algorithm.Algorithm) {
+			panic(fmt.Sprintf("unsupported digest algorithm %q for %q", algorithm.Algorithm, algorithm.Repository))
+		}
+
+		app.digestAlgorithms = append(app.digestAlgorithms, storage.DigestAlgorithm{
+			Pattern:   algorithm.Repository,
+			Algorithm: algorithm.Algorithm,
+		})
+	}
+}
+
+// configureTrust loads the certificate bundles of the configured trust
+// policies, panicking on an invalid repository pattern or an unreadable
+// bundle.
+// 配置签名信任策略
+func (app *App) configureTrust(configuration *configuration.Configuration) {
+	policies, err := TrustPolicies(configuration)
+	if err != nil {
+		panic(err.Error())
+	}
+	app.trustPolicies = policies
+
+	if len(app.trustPolicies) > 0 {
+		ctxu.GetLogger(app).Infof("requiring trusted signatures for %d repository patterns", len(app.trustPolicies))
+	}
+}
+
+// TrustPolicies loads the certificate bundles of the trust policies in the
+// configuration, returning an error on an invalid repository pattern or an
+// unreadable bundle.
+func TrustPolicies(configuration *configuration.Configuration) ([]storage.TrustPolicy, error) {
+	var policies []storage.TrustPolicy
+	for _, trust := range configuration.Trust {
+		if _, err := path.Match(trust.Repository, ""); err != nil {
+			return nil, fmt.Errorf("invalid trust repository pattern %q: %v", trust.Repository, err)
+		}
+
+		if len(trust.RootCertBundles) == 0 {
+			return nil, fmt.Errorf("no root certificate bundles configured to trust %q", trust.Repository)
+		}
+
+		roots := x509.NewCertPool()
+		for _, bundle := range trust.RootCertBundles {
+			certs, err := libtrust.LoadCertificateBundle(bundle)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load root certificate bundle %q: %v", bundle, err)
+			}
+
+			if len(certs) == 0 {
+				return nil, fmt.Errorf("no certificates in root certificate bundle %q", bundle)
+			}
+
+			for _, cert := range certs {
+				roots.AddCert(cert)
+			}
+		}
+
+		policies = append(policies, storage.TrustPolicy{
+			Pattern: trust.Repository,
+			Roots:   roots,
+		})
+	}
+
+	return policies, nil
+}
+
 // 配置 redis
 func (app *App) configureRedis(configuration *configuration.Configuration) {
 	if configuration.Redis.Addr == "" {
@@ -354,6 +548,11 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 
 		// Add username to request logging
 		context.Context = ctxu.WithLogger(context.Context, ctxu.GetLogger(context.Context, "auth.user.name"))
+
+		// 只读模式下拒绝写操作
+		if app.rejectReadOnly(context, w, r) {
+			return
+		}
 		
 		// 名字
 		if app.nameRequired(r) {
@@ -375,13 +574,8 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 				return
 			}
 
-			// assign and decorate the authorized repository with an event bridge.
-			context.Repository = notifications.Listen(
-				repository,
-				app.eventBridge(context, r))
-				
 			// repo middleware
-			context.Repository, err = applyRepoMiddleware(context.Repository, app.Config.Middleware["repository"])
+			repository, err = applyRepoMiddleware(repository, app.Config.Middleware["repository"])
 			if err != nil {
 				ctxu.GetLogger(context).Errorf("error initializing repository middleware: %v", err)
 				context.Errors.Push(v2.ErrorCodeUnknown, err)
@@ -389,6 +583,13 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 				serveJSON(w, context.Errors)
 				return
 			}
+
+			// assign and decorate the authorized repository with an event
+			// bridge. The bridge wraps the middleware, so that the events
+			// reflect the outcome of the middleware, such as a refused push.
+			context.Repository = notifications.Listen(
+				repository,
+				app.eventBridge(context, r))
 		}
 		
 		dispatch(context, r).ServeHTTP(w, r)
@@ -459,13 +660,14 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 
 	if repo != "" {
 		accessRecords = appendAccessRecords(accessRecords, r.Method, repo)
+		accessRecords = appendBlobMountAccessRecords(accessRecords, r)
 	} else {
-		// Only allow the name not to be set on the base route.
+		// Only allow the name not to be set on the base and catalog routes.
 		if app.nameRequired(r) {
 			// For this to be properly secured, repo must always be set for a
 			// resource that may make a modification. The only condition under
 			// which name is not set and we still allow access is when the
-			// base route is accessed. This section prevents us from making
+			// base or catalog routes are accessed. This section prevents us from making
 			// that mistake elsewhere in the code, allowing any operation to
 			// proceed.
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -476,8 +678,21 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 			serveJSON(w, errs)
 			return fmt.Errorf("forbidden: no repository name")
 		}
+
+		accessRecords = appendCatalogAccessRecord(accessRecords, r)
 	}
-	
+
+	return app.authorizeAccess(w, r, context, accessRecords...)
+}
+
+// authorizeAccess checks the request against the access controller for the
+// given access records, responding with a challenge or an error if access is
+// denied. On success, the context carries the authorized user.
+func (app *App) authorizeAccess(w http.ResponseWriter, r *http.Request, context *Context, accessRecords ...auth.Access) error {
+	if app.accessController == nil {
+		return nil // access controller is not enabled.
+	}
+
 	// 调用 Authorized 函数进行认证
 	ctx, err := app.accessController.Authorized(context.Context, accessRecords...)
 	if err != nil {
@@ -522,7 +737,11 @@ func (app *App) eventBridge(ctx *Context, r *http.Request) notifications.Listene
 // nameRequired returns true if the route requires a name.
 func (app *App) nameRequired(r *http.Request) bool {
 	route := mux.CurrentRoute(r)
-	return route == nil || route.GetName() != v2.RouteNameBase
+	if route == nil {
+		return true
+	}
+	routeName := route.GetName()
+	return routeName != v2.RouteNameBase && routeName != v2.RouteNameCatalog
 }
 
 // apiBase implements a simple yes-man for doing overall checks against the
@@ -576,6 +795,40 @@ func appendAccessRecords(records []auth.Access, method string, repo string) []au
 	return records
 }
 
+// appendBlobMountAccessRecords adds pull access on the source repository when
+// an upload is started as a cross repository mount.
+func appendBlobMountAccessRecords(records []auth.Access, r *http.Request) []auth.Access {
+	route := mux.CurrentRoute(r)
+	if route == nil || route.GetName() != v2.RouteNameBlobUpload || r.Method != "POST" {
+		return records
+	}
+
+	// 挂载需要源 repository 的 pull 权限
+	if fromRepo := r.FormValue("from"); fromRepo != "" {
+		records = appendAccessRecords(records, "GET", fromRepo)
+	}
+
+	return records
+}
+
+// appendCatalogAccessRecord adds the access record required to list the
+// repositories in the registry when the catalog route is requested.
+func appendCatalogAccessRecord(records []auth.Access, r *http.Request) []auth.Access {
+	route := mux.CurrentRoute(r)
+	if route == nil || route.GetName() != v2.RouteNameCatalog {
+		return records
+	}
+
+	// 列出所有 repository 需要 registry 级别的权限
+	return append(records, auth.Access{
+		Resource: auth.Resource{
+			Type: "registry",
+			Name: "catalog",
+		},
+		Action: "*",
+	})
+}
+
 // applyRegistryMiddleware wraps a registry instance with the configured middlewares
 func applyRegistryMiddleware(registry distribution.Namespace, middlewares []configuration.Middleware) (distribution.Namespace, error) {
 	for _, mw := range middlewares {
@@ -630,8 +883,9 @@ func badPurgeUploadConfig(reason string) {
 }
 
 // startUploadPurger schedules a goroutine which will periodically
-// check upload directories for old files and delete them
-func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, log ctxu.Logger, config map[interface{}]interface{}) {
+// check upload directories for old files and delete them. Purging is skipped
+// while suspended returns true.
+func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, log ctxu.Logger, config map[interface{}]interface{}, suspended func() bool) {
 	if config["enabled"] == false {
 		return
 	}
@@ -686,7 +940,11 @@ func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageD
 		time.Sleep(jitter)
 
 		for {
-			storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			if suspended() {
+				log.Infof("Upload purge suspended: registry is in read-only mode")
+			} else {
+				storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			}
 			log.Infof("Starting upload purge in %s", intervalDuration)
 			time.Sleep(intervalDuration)
 		}
@@ -203,6 +203,35 @@ func TestNewApp(t *testing.T) {
 	if errs.Errors[0].Code != v2.ErrorCodeUnauthorized {
 		t.Fatalf("unexpected error code: %v != %v", errs.Errors[0].Code, v2.ErrorCodeUnauthorized)
 	}
+
+	// The admin endpoints of the debug server are behind the access
+	// controller too.
+	for _, admin := range []struct {
+		handler http.Handler
+		method  string
+	}{
+		{app.CopyRepositoryHandler(), "POST"},
+		{app.RenameRepositoryHandler(), "POST"},
+		{app.UploadsHandler(), "GET"},
+		{app.UploadsHandler(), "DELETE"},
+	} {
+		server := httptest.NewServer(admin.handler)
+		req, err := http.NewRequest(admin.method, server.URL+"?from=foo/bar&to=foo/baz&name=foo/bar&id=upload", nil)
+		if err != nil {
+			t.Fatalf("error creating request: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error during %s: %v", admin.method, err)
+		}
+		resp.Body.Close()
+		server.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("unexpected status code during admin %s: %v != %v", admin.method, resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
 }
 
 // Test the access record accumulator
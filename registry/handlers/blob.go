@@ -35,8 +35,9 @@ func blobDispatcher(ctx *Context, r *http.Request) http.Handler {
 	
 	// GET 和 HEAD 方法都对应 GetBlob 方法
 	return handlers.MethodHandler{
-		"GET":  http.HandlerFunc(blobHandler.GetBlob),
-		"HEAD": http.HandlerFunc(blobHandler.GetBlob),
+		"GET":    http.HandlerFunc(blobHandler.GetBlob),
+		"HEAD":   http.HandlerFunc(blobHandler.GetBlob),
+		"DELETE": http.HandlerFunc(blobHandler.DeleteBlob),
 	}
 }
 
@@ -69,3 +70,27 @@ func (bh *blobHandler) GetBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// DeleteBlob removes the blob from the repository. The blob data remains
+// available to other repositories that link it.
+func (bh *blobHandler) DeleteBlob(w http.ResponseWriter, r *http.Request) {
+	context.GetLogger(bh).Debug("DeleteBlob")
+	blobs := bh.Repository.Blobs(bh)
+	if err := blobs.Delete(bh, bh.Digest); err != nil {
+		if err == distribution.ErrBlobUnknown {
+			w.WriteHeader(http.StatusNotFound)
+			bh.Errors.Push(v2.ErrorCodeBlobUnknown, bh.Digest)
+		} else if err == distribution.ErrUnsupported {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			bh.Errors.Push(v2.ErrorCodeUnsupported)
+		} else {
+			context.GetLogger(bh).Errorf("error deleting blob %v: %v", bh.Digest, err)
+			bh.Errors.Push(v2.ErrorCodeUnknown, err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -120,11 +120,40 @@ type blobUploadHandler struct {
 }
 
 // StartBlobUpload begins the blob upload process and allocates a server-side
-// blob writer session.
+// blob writer session. If the digest parameter is present, the request body
+// is the complete blob and the upload is committed in the same request.
 func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Request) {
 	blobs := buh.Repository.Blobs(buh)
+
+	// If the client asked to mount a blob from another repository, try that
+	// first. Any failure other than an exceeded quota falls back to a
+	// regular upload session.
+	if mountDigest, fromRepo := r.FormValue("mount"), r.FormValue("from"); mountDigest != "" && fromRepo != "" {
+		if buh.mountBlob(w, blobs, fromRepo, mountDigest) {
+			return
+		}
+	}
+
+	// With a digest, the request body is the complete blob, uploaded in a
+	// single request.
+	var dgst digest.Digest
+	if dgstStr := r.FormValue("digest"); dgstStr != "" {
+		var err error
+		if dgst, err = digest.ParseDigest(dgstStr); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			buh.Errors.Push(v2.ErrorCodeDigestInvalid, "digest parsing failed")
+			return
+		}
+	}
+
 	upload, err := blobs.Create(buh)
 	if err != nil {
+		if err == distribution.ErrUnsupported {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			buh.Errors.Push(v2.ErrorCodeUnsupported)
+			return
+		}
+
 		w.WriteHeader(http.StatusInternalServerError) // Error conditions here?
 		buh.Errors.Push(v2.ErrorCodeUnknown, err)
 		return
@@ -133,6 +162,11 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 	buh.Upload = upload
 	defer buh.Upload.Close()
 
+	if dgst != "" {
+		buh.completeUpload(w, r, dgst)
+		return
+	}
+
 	if err := buh.blobUploadResponse(w, r, true); err != nil {
 		w.WriteHeader(http.StatusInternalServerError) // Error conditions here?
 		buh.Errors.Push(v2.ErrorCodeUnknown, err)
@@ -143,6 +177,49 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// mountBlob attempts to link the blob identified by mountDigest from the
+// repository fromRepo into the current repository. If successful, a 201
+// response is written and true is returned. If the mount would exceed a
+// quota, the error is written and true is returned. Otherwise, nothing is
+// written to the response and the caller should proceed with a normal upload.
+func (buh *blobUploadHandler) mountBlob(w http.ResponseWriter, blobs distribution.BlobStore, fromRepo, mountDigest string) bool {
+	dgst, err := digest.ParseDigest(mountDigest)
+	if err != nil {
+		ctxu.GetLogger(buh).Infof("invalid mount digest %q, falling back to upload: %v", mountDigest, err)
+		return false
+	}
+
+	if err := v2.ValidateRespositoryName(fromRepo); err != nil {
+		ctxu.GetLogger(buh).Infof("invalid mount repository %q, falling back to upload: %v", fromRepo, err)
+		return false
+	}
+
+	desc, err := blobs.Mount(buh, fromRepo, dgst)
+	if err != nil {
+		if err, ok := err.(distribution.ErrQuotaExceeded); ok {
+			// Uploading the blob would exceed the quota as well.
+			w.WriteHeader(http.StatusInsufficientStorage)
+			buh.Errors.Push(v2.ErrorCodeQuotaExceeded, err)
+			return true
+		}
+
+		ctxu.GetLogger(buh).Infof("unable to mount %v from %q, falling back to upload: %v", dgst, fromRepo, err)
+		return false
+	}
+
+	blobURL, err := buh.urlBuilder.BuildBlobURL(buh.Repository.Name(), desc.Digest)
+	if err != nil {
+		// The blob is linked at this point, so the mount itself succeeded.
+		ctxu.GetLogger(buh).Errorf("error building blob url: %v", err)
+	}
+
+	w.Header().Set("Location", blobURL)
+	w.Header().Set("Content-Length", "0")
+	w.Header().Set("Docker-Content-Digest", desc.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+	return true
+}
+
 // GetUploadStatus returns the status of a given upload, identified by id.
 func (buh *blobUploadHandler) GetUploadStatus(w http.ResponseWriter, r *http.Request) {
 	if buh.Upload == nil {
@@ -227,6 +304,14 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		return
 	}
 
+	buh.completeUpload(w, r, dgst)
+}
+
+// completeUpload reads the remaining blob data, if any, from the request body
+// and commits the upload, verifying the data against dgst. If successful,
+// 201 Created is returned with the canonical url of the blob. Otherwise, the
+// upload is cancelled.
+func (buh *blobUploadHandler) completeUpload(w http.ResponseWriter, r *http.Request, dgst digest.Digest) {
 	// Read in the data, if any.
 	if _, err := io.Copy(buh.Upload, r.Body); err != nil {
 		ctxu.GetLogger(buh).Errorf("unknown error copying into upload: %v", err)
@@ -248,6 +333,9 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		case distribution.ErrBlobInvalidDigest:
 			w.WriteHeader(http.StatusBadRequest)
 			buh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
+		case distribution.ErrQuotaExceeded:
+			w.WriteHeader(http.StatusInsufficientStorage)
+			buh.Errors.Push(v2.ErrorCodeQuotaExceeded, err)
 		default:
 			switch err {
 			case distribution.ErrBlobInvalidLength, distribution.ErrBlobDigestUnsupported:
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/gorilla/handlers"
+)
+
+// catalogDispatcher constructs the catalog handler api endpoint.
+func catalogDispatcher(ctx *Context, r *http.Request) http.Handler {
+	catalogHandler := &catalogHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(catalogHandler.GetCatalog),
+	}
+}
+
+// catalogHandler handles requests for the list of repositories in the
+// registry.
+type catalogHandler struct {
+	*Context
+}
+
+type catalogAPIResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+// GetCatalog returns a json list of the repositories in the registry,
+// optionally paginated by the "n" and "last" query parameters.
+// 返回 registry 中的 repository 列表
+func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
+	n, last, ok := parsePagination(ch.Context, w, r)
+	if !ok {
+		return
+	}
+
+	repos, more, err := ch.registry.Catalog(ch).Get(n, last)
+	if err != nil {
+		ctxu.GetLogger(ch).Errorf("error listing repositories: %v", err)
+		ch.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if repos == nil {
+		repos = []string{}
+	}
+
+	if more {
+		urlStr, err := createLinkEntry(ch.urlBuilder.BuildCatalogURL, n, repos[len(repos)-1])
+		if err != nil {
+			ch.Errors.PushErr(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Link", urlStr)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(catalogAPIResponse{
+		Repositories: repos,
+	}); err != nil {
+		ch.Errors.PushErr(err)
+		return
+	}
+}
+
+// parsePagination reads the "n" and "last" query parameters from the
+// request. If "n" is invalid, the error is written to the response and ok is
+// false.
+func parsePagination(ctx *Context, w http.ResponseWriter, r *http.Request) (n int, last string, ok bool) {
+	q := r.URL.Query()
+	last = q.Get("last")
+
+	if entries := q.Get("n"); entries != "" {
+		var err error
+		n, err = strconv.Atoi(entries)
+		if err != nil || n < 0 {
+			ctx.Errors.Push(v2.ErrorCodePaginationNumberInvalid, map[string]string{"n": entries})
+			w.WriteHeader(http.StatusBadRequest)
+			return 0, "", false
+		}
+	}
+
+	return n, last, true
+}
+
+// createLinkEntry builds an RFC5988 Link header value pointing at the next
+// page of results, using build to construct the url.
+func createLinkEntry(build func(values ...url.Values) (string, error), n int, last string) (string, error) {
+	urlStr, err := build(url.Values{
+		"n":    []string{strconv.Itoa(n)},
+		"last": []string{last},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("<%s>; rel=\"next\"", urlStr), nil
+}
@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"strings"
 
@@ -12,7 +13,6 @@ import (
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/gorilla/handlers"
-	"golang.org/x/net/context"
 )
 
 // imageManifestDispatcher takes the request context and builds the
@@ -49,20 +49,24 @@ type imageManifestHandler struct {
 }
 
 // GetImageManifest fetches the image manifest from the storage backend, if it exists.
+// Schema1 manifests are always served. Other formats are only served to
+// clients that list their media type in the Accept header, and are refused
+// with 406 Not Acceptable otherwise, since the registry does not convert them
+// to schema1.
 // 从 storage 中获取 manifest
 func (imh *imageManifestHandler) GetImageManifest(w http.ResponseWriter, r *http.Request) {
 	ctxu.GetLogger(imh).Debug("GetImageManifest")
 	manifests := imh.Repository.Manifests()
 
 	var (
-		sm  *manifest.SignedManifest
+		m   distribution.Manifest
 		err error
 	)
 
 	if imh.Tag != "" {
-		sm, err = manifests.GetByTag(imh.Tag)
+		m, err = manifests.GetByTag(imh.Tag)
 	} else {
-		sm, err = manifests.Get(imh.Digest)
+		m, err = manifests.Get(imh.Digest)
 	}
 
 	if err != nil {
@@ -71,48 +75,91 @@ func (imh *imageManifestHandler) GetImageManifest(w http.ResponseWriter, r *http
 		return
 	}
 
+	desc, err := m.Descriptor()
+	if err != nil {
+		ctxu.GetLogger(imh).Errorf("error digesting manifest: %v", err)
+		imh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	contentType := desc.MediaType
+	if desc.MediaType == manifest.ManifestMediaType {
+		// Keep the content type that clients predating schema2 expect.
+		contentType = "application/json; charset=utf-8"
+	} else if !acceptsMediaType(r, desc.MediaType) {
+		// 客户端不支持该 manifest 格式
+		imh.Errors.Push(v2.ErrorCodeManifestNotAcceptable, map[string]string{"mediaType": desc.MediaType})
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
 	// Get the digest, if we don't already have it.
 	if imh.Digest == "" {
-		dgst, err := digestManifest(imh, sm)
-		if err != nil {
-			imh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-
-		imh.Digest = dgst
+		imh.Digest = desc.Digest
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set("Content-Length", fmt.Sprint(len(sm.Raw)))
+	content := m.Content()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
 	w.Header().Set("Docker-Content-Digest", imh.Digest.String())
-	w.Write(sm.Raw)
+	w.Write(content)
 }
 
-// PutImageManifest validates and stores and image in the registry.
+// acceptsMediaType returns true if mediaType is listed in the Accept headers
+// of the request.
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header[http.CanonicalHeaderKey("Accept")] {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			accepted, _, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+
+			if accepted == mediaType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// PutImageManifest validates and stores and image in the registry. The
+// manifest format is selected by the Content-Type of the request.
 func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http.Request) {
 	ctxu.GetLogger(imh).Debug("PutImageManifest")
 	manifests := imh.Repository.Manifests()
-	dec := json.NewDecoder(r.Body)
 
-	var manifest manifest.SignedManifest
-	if err := dec.Decode(&manifest); err != nil {
+	p, err := ioutil.ReadAll(r.Body)
+	if err != nil {
 		imh.Errors.Push(v2.ErrorCodeManifestInvalid, err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	dgst, err := digestManifest(imh, &manifest)
+	// 根据 Content-Type 选择 manifest 格式
+	m, err := distribution.UnmarshalManifest(r.Header.Get("Content-Type"), p)
 	if err != nil {
+		imh.Errors.Push(v2.ErrorCodeManifestInvalid, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	desc, err := m.Descriptor()
+	if err != nil {
+		ctxu.GetLogger(imh).Errorf("error digesting manifest: %v", err)
 		imh.Errors.Push(v2.ErrorCodeDigestInvalid, err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	dgst := desc.Digest
 
 	// Validate manifest tag or digest matches payload
 	if imh.Tag != "" {
-		if manifest.Tag != imh.Tag {
-			ctxu.GetLogger(imh).Errorf("invalid tag on manifest payload: %q != %q", manifest.Tag, imh.Tag)
+		// Schema1 manifests embed the tag, which must match the request.
+		if sm, ok := m.(*manifest.SignedManifest); ok && sm.Tag != imh.Tag {
+			ctxu.GetLogger(imh).Errorf("invalid tag on manifest payload: %q != %q", sm.Tag, imh.Tag)
 			imh.Errors.Push(v2.ErrorCodeTagInvalid)
 			w.WriteHeader(http.StatusBadRequest)
 			return
@@ -133,17 +180,35 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 	}
 	
 	// Put 方法在 manifeststore 里
-	if err := manifests.Put(&manifest); err != nil {
+	if err := manifests.Put(m, imh.Tag); err != nil {
+		if err == distribution.ErrUnsupported {
+			imh.Errors.Push(v2.ErrorCodeUnsupported)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
 		// TODO(stevvooe): These error handling switches really need to be
 		// handled by an app global mapper.
 		switch err := err.(type) {
+		case distribution.ErrQuotaExceeded:
+			imh.Errors.Push(v2.ErrorCodeQuotaExceeded, err)
+			w.WriteHeader(http.StatusInsufficientStorage)
+			return
+		case distribution.ErrTagImmutable:
+			imh.Errors.Push(v2.ErrorCodeTagImmutable, err)
+			w.WriteHeader(http.StatusConflict)
+			return
 		case distribution.ErrManifestVerification:
 			for _, verificationError := range err {
 				switch verificationError := verificationError.(type) {
 				case distribution.ErrManifestBlobUnknown:
 					imh.Errors.Push(v2.ErrorCodeBlobUnknown, verificationError.Digest)
+				case distribution.ErrManifestUnknownRevision:
+					imh.Errors.Push(v2.ErrorCodeManifestUnknown, verificationError.Revision)
 				case distribution.ErrManifestUnverified:
 					imh.Errors.Push(v2.ErrorCodeManifestUnverified)
+				case distribution.ErrManifestUntrusted:
+					imh.Errors.Push(v2.ErrorCodeManifestUnverified, verificationError.Reason)
 				default:
 					if verificationError == digest.ErrDigestInvalidFormat {
 						imh.Errors.Push(v2.ErrorCodeDigestInvalid)
@@ -174,41 +239,64 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// DeleteImageManifest removes the image with the given tag from the registry.
-// 不支持对 manifest 的删除
+// DeleteImageManifest removes the manifest with the given digest from the
+// registry. Any tags referencing the manifest are removed along with it. If a
+// tag is given instead of a digest, only the tag is removed.
 func (imh *imageManifestHandler) DeleteImageManifest(w http.ResponseWriter, r *http.Request) {
 	ctxu.GetLogger(imh).Debug("DeleteImageManifest")
 
-	// TODO(stevvooe): Unfortunately, at this point, manifest deletes are
-	// unsupported. There are issues with schema version 1 that make removing
-	// tag index entries a serious problem in eventually consistent storage.
-	// Once we work out schema version 2, the full deletion system will be
-	// worked out and we can add support back.
-	imh.Errors.Push(v2.ErrorCodeUnsupported)
-	w.WriteHeader(http.StatusBadRequest)
-}
+	manifests := imh.Repository.Manifests()
 
-// digestManifest takes a digest of the given manifest. This belongs somewhere
-// better but we'll wait for a refactoring cycle to find that real somewhere.
-func digestManifest(ctx context.Context, sm *manifest.SignedManifest) (digest.Digest, error) {
-	p, err := sm.Payload()
-	if err != nil {
-		if !strings.Contains(err.Error(), "missing signature key") {
-			ctxu.GetLogger(ctx).Errorf("error getting manifest payload: %v", err)
-			return "", err
+	if imh.Digest == "" {
+		// A delete by tag only removes the tag, leaving the revision that it
+		// referenced available by digest.
+		if err := manifests.DeleteByTag(imh.Tag); err != nil {
+			switch err := err.(type) {
+			case distribution.ErrManifestUnknown:
+				imh.Errors.Push(v2.ErrorCodeManifestUnknown, err)
+				w.WriteHeader(http.StatusNotFound)
+			case distribution.ErrTagImmutable:
+				imh.Errors.Push(v2.ErrorCodeTagImmutable, err)
+				w.WriteHeader(http.StatusConflict)
+			default:
+				if err == distribution.ErrUnsupported {
+					imh.Errors.Push(v2.ErrorCodeUnsupported)
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				ctxu.GetLogger(imh).Errorf("error deleting tag %q: %v", imh.Tag, err)
+				imh.Errors.Push(v2.ErrorCodeUnknown, err)
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
 		}
 
-		// NOTE(stevvooe): There are no signatures but we still have a
-		// payload. The request will fail later but this is not the
-		// responsibility of this part of the code.
-		p = sm.Raw
+		w.WriteHeader(http.StatusAccepted)
+		return
 	}
 
-	dgst, err := digest.FromBytes(p)
-	if err != nil {
-		ctxu.GetLogger(ctx).Errorf("error digesting manifest: %v", err)
-		return "", err
+	if err := manifests.Delete(imh.Digest); err != nil {
+		switch err := err.(type) {
+		case distribution.ErrManifestUnknownRevision:
+			imh.Errors.Push(v2.ErrorCodeManifestUnknown, err)
+			w.WriteHeader(http.StatusNotFound)
+		case distribution.ErrTagImmutable:
+			imh.Errors.Push(v2.ErrorCodeTagImmutable, err)
+			w.WriteHeader(http.StatusConflict)
+		default:
+			if err == distribution.ErrUnsupported {
+				imh.Errors.Push(v2.ErrorCodeUnsupported)
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			ctxu.GetLogger(imh).Errorf("error deleting manifest %v: %v", imh.Digest, err)
+			imh.Errors.Push(v2.ErrorCodeUnknown, err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
 	}
 
-	return dgst, err
+	w.WriteHeader(http.StatusAccepted)
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+)
+
+// readOnlyRetryAfter is the delay suggested to clients whose writes are
+// rejected while the registry is in read-only mode.
+const readOnlyRetryAfter = 5 * time.Minute
+
+// ReadOnly returns true if the registry is in read-only maintenance mode.
+func (app *App) ReadOnly() bool {
+	return atomic.LoadInt32(&app.readOnly) == 1
+}
+
+// SetReadOnly enables or disables read-only maintenance mode. While enabled,
+// writes to repositories are rejected and the upload purger and tag pruner
+// are suspended.
+// 开启或关闭只读维护模式
+func (app *App) SetReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+
+	if atomic.SwapInt32(&app.readOnly, v) != v {
+		ctxu.GetLogger(app).Infof("read-only mode set to %v", readOnly)
+	}
+}
+
+// ReadOnlyHandler returns a handler to query and toggle read-only mode. It is
+// meant to be served on the debug server, which should not be exposed
+// externally. A GET returns the current state; a PUT or POST with the
+// "enabled" query parameter sets it.
+func (app *App) ReadOnlyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT", "POST":
+			enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid value for enabled: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			app.SetReadOnly(enabled)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(struct {
+			ReadOnly bool `json:"readonly"`
+		}{
+			ReadOnly: app.ReadOnly(),
+		})
+	})
+}
+
+// rejectReadOnly responds with a read-only error if the registry is in
+// read-only mode and the request would write to a repository. It returns
+// true if the request was rejected.
+func (app *App) rejectReadOnly(context *Context, w http.ResponseWriter, r *http.Request) bool {
+	if !app.ReadOnly() {
+		return false
+	}
+
+	switch r.Method {
+	case "PUT", "POST", "PATCH", "DELETE":
+	default:
+		return false
+	}
+
+	w.Header().Set("Retry-After", fmt.Sprint(int(readOnlyRetryAfter.Seconds())))
+	context.Errors.Push(v2.ErrorCodeReadOnly)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	serveJSON(w, context.Errors)
+	return true
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/gorilla/handlers"
+)
+
+// quotaDispatcher constructs the quota handler api endpoint.
+func quotaDispatcher(ctx *Context, r *http.Request) http.Handler {
+	quotaHandler := &quotaHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(quotaHandler.GetQuota),
+	}
+}
+
+// quotaHandler handles requests for the storage usage of a repository.
+type quotaHandler struct {
+	*Context
+}
+
+type quotaUsageAPIResponse struct {
+	Pattern string `json:"pattern"`
+	Limit   int64  `json:"limit"`
+	Used    int64  `json:"used"`
+}
+
+type quotaAPIResponse struct {
+	Name   string                  `json:"name"`
+	Used   int64                   `json:"used"`
+	Quotas []quotaUsageAPIResponse `json:"quotas"`
+}
+
+// GetQuota returns the bytes used by the repository, along with the usage of
+// each quota that applies to it.
+// 返回 repository 的存储用量以及适用的配额
+func (qh *quotaHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+	used, usages, err := storage.Usage(qh, qh.driver, qh.quotas, qh.Repository.Name())
+	if err != nil {
+		ctxu.GetLogger(qh).Errorf("error computing storage usage: %v", err)
+		qh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := quotaAPIResponse{
+		Name:   qh.Repository.Name(),
+		Used:   used,
+		Quotas: []quotaUsageAPIResponse{},
+	}
+
+	for _, usage := range usages {
+		response.Quotas = append(response.Quotas, quotaUsageAPIResponse{
+			Pattern: usage.Pattern,
+			Limit:   usage.Limit,
+			Used:    usage.Used,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(response); err != nil {
+		qh.Errors.PushErr(err)
+		return
+	}
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/libtrust"
+)
+
+// SigningKey loads the private key used to sign the schema1 manifests that
+// the registry rewrites, generating an ephemeral key if none is configured.
+// Clients only check that schema1 signatures are consistent, so any key
+// will do.
+func SigningKey(configuration *configuration.Configuration) (libtrust.PrivateKey, error) {
+	if configuration.Compatibility.Schema1.SigningKeyFile == "" {
+		return libtrust.GenerateECP256PrivateKey()
+	}
+
+	return libtrust.LoadKeyFile(configuration.Compatibility.Schema1.SigningKeyFile)
+}
+
+// copyFunc copies a repository, as storage.CopyRepository does.
+type copyFunc func(ctx ctxu.Context, registry distribution.Namespace, driver storagedriver.StorageDriver, from, to string, key libtrust.PrivateKey) (storage.RepositoryCopy, error)
+
+// CopyRepositoryHandler returns a handler that copies the repository named
+// by the "from" query parameter to a new repository named by "to", without
+// copying blob data. It requires pull access to the source and push access
+// to the destination, and sends a push event for each tagged manifest of the
+// copy. It is meant to be served on the debug server, which must not be
+// exposed externally.
+// 复制 repository 的管理接口
+func (app *App) CopyRepositoryHandler() http.Handler {
+	return app.repositoryCopyHandler(storage.CopyRepository, false)
+}
+
+// RenameRepositoryHandler returns a handler that renames the repository
+// named by the "from" query parameter to "to". It requires the delete
+// access of the source, and also sends a delete event for each manifest that
+// was tagged in the source. Like CopyRepositoryHandler, it is meant to be
+// served on the debug server.
+// 重命名 repository 的管理接口
+func (app *App) RenameRepositoryHandler() http.Handler {
+	return app.repositoryCopyHandler(storage.RenameRepository, true)
+}
+
+// repositoryCopyHandler serves POST requests with copy, responding with a
+// summary of the copied content. If remove is true, copy removes the source.
+func (app *App) repositoryCopyHandler(copy copyFunc, remove bool) http.Handler {
+	access := func(r *http.Request) []auth.Access {
+		sourceMethod := "GET"
+		if remove {
+			sourceMethod = "DELETE"
+		}
+
+		records := appendAccessRecords(nil, sourceMethod, r.FormValue("from"))
+		return appendAccessRecords(records, "POST", r.FormValue("to"))
+	}
+
+	return app.adminHandler([]string{"POST"}, access, func(context *Context, w http.ResponseWriter, r *http.Request) {
+		from, to := r.FormValue("from"), r.FormValue("to")
+		if from == "" || to == "" {
+			http.Error(w, "from and to are required", http.StatusBadRequest)
+			return
+		}
+
+		// The manifests of a renamed source are gone once it is removed,
+		// so collect them for the delete events beforehand.
+		var removed []distribution.Manifest
+		if remove {
+			var err error
+			if removed, err = taggedManifests(context, app.registry, from); err != nil {
+				ctxu.GetLogger(context).Errorf("error resolving tagged manifests of %s: %v", from, err)
+			}
+		}
+
+		copied, err := copy(context, app.registry, app.driver, from, to, app.signingKey)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch err.(type) {
+			case distribution.ErrRepositoryNameInvalid:
+				status = http.StatusBadRequest
+			case distribution.ErrRepositoryUnknown:
+				status = http.StatusNotFound
+			case distribution.ErrRepositoryExists:
+				status = http.StatusConflict
+			case distribution.ErrQuotaExceeded:
+				status = http.StatusInsufficientStorage
+			default:
+				ctxu.GetLogger(context).Errorf("error copying repository %s to %s: %v", from, to, err)
+			}
+
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		ctxu.GetLogger(context).Infof("copied repository %s to %s: %d layers, %d manifests, %d tags", from, to, copied.Layers, copied.Manifests, copied.Tags)
+		app.notifyCopy(context, r, from, to, removed)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(copied)
+	})
+}
+
+// notifyCopy sends a push event for each tagged manifest of the repository
+// named to, and a delete event for each of the removed manifests of the
+// repository named from.
+func (app *App) notifyCopy(context *Context, r *http.Request, from, to string, removed []distribution.Manifest) {
+	listener := app.eventBridge(context, r)
+
+	source, err := app.registry.Repository(context, from)
+	if err != nil {
+		ctxu.GetLogger(context).Errorf("error resolving repository %s: %v", from, err)
+		return
+	}
+
+	for _, m := range removed {
+		if err := listener.ManifestDeleted(source, m); err != nil {
+			ctxu.GetLogger(context).Errorf("error dispatching manifest delete to listener: %v", err)
+		}
+	}
+
+	destination, err := app.registry.Repository(context, to)
+	if err != nil {
+		ctxu.GetLogger(context).Errorf("error resolving repository %s: %v", to, err)
+		return
+	}
+
+	pushed, err := taggedManifests(context, app.registry, to)
+	if err != nil {
+		ctxu.GetLogger(context).Errorf("error resolving tagged manifests of %s: %v", to, err)
+	}
+
+	for _, m := range pushed {
+		if err := listener.ManifestPushed(destination, m); err != nil {
+			ctxu.GetLogger(context).Errorf("error dispatching manifest push to listener: %v", err)
+		}
+	}
+}
+
+// taggedManifests returns the manifests referenced by the tags of the named
+// repository, once each.
+func taggedManifests(ctx ctxu.Context, registry distribution.Namespace, name string) ([]distribution.Manifest, error) {
+	repo, err := registry.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := repo.Manifests().Tags()
+	if err != nil {
+		if _, ok := err.(distribution.ErrRepositoryUnknown); ok {
+			return nil, nil // no tags
+		}
+		return nil, err
+	}
+
+	var manifests []distribution.Manifest
+	seen := make(map[digest.Digest]struct{})
+	for _, tag := range tags {
+		m, err := repo.Manifests().GetByTag(tag)
+		if err != nil {
+			return manifests, err
+		}
+
+		desc, err := m.Descriptor()
+		if err != nil {
+			return manifests, err
+		}
+
+		if _, ok := seen[desc.Digest]; !ok {
+			seen[desc.Digest] = struct{}{}
+			manifests = append(manifests, m)
+		}
+	}
+
+	return manifests, nil
+}
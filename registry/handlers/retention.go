@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/storage"
+)
+
+// defaultRetentionInterval is the time between runs of the tag pruner when
+// the configuration does not set one.
+const defaultRetentionInterval = 24 * time.Hour
+
+// RetentionPolicies compiles the configured retention policies.
+func RetentionPolicies(config configuration.Retention) ([]storage.RetentionPolicy, error) {
+	var policies []storage.RetentionPolicy
+	for _, policy := range config.Policies {
+		if _, err := path.Match(policy.Repository, ""); err != nil {
+			return nil, fmt.Errorf("invalid retention repository pattern %q: %v", policy.Repository, err)
+		}
+
+		if policy.KeepLast < 0 || policy.MaxAge < 0 {
+			return nil, fmt.Errorf("invalid retention policy for %q: keeplast and maxage must not be negative", policy.Repository)
+		}
+
+		compiled := storage.RetentionPolicy{
+			Repository: policy.Repository,
+			KeepLast:   policy.KeepLast,
+			MaxAge:     policy.MaxAge,
+		}
+
+		if policy.Tags != "" {
+			re, err := regexp.Compile(policy.Tags)
+			if err != nil {
+				return nil, fmt.Errorf("invalid retention tags expression %q: %v", policy.Tags, err)
+			}
+			compiled.Tags = re
+		}
+
+		for _, protect := range policy.Protect {
+			re, err := regexp.Compile(protect)
+			if err != nil {
+				return nil, fmt.Errorf("invalid retention protect expression %q: %v", protect, err)
+			}
+			compiled.Protect = append(compiled.Protect, re)
+		}
+
+		policies = append(policies, compiled)
+	}
+
+	return policies, nil
+}
+
+// startTagPruner schedules the pruning of the tags selected by the retention
+// policies, in the same way as the upload purger. Tags are removed through
+// the repository middleware, so immutable tags are kept, and each removal is
+// dispatched as a tag delete event. The pruner is suspended while the
+// registry is in read-only mode.
+// 定时按保留策略清理 tag
+func startTagPruner(app *App, config configuration.Retention) {
+	policies, err := RetentionPolicies(config)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if len(policies) == 0 {
+		return
+	}
+
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	log := context.GetLogger(app)
+	registry := &retentionNamespace{
+		Namespace: app.registry,
+		app:       app,
+		listener: notifications.NewBridge(v2.NewURLBuilder(&url.URL{}), app.events.source,
+			notifications.ActorRecord{}, notifications.RequestRecord{}, app.events.sink),
+	}
+
+	go func() {
+		rand.Seed(time.Now().Unix())
+		jitter := time.Duration(rand.Int()%60) * time.Minute
+		log.Infof("Starting tag pruning in %s", jitter)
+		time.Sleep(jitter)
+
+		for {
+			if app.ReadOnly() {
+				log.Infof("Tag pruning suspended: registry is in read-only mode")
+			} else {
+				pruned, err := storage.PruneTags(app, registry, app.driver, policies, config.DryRun)
+				for _, tag := range pruned {
+					if config.DryRun {
+						log.Infof("retention: would prune tag %s:%s, %s", tag.Repository, tag.Tag, tag.Reason)
+					} else {
+						log.Infof("retention: pruned tag %s:%s, %s", tag.Repository, tag.Tag, tag.Reason)
+					}
+				}
+
+				if err != nil {
+					log.Errorf("error pruning tags: %v", err)
+				}
+			}
+			log.Infof("Starting tag pruning in %s", interval)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// retentionNamespace decorates the repositories of the registry as they would
+// be for a request, applying the repository middleware and dispatching
+// events to the listener.
+type retentionNamespace struct {
+	distribution.Namespace
+	app      *App
+	listener notifications.Listener
+}
+
+func (ns *retentionNamespace) Repository(ctx context.Context, name string) (distribution.Repository, error) {
+	repository, err := ns.Namespace.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err = applyRepoMiddleware(repository, ns.app.Config.Middleware["repository"])
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications.Listen(repository, ns.listener), nil
+}
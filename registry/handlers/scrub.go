@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage"
+)
+
+// defaultScrubInterval is the time between runs of the blob scrubber when the
+// configuration does not set one.
+const defaultScrubInterval = 7 * 24 * time.Hour
+
+// startBlobScrubber schedules the verification of the blob data, in the same
+// way as the upload purger. Corrupt blobs are logged and, if configured,
+// quarantined. Quarantine is skipped while the registry is in read-only mode,
+// but blobs are still verified.
+// 定时校验 blob 的完整性
+func startBlobScrubber(app *App, config configuration.Scrub) {
+	if !config.Enabled {
+		return
+	}
+
+	if config.RateLimit < 0 {
+		panic("scrub ratelimit must not be negative")
+	}
+
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultScrubInterval
+	}
+
+	log := context.GetLogger(app)
+
+	go func() {
+		rand.Seed(time.Now().Unix())
+		jitter := time.Duration(rand.Int()%60) * time.Minute
+		log.Infof("Starting blob scrub in %s", jitter)
+		time.Sleep(jitter)
+
+		for {
+			options := storage.ScrubOptions{
+				RateLimit:  config.RateLimit,
+				Quarantine: config.Quarantine && !app.ReadOnly(),
+				Cache:      app.blobDescriptorCache,
+			}
+
+			report, err := storage.ScrubBlobs(app, app.driver, options)
+			if err != nil {
+				log.Errorf("error scrubbing blobs: %v", err)
+			}
+
+			log.Infof("Blob scrub finished: %d blobs, %d bytes verified, %d corrupt", report.Blobs, report.Bytes, len(report.Corrupt))
+			log.Infof("Starting blob scrub in %s", interval)
+			time.Sleep(interval)
+		}
+	}()
+}
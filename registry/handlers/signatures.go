@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/gorilla/handlers"
+)
+
+// signaturesDispatcher constructs the handler for the detached signatures of
+// a manifest revision.
+// 签名的调度器
+func signaturesDispatcher(ctx *Context, r *http.Request) http.Handler {
+	dgst, err := getDigest(ctx)
+	if err != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Errors.Push(v2.ErrorCodeDigestInvalid, err)
+			w.WriteHeader(http.StatusBadRequest)
+		})
+	}
+
+	signaturesHandler := &signaturesHandler{
+		Context: ctx,
+		Digest:  dgst,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(signaturesHandler.GetSignatures),
+		"PUT": http.HandlerFunc(signaturesHandler.PutSignatures),
+	}
+}
+
+// signaturesHandler handles requests for the signatures of a manifest
+// revision.
+type signaturesHandler struct {
+	*Context
+
+	Digest digest.Digest
+}
+
+type signaturesAPIResponse struct {
+	Name       string            `json:"name"`
+	Digest     digest.Digest     `json:"digest"`
+	Signatures []json.RawMessage `json:"signatures"`
+}
+
+type signaturesAPIRequest struct {
+	Signatures []json.RawMessage `json:"signatures"`
+}
+
+// GetSignatures returns the JWS signatures attached to the manifest
+// revision. Unsigned manifest formats have no signatures.
+// 返回 manifest 的签名
+func (sh *signaturesHandler) GetSignatures(w http.ResponseWriter, r *http.Request) {
+	ctxu.GetLogger(sh).Debug("GetSignatures")
+
+	exists, err := sh.Repository.Manifests().Exists(sh.Digest)
+	if err != nil {
+		sh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !exists {
+		sh.Errors.Push(v2.ErrorCodeManifestUnknown, sh.Digest)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	signatures, err := sh.Repository.Signatures().Get(sh.Digest)
+	if err != nil {
+		ctxu.GetLogger(sh).Errorf("error fetching signatures: %v", err)
+		sh.Errors.PushErr(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := signaturesAPIResponse{
+		Name:       sh.Repository.Name(),
+		Digest:     sh.Digest,
+		Signatures: []json.RawMessage{},
+	}
+
+	for _, signature := range signatures {
+		response.Signatures = append(response.Signatures, json.RawMessage(signature))
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Docker-Content-Digest", sh.Digest.String())
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(response); err != nil {
+		sh.Errors.PushErr(err)
+		return
+	}
+}
+
+// PutSignatures attaches the JWS signatures in the request body to the
+// manifest revision, without the manifest being pushed again.
+// 为已有的 manifest 附加签名
+func (sh *signaturesHandler) PutSignatures(w http.ResponseWriter, r *http.Request) {
+	ctxu.GetLogger(sh).Debug("PutSignatures")
+
+	var request signaturesAPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		sh.Errors.Push(v2.ErrorCodeManifestInvalid, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if len(request.Signatures) == 0 {
+		sh.Errors.Push(v2.ErrorCodeManifestInvalid, "no signatures provided")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var signatures [][]byte
+	for _, signature := range request.Signatures {
+		signatures = append(signatures, []byte(signature))
+	}
+
+	if err := sh.Repository.Signatures().Put(sh.Digest, signatures...); err != nil {
+		switch err := err.(type) {
+		case distribution.ErrManifestUnknownRevision:
+			sh.Errors.Push(v2.ErrorCodeManifestUnknown, err.Revision)
+			w.WriteHeader(http.StatusNotFound)
+		case distribution.ErrManifestUnverified:
+			sh.Errors.Push(v2.ErrorCodeManifestUnverified)
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			if err == distribution.ErrUnsupported {
+				sh.Errors.Push(v2.ErrorCodeManifestInvalid, fmt.Sprintf("manifest %v is not signed", sh.Digest))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			ctxu.GetLogger(sh).Errorf("error attaching signatures: %v", err)
+			sh.Errors.PushErr(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	location, err := sh.urlBuilder.BuildSignaturesURL(sh.Repository.Name(), sh.Digest)
+	if err != nil {
+		ctxu.GetLogger(sh).Errorf("error building signatures url: %v", err)
+	}
+
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Length", "0")
+	w.Header().Set("Docker-Content-Digest", sh.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+}
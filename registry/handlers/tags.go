@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/registry/api/v2"
@@ -35,6 +36,11 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	manifests := th.Repository.Manifests()
 
+	n, last, ok := parsePagination(th.Context, w, r)
+	if !ok {
+		return
+	}
+
 	tags, err := manifests.Tags()
 	if err != nil {
 		switch err := err.(type) {
@@ -47,6 +53,23 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The tags are listed in full, since the storage drivers cannot start a
+	// listing after last. Only the response is paginated.
+	tags, more := v2.Paginate(tags, n, last)
+	if more {
+		buildTagsURL := func(values ...url.Values) (string, error) {
+			return th.urlBuilder.BuildTagsURL(th.Repository.Name(), values...)
+		}
+
+		urlStr, err := createLinkEntry(buildTagsURL, n, tags[len(tags)-1])
+		if err != nil {
+			th.Errors.PushErr(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Link", urlStr)
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 	enc := json.NewEncoder(w)
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/storage"
+)
+
+// UploadsHandler returns a handler for the uploads in progress. A GET lists
+// the uploads of the repository named by the "name" query parameter, or of
+// every repository if it is omitted. A DELETE cancels the upload named by
+// the "name" and "id" query parameters, removing the data received so far.
+// Listing requires pull access to the repository, or catalog access without
+// a name, and cancelling requires delete access, as for the API routes. It is
+// meant to be served on the debug server, which must not be exposed
+// externally.
+// 列出和取消正在进行的上传的管理接口
+func (app *App) UploadsHandler() http.Handler {
+	access := func(r *http.Request) []auth.Access {
+		name := r.FormValue("name")
+		if r.Method == "GET" && name == "" {
+			return []auth.Access{{
+				Resource: auth.Resource{Type: "registry", Name: "catalog"},
+				Action:   "*",
+			}}
+		}
+
+		return appendAccessRecords(nil, r.Method, name)
+	}
+
+	return app.adminHandler([]string{"GET", "DELETE"}, access, func(context *Context, w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			app.listUploads(context, w, r)
+		case "DELETE":
+			app.cancelUpload(context, w, r)
+		}
+	})
+}
+
+// listUploads responds with the uploads in progress, as returned by
+// storage.ListUploads.
+func (app *App) listUploads(context *Context, w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	uploads, err := storage.ListUploads(context, app.driver, name)
+	if err != nil {
+		if _, ok := err.(distribution.ErrRepositoryNameInvalid); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctxu.GetLogger(context).Errorf("error listing uploads: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(uploads)
+}
+
+// cancelUpload cancels an upload with storage.CancelUpload.
+func (app *App) cancelUpload(context *Context, w http.ResponseWriter, r *http.Request) {
+	name, id := r.FormValue("name"), r.FormValue("id")
+	if name == "" || id == "" {
+		http.Error(w, "name and id are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := storage.CancelUpload(context, app.driver, name, id); err != nil {
+		status := http.StatusInternalServerError
+		switch err.(type) {
+		case distribution.ErrRepositoryNameInvalid:
+			status = http.StatusBadRequest
+		default:
+			if err == distribution.ErrBlobUploadUnknown {
+				status = http.StatusNotFound
+			} else {
+				ctxu.GetLogger(context).Errorf("error cancelling upload %s in %s: %v", id, name, err)
+			}
+		}
+
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage"
+)
+
+// UsageHandler returns a handler reporting the storage usage of the
+// registry, as returned by storage.ReportUsage. The report walks the whole
+// storage, so the handler is meant to be served on the debug server, which
+// should not be exposed externally.
+// 返回 registry 存储用量报告的 handler
+func (app *App) UsageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		usage, err := storage.ReportUsage(app, app.driver)
+		if err != nil {
+			ctxu.GetLogger(app).Errorf("error reporting storage usage: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(usage)
+	})
+}
@@ -0,0 +1,218 @@
+// Package immutabletags provides a repository middleware that prevents tags
+// matching configured patterns from being moved or removed once they have
+// been pushed.
+//
+// 不可变 tag 中间件，匹配的 tag 一旦推送就不能再指向其他 manifest
+package immutabletags
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	middleware "github.com/docker/distribution/registry/middleware/repository"
+)
+
+func init() {
+	middleware.Register("immutabletags", newImmutableTagsRepository)
+}
+
+// immutableTagsRepository refuses changes to the tags matching its patterns.
+type immutableTagsRepository struct {
+	distribution.Repository
+	patterns []string
+}
+
+var _ distribution.Repository = &immutableTagsRepository{}
+
+// newImmutableTagsRepository wraps the repository, protecting the tags
+// matching the patterns listed under the "tags" option. Patterns use the
+// syntax of path.Match.
+func newImmutableTagsRepository(repository distribution.Repository, options map[string]interface{}) (distribution.Repository, error) {
+	patterns, err := parsePatterns(options["tags"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &immutableTagsRepository{
+		Repository: repository,
+		patterns:   patterns,
+	}, nil
+}
+
+// parsePatterns reads and validates the list of tag patterns.
+func parsePatterns(v interface{}) ([]string, error) {
+	var patterns []string
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("no tags provided")
+	case []string:
+		patterns = v
+	case []interface{}:
+		for _, item := range v {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("tag pattern must be a string: %v", item)
+			}
+			patterns = append(patterns, pattern)
+		}
+	default:
+		return nil, fmt.Errorf("tags must be a list of patterns")
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tag pattern %q: %v", pattern, err)
+		}
+	}
+
+	return patterns, nil
+}
+
+// immutable returns true if the tag matches one of the patterns.
+func (repo *immutableTagsRepository) immutable(tag string) bool {
+	for _, pattern := range repo.patterns {
+		if matched, _ := path.Match(pattern, tag); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (repo *immutableTagsRepository) Manifests() distribution.ManifestService {
+	return &immutableTagsManifestService{
+		ManifestService: repo.Repository.Manifests(),
+		repo:            repo,
+	}
+}
+
+type immutableTagsManifestService struct {
+	distribution.ManifestService
+	repo *immutableTagsRepository
+}
+
+// tagResolver is implemented by the manifest services able to resolve a tag
+// to the digest of its revision without reading the manifest, such as the
+// one of the storage package.
+type tagResolver interface {
+	ResolveTag(tag string) (digest.Digest, error)
+}
+
+// Put refuses to move an immutable tag to a different revision. Pushing the
+// revision that the tag already references is allowed, so that pushes can be
+// retried.
+//
+// The check and the put are not atomic. Two pushes of different revisions
+// to a new immutable tag, racing each other or made through registry
+// instances sharing the storage, can both succeed, the last one winning.
+func (ms *immutableTagsManifestService) Put(m distribution.Manifest, tag string) error {
+	if tag == "" || !ms.repo.immutable(tag) {
+		return ms.ManifestService.Put(m, tag)
+	}
+
+	desc, err := m.Descriptor()
+	if err != nil {
+		return err
+	}
+
+	revision, err := ms.revision(tag)
+	if err != nil {
+		return err
+	}
+
+	if revision != "" && revision != desc.Digest {
+		return ms.errTagImmutable(tag, revision)
+	}
+
+	return ms.ManifestService.Put(m, tag)
+}
+
+// DeleteByTag refuses to remove an immutable tag, since it could then be
+// pushed again with another revision.
+func (ms *immutableTagsManifestService) DeleteByTag(tag string) error {
+	if ms.repo.immutable(tag) {
+		revision, err := ms.revision(tag)
+		if err != nil {
+			return err
+		}
+
+		if revision != "" {
+			return ms.errTagImmutable(tag, revision)
+		}
+	}
+
+	return ms.ManifestService.DeleteByTag(tag)
+}
+
+// Delete refuses to remove a revision referenced by an immutable tag, as that
+// removes the tag along with it. Only the tags matching the patterns are
+// resolved.
+func (ms *immutableTagsManifestService) Delete(dgst digest.Digest) error {
+	tags, err := ms.ManifestService.Tags()
+	if err != nil {
+		if _, ok := err.(distribution.ErrRepositoryUnknown); !ok {
+			return err
+		}
+	}
+
+	for _, tag := range tags {
+		if !ms.repo.immutable(tag) {
+			continue
+		}
+
+		revision, err := ms.revision(tag)
+		if err != nil {
+			return err
+		}
+
+		if revision == dgst {
+			return ms.errTagImmutable(tag, revision)
+		}
+	}
+
+	return ms.ManifestService.Delete(dgst)
+}
+
+// revision returns the digest of the revision referenced by the tag, or an
+// empty digest if the tag does not exist. The manifest is only read if the
+// wrapped manifest service cannot resolve the tag itself.
+func (ms *immutableTagsManifestService) revision(tag string) (digest.Digest, error) {
+	if resolver, ok := ms.ManifestService.(tagResolver); ok {
+		revision, err := resolver.ResolveTag(tag)
+		if err != nil {
+			if _, ok := err.(distribution.ErrManifestUnknown); ok {
+				return "", nil
+			}
+
+			return "", err
+		}
+
+		return revision, nil
+	}
+
+	m, err := ms.ManifestService.GetByTag(tag)
+	if err != nil {
+		if _, ok := err.(distribution.ErrManifestUnknown); ok {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	desc, err := m.Descriptor()
+	if err != nil {
+		return "", err
+	}
+
+	return desc.Digest, nil
+}
+
+func (ms *immutableTagsManifestService) errTagImmutable(tag string, revision digest.Digest) error {
+	return distribution.ErrTagImmutable{
+		Name:     ms.repo.Name(),
+		Tag:      tag,
+		Revision: revision,
+	}
+}
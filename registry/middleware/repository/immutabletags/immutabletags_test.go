@@ -0,0 +1,190 @@
+package immutabletags
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/schema2"
+	middleware "github.com/docker/distribution/registry/middleware/repository"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func makeManifest(t *testing.T, config digest.Digest) (*schema2.DeserializedManifest, digest.Digest) {
+	m, err := schema2.FromStruct(schema2.Manifest{
+		SchemaVersion: schema2.SchemaVersion,
+		MediaType:     schema2.MediaTypeManifest,
+		Config: schema2.Descriptor{
+			MediaType: schema2.MediaTypeConfig,
+			Digest:    config,
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating manifest: %v", err)
+	}
+
+	desc, err := m.Descriptor()
+	if err != nil {
+		t.Fatalf("error getting manifest descriptor: %v", err)
+	}
+
+	return m, desc.Digest
+}
+
+func checkImmutable(t *testing.T, err error, tag string, revision digest.Digest) {
+	immutable, ok := err.(distribution.ErrTagImmutable)
+	if !ok {
+		t.Fatalf("expected immutable tag error for %s, got %v", tag, err)
+	}
+
+	if immutable.Name != "foo/bar" || immutable.Tag != tag || immutable.Revision != revision {
+		t.Fatalf("unexpected immutable tag error: %#v", immutable)
+	}
+}
+
+func TestImmutableTags(t *testing.T) {
+	ctx := context.Background()
+
+	// Layers are not needed to exercise tagging.
+	registry := storage.NewRegistryWithDriverSkipLayerVerification(ctx, inmemory.New(), nil)
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	repo, err = middleware.Get("immutabletags", map[string]interface{}{
+		"tags": []interface{}{"v*"},
+	}, repo)
+	if err != nil {
+		t.Fatalf("unexpected error creating middleware: %v", err)
+	}
+
+	ms := repo.Manifests()
+	m1, dgst1 := makeManifest(t, "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b")
+	m2, dgst2 := makeManifest(t, "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b")
+
+	if err := ms.Put(m1, "v1"); err != nil {
+		t.Fatalf("unexpected error pushing new immutable tag: %v", err)
+	}
+
+	// Pushing the same revision again is allowed.
+	if err := ms.Put(m1, "v1"); err != nil {
+		t.Fatalf("unexpected error pushing immutable tag again: %v", err)
+	}
+
+	checkImmutable(t, ms.Put(m2, "v1"), "v1", dgst1)
+
+	// Other tags can still be moved.
+	if err := ms.Put(m2, "latest"); err != nil {
+		t.Fatalf("unexpected error pushing mutable tag: %v", err)
+	}
+
+	if err := ms.Put(m1, "latest"); err != nil {
+		t.Fatalf("unexpected error moving mutable tag: %v", err)
+	}
+
+	m, err := ms.GetByTag("v1")
+	if err != nil {
+		t.Fatalf("unexpected error getting immutable tag: %v", err)
+	}
+
+	if desc, err := m.Descriptor(); err != nil || desc.Digest != dgst1 {
+		t.Fatalf("immutable tag was moved: %v, %v", desc.Digest, err)
+	}
+
+	checkImmutable(t, ms.DeleteByTag("v1"), "v1", dgst1)
+	checkImmutable(t, ms.Delete(dgst1), "v1", dgst1)
+
+	if err := ms.DeleteByTag("latest"); err != nil {
+		t.Fatalf("unexpected error deleting mutable tag: %v", err)
+	}
+
+	if err := ms.Delete(dgst2); err != nil {
+		t.Fatalf("unexpected error deleting revision without immutable tags: %v", err)
+	}
+
+	// Unknown immutable tags are reported as such.
+	if err := ms.DeleteByTag("v2"); err == nil {
+		t.Fatalf("expected error deleting unknown tag")
+	} else if _, ok := err.(distribution.ErrManifestUnknown); !ok {
+		t.Fatalf("unexpected error deleting unknown tag: %v", err)
+	}
+}
+
+// resolveCountingManifestService records the tags resolved through it and
+// counts the manifests read by tag.
+type resolveCountingManifestService struct {
+	distribution.ManifestService
+	resolved []string
+	gets     int
+}
+
+func (ms *resolveCountingManifestService) ResolveTag(tag string) (digest.Digest, error) {
+	ms.resolved = append(ms.resolved, tag)
+	return ms.ManifestService.(tagResolver).ResolveTag(tag)
+}
+
+func (ms *resolveCountingManifestService) GetByTag(tag string) (distribution.Manifest, error) {
+	ms.gets++
+	return ms.ManifestService.GetByTag(tag)
+}
+
+func TestImmutableTagsResolveLinks(t *testing.T) {
+	ctx := context.Background()
+
+	registry := storage.NewRegistryWithDriverSkipLayerVerification(ctx, inmemory.New(), nil)
+	repo, err := registry.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	if _, ok := repo.Manifests().(tagResolver); !ok {
+		t.Fatalf("storage manifest service does not resolve tags")
+	}
+
+	counting := &resolveCountingManifestService{ManifestService: repo.Manifests()}
+	ms := &immutableTagsManifestService{
+		ManifestService: counting,
+		repo:            &immutableTagsRepository{Repository: repo, patterns: []string{"v*"}},
+	}
+
+	m1, dgst1 := makeManifest(t, "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b")
+	m2, dgst2 := makeManifest(t, "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b")
+
+	for tag, m := range map[string]distribution.Manifest{"v1": m1, "latest": m2, "stable": m2} {
+		if err := ms.Put(m, tag); err != nil {
+			t.Fatalf("unexpected error pushing %s: %v", tag, err)
+		}
+	}
+
+	checkImmutable(t, ms.Delete(dgst1), "v1", dgst1)
+
+	// Only the immutable tags are resolved when deleting a revision.
+	counting.resolved = nil
+	if err := ms.Delete(dgst2); err != nil {
+		t.Fatalf("unexpected error deleting revision without immutable tags: %v", err)
+	}
+
+	if len(counting.resolved) != 1 || counting.resolved[0] != "v1" {
+		t.Fatalf("unexpected tags resolved: %v", counting.resolved)
+	}
+
+	if counting.gets != 0 {
+		t.Fatalf("tags were resolved by reading %d manifests", counting.gets)
+	}
+}
+
+func TestImmutableTagsOptions(t *testing.T) {
+	for _, options := range []map[string]interface{}{
+		{},
+		{"tags": "v*"},
+		{"tags": []interface{}{1}},
+		{"tags": []interface{}{"v["}},
+	} {
+		if _, err := newImmutableTagsRepository(nil, options); err == nil {
+			t.Fatalf("expected error with options %v", options)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/client"
+)
+
+// proxyBlobStore serves blobs from local storage, fetching them from the
+// remote registry on a miss. Fetched content is streamed to the client and
+// committed to local storage at the same time.
+type proxyBlobStore struct {
+	repositoryName string
+	localStore     distribution.BlobStore
+	registry       *proxyingRegistry
+}
+
+var _ distribution.BlobStore = &proxyBlobStore{}
+
+func (pbs *proxyBlobStore) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	desc, err := pbs.localStore.Stat(ctx, dgst)
+	if err != distribution.ErrBlobUnknown {
+		return desc, err
+	}
+
+	length, err := pbs.registry.remote.BlobLength(pbs.repositoryName, dgst)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	if length < 0 {
+		return distribution.Descriptor{}, distribution.ErrBlobUnknown
+	}
+
+	return distribution.Descriptor{
+		Digest:    dgst,
+		Length:    int64(length),
+		MediaType: "application/octet-stream",
+	}, nil
+}
+
+func (pbs *proxyBlobStore) Get(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+	if err := pbs.ensureLocal(ctx, dgst); err != nil {
+		return nil, err
+	}
+
+	return pbs.localStore.Get(ctx, dgst)
+}
+
+func (pbs *proxyBlobStore) Open(ctx context.Context, dgst digest.Digest) (distribution.ReadSeekCloser, error) {
+	if err := pbs.ensureLocal(ctx, dgst); err != nil {
+		return nil, err
+	}
+
+	return pbs.localStore.Open(ctx, dgst)
+}
+
+func (pbs *proxyBlobStore) ServeBlob(ctx context.Context, w http.ResponseWriter, r *http.Request, dgst digest.Digest) error {
+	err := pbs.localStore.ServeBlob(ctx, w, r, dgst)
+	if err != distribution.ErrBlobUnknown {
+		return err
+	}
+
+	remoteReader, length, err := pbs.openRemote(dgst)
+	if err != nil {
+		return err
+	}
+	defer remoteReader.Close()
+
+	w.Header().Set("Content-Length", strconv.FormatInt(int64(length), 10))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Etag", dgst.String())
+
+	// 一边返回给客户端，一边写入本地存储
+	if err := pbs.fetch(ctx, dgst, remoteReader, w); err != nil {
+		// The response has already started, so the error can't be
+		// reported to the client.
+		context.GetLogger(ctx).Errorf("error serving blob %v from remote: %v", dgst, err)
+	}
+
+	return nil
+}
+
+// ensureLocal fetches the blob from the remote into local storage if it is
+// not already present.
+func (pbs *proxyBlobStore) ensureLocal(ctx context.Context, dgst digest.Digest) error {
+	_, err := pbs.localStore.Stat(ctx, dgst)
+	if err != distribution.ErrBlobUnknown {
+		return err
+	}
+
+	remoteReader, _, err := pbs.openRemote(dgst)
+	if err != nil {
+		return err
+	}
+	defer remoteReader.Close()
+
+	return pbs.fetch(ctx, dgst, remoteReader, ioutil.Discard)
+}
+
+// openRemote opens the blob on the remote registry.
+func (pbs *proxyBlobStore) openRemote(dgst digest.Digest) (io.ReadCloser, int, error) {
+	rc, length, err := pbs.registry.remote.GetBlob(pbs.repositoryName, dgst, 0)
+	if err != nil {
+		if _, ok := err.(*client.BlobNotFoundError); ok {
+			return nil, 0, distribution.ErrBlobUnknown
+		}
+		return nil, 0, err
+	}
+
+	return rc, length, nil
+}
+
+// fetch copies the remote content to w, committing it to local storage
+// along the way. If the blob is already being fetched by another request, the
+// content is only copied to w.
+func (pbs *proxyBlobStore) fetch(ctx context.Context, dgst digest.Digest, remoteReader io.Reader, w io.Writer) error {
+	key := pbs.repositoryName + "@" + dgst.String()
+	if !pbs.registry.inflight.acquire(key) {
+		_, err := io.Copy(w, remoteReader)
+		return err
+	}
+	defer pbs.registry.inflight.release(key)
+
+	bw, err := pbs.localStore.Create(ctx)
+	if err != nil {
+		return err
+	}
+	defer bw.Close()
+
+	if _, err := io.Copy(io.MultiWriter(w, bw), remoteReader); err != nil {
+		bw.Cancel(ctx)
+		return err
+	}
+
+	if _, err := bw.Commit(ctx, distribution.Descriptor{Digest: dgst}); err != nil {
+		// The content has already been sent, so the client has to verify the
+		// digest itself. Nothing is stored locally.
+		bw.Cancel(ctx)
+		return fmt.Errorf("error storing blob %v from remote: %v", dgst, err)
+	}
+
+	return nil
+}
+
+// The pull through cache is read-only; content is only written when fetched
+// from the remote.
+
+func (pbs *proxyBlobStore) Put(ctx context.Context, mediaType string, p []byte) (distribution.Descriptor, error) {
+	return distribution.Descriptor{}, distribution.ErrUnsupported
+}
+
+func (pbs *proxyBlobStore) Create(ctx context.Context) (distribution.BlobWriter, error) {
+	return nil, distribution.ErrUnsupported
+}
+
+func (pbs *proxyBlobStore) Resume(ctx context.Context, id string) (distribution.BlobWriter, error) {
+	return nil, distribution.ErrUnsupported
+}
+
+func (pbs *proxyBlobStore) Mount(ctx context.Context, sourceRepo string, dgst digest.Digest) (distribution.Descriptor, error) {
+	return distribution.Descriptor{}, distribution.ErrUnsupported
+}
+
+func (pbs *proxyBlobStore) Delete(ctx context.Context, dgst digest.Digest) error {
+	return distribution.ErrUnsupported
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/client"
+)
+
+// proxyManifestStore serves manifests from local storage, fetching them from
+// the remote registry when they are missing or, for tags, when the local copy
+// is older than the configured ttl.
+type proxyManifestStore struct {
+	ctx            context.Context
+	repositoryName string
+	localManifests distribution.ManifestService
+	registry       *proxyingRegistry
+}
+
+var _ distribution.ManifestService = &proxyManifestStore{}
+
+func (pms *proxyManifestStore) Exists(dgst digest.Digest) (bool, error) {
+	exists, err := pms.localManifests.Exists(dgst)
+	if err != nil || exists {
+		return exists, err
+	}
+
+	if _, err := pms.Get(dgst); err != nil {
+		switch err.(type) {
+		case distribution.ErrManifestUnknownRevision:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (pms *proxyManifestStore) Get(dgst digest.Digest) (distribution.Manifest, error) {
+	local, err := pms.localManifests.Get(dgst)
+	if err == nil {
+		return local, nil
+	}
+
+	if _, ok := err.(distribution.ErrManifestUnknownRevision); !ok {
+		return nil, err
+	}
+
+	sm, err := pms.registry.remote.GetImageManifest(pms.repositoryName, dgst.String())
+	if err != nil {
+		if _, ok := err.(*client.ImageManifestNotFoundError); ok {
+			return nil, distribution.ErrManifestUnknownRevision{Name: pms.repositoryName, Revision: dgst}
+		}
+		return nil, err
+	}
+
+	// Content fetched by digest is only trusted if it matches the digest.
+	fetched, err := sm.Descriptor()
+	if err != nil {
+		return nil, err
+	}
+
+	if fetched.Digest != dgst {
+		context.GetLogger(pms.ctx).Errorf("remote manifest digest does not match: %v != %v", fetched.Digest, dgst)
+		return nil, distribution.ErrManifestUnknownRevision{Name: pms.repositoryName, Revision: dgst}
+	}
+
+	pms.store(sm)
+	return sm, nil
+}
+
+func (pms *proxyManifestStore) Tags() ([]string, error) {
+	tags, err := pms.registry.remote.ListImageTags(pms.repositoryName)
+	if err != nil {
+		context.GetLogger(pms.ctx).Warnf("error listing remote tags for %s, using local tags: %v", pms.repositoryName, err)
+		return pms.localManifests.Tags()
+	}
+
+	return tags, nil
+}
+
+func (pms *proxyManifestStore) ExistsByTag(tag string) (bool, error) {
+	if _, err := pms.GetByTag(tag); err != nil {
+		switch err.(type) {
+		case distribution.ErrManifestUnknown:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (pms *proxyManifestStore) GetByTag(tag string) (distribution.Manifest, error) {
+	local, localErr := pms.localManifests.GetByTag(tag)
+	if localErr == nil && pms.registry.tags.fresh(pms.repositoryName, tag, pms.registry.ttl) {
+		return local, nil
+	}
+
+	// The tag is either missing locally or needs to be revalidated.
+	// 本地没有或者已经过期，从远端获取
+	sm, err := pms.registry.remote.GetImageManifest(pms.repositoryName, tag)
+	if err != nil {
+		if _, ok := err.(*client.ImageManifestNotFoundError); ok {
+			return nil, distribution.ErrManifestUnknown{Name: pms.repositoryName, Tag: tag}
+		}
+
+		// Serve stale content rather than failing when the remote is
+		// unavailable.
+		if localErr == nil {
+			context.GetLogger(pms.ctx).Warnf("error revalidating %s:%s, serving local copy: %v", pms.repositoryName, tag, err)
+			return local, nil
+		}
+
+		return nil, err
+	}
+
+	if sm.Tag != tag {
+		context.GetLogger(pms.ctx).Errorf("remote manifest tag does not match: %q != %q", sm.Tag, tag)
+		return nil, distribution.ErrManifestUnknown{Name: pms.repositoryName, Tag: tag}
+	}
+
+	if pms.store(sm) {
+		pms.registry.tags.touch(pms.repositoryName, tag)
+	}
+
+	return sm, nil
+}
+
+// store saves a manifest fetched from the remote into local storage,
+// returning true on success. Failures are logged but not returned, since the
+// manifest can still be served to the client.
+func (pms *proxyManifestStore) store(sm *manifest.SignedManifest) bool {
+	if err := pms.localManifests.Put(sm, sm.Tag); err != nil {
+		context.GetLogger(pms.ctx).Errorf("error storing manifest %s:%s from remote: %v", pms.repositoryName, sm.Tag, err)
+		return false
+	}
+
+	return true
+}
+
+// The pull through cache is read-only; content is only written when fetched
+// from the remote.
+
+func (pms *proxyManifestStore) Put(manifest distribution.Manifest, tag string) error {
+	return distribution.ErrUnsupported
+}
+
+func (pms *proxyManifestStore) Delete(dgst digest.Digest) error {
+	return distribution.ErrUnsupported
+}
+
+func (pms *proxyManifestStore) DeleteByTag(tag string) error {
+	return distribution.ErrUnsupported
+}
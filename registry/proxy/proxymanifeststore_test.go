@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/distribution/testutil"
+	"github.com/docker/libtrust"
+)
+
+// fakeRemote serves manifests by tag, counting the fetches. Calls to methods
+// that are not implemented will panic.
+type fakeRemote struct {
+	client.Client
+	manifests map[string]*manifest.SignedManifest
+	fetches   int
+	err       error
+}
+
+func (fr *fakeRemote) GetImageManifest(name, tag string) (*manifest.SignedManifest, error) {
+	fr.fetches++
+	if fr.err != nil {
+		return nil, fr.err
+	}
+
+	sm, ok := fr.manifests[name+":"+tag]
+	if !ok {
+		return nil, &client.ImageManifestNotFoundError{Name: name, Tag: tag}
+	}
+
+	return sm, nil
+}
+
+func newTestProxyRegistry(remote client.Client, ttl time.Duration) *proxyingRegistry {
+	ctx := context.Background()
+	embedded := storage.NewRegistryWithDriverSkipLayerVerification(ctx, inmemory.New(), nil)
+
+	return &proxyingRegistry{
+		embedded: embedded,
+		remote:   remote,
+		ttl:      ttl,
+		tags:     &tagFreshness{validated: make(map[string]time.Time)},
+		inflight: &inflightBlobs{fetching: make(map[string]struct{})},
+	}
+}
+
+func makeSignedManifest(t *testing.T, name, tag string) *manifest.SignedManifest {
+	_, dgst, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("unexpected error generating test layer file: %v", err)
+	}
+
+	m := manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: name,
+		Tag:  tag,
+		FSLayers: []manifest.FSLayer{
+			{BlobSum: digest.Digest(dgst)},
+		},
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	sm, err := manifest.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	return sm
+}
+
+func TestProxyManifestTTL(t *testing.T) {
+	ctx := context.Background()
+	name, tag := "foo/bar", "latest"
+
+	remote := &fakeRemote{
+		manifests: map[string]*manifest.SignedManifest{
+			name + ":" + tag: makeSignedManifest(t, name, tag),
+		},
+	}
+
+	pr := newTestProxyRegistry(remote, time.Hour)
+	repo, err := pr.Repository(ctx, name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+	manifests := repo.Manifests()
+
+	// The first fetch goes to the remote; the second is served locally.
+	for i := 0; i < 2; i++ {
+		m, err := manifests.GetByTag(tag)
+		if err != nil {
+			t.Fatalf("unexpected error fetching manifest: %v", err)
+		}
+
+		if !bytes.Equal(m.Content(), remote.manifests[name+":"+tag].Raw) {
+			t.Fatalf("fetched manifest does not match remote")
+		}
+	}
+
+	if remote.fetches != 1 {
+		t.Fatalf("expected 1 remote fetch, got %d", remote.fetches)
+	}
+
+	// Fetching by digest is served from local storage.
+	desc, err := remote.manifests[name+":"+tag].Descriptor()
+	if err != nil {
+		t.Fatalf("unexpected error digesting manifest: %v", err)
+	}
+
+	if _, err := manifests.Get(desc.Digest); err != nil {
+		t.Fatalf("unexpected error fetching manifest by digest: %v", err)
+	}
+
+	if remote.fetches != 1 {
+		t.Fatalf("expected fetch by digest to be served locally, got %d remote fetches", remote.fetches)
+	}
+
+	// Once the ttl has expired, the tag is revalidated against the remote.
+	updated := makeSignedManifest(t, name, tag)
+	remote.manifests[name+":"+tag] = updated
+	pr.ttl = time.Nanosecond
+
+	m, err := manifests.GetByTag(tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest: %v", err)
+	}
+
+	if remote.fetches != 2 {
+		t.Fatalf("expected 2 remote fetches, got %d", remote.fetches)
+	}
+
+	if !bytes.Equal(m.Content(), updated.Raw) {
+		t.Fatalf("expected updated manifest after ttl expiry")
+	}
+
+	// When the remote is unavailable, the stale local copy is served.
+	remote.err = fmt.Errorf("remote unavailable")
+
+	m, err = manifests.GetByTag(tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest with remote unavailable: %v", err)
+	}
+
+	if !bytes.Equal(m.Content(), updated.Raw) {
+		t.Fatalf("expected local manifest when remote is unavailable")
+	}
+
+	// Unknown tags are reported as such.
+	remote.err = nil
+	if _, err := manifests.GetByTag("unknown"); true {
+		switch err.(type) {
+		case distribution.ErrManifestUnknown:
+		default:
+			t.Fatalf("expected manifest unknown error: %#v", err)
+		}
+	}
+
+	// The cache is read-only.
+	if err := manifests.Put(updated, tag); err != distribution.ErrUnsupported {
+		t.Fatalf("expected unsupported error putting manifest: %v", err)
+	}
+}
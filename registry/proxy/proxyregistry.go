@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/client"
+)
+
+// defaultTTL is used when the proxy configuration does not specify how long
+// manifests fetched by tag may be served before they are revalidated.
+const defaultTTL = 5 * time.Minute
+
+// proxyingRegistry fetches content from a remote registry and caches it in
+// the embedded local registry.
+// 拉取缓存：本地没有的内容从远端 registry 获取并存储到本地
+type proxyingRegistry struct {
+	embedded distribution.Namespace // provides local registry functionality
+	remote   client.Client
+	ttl      time.Duration
+
+	// tags records when a tag was last validated against the remote.
+	tags *tagFreshness
+
+	// inflight tracks blobs that are currently being fetched from the
+	// remote, so concurrent requests don't store the same content twice.
+	inflight *inflightBlobs
+}
+
+var _ distribution.Namespace = &proxyingRegistry{}
+
+// NewRegistryPullThroughCache creates a registry acting as a pull through
+// cache of the remote registry described by config. The local registry,
+// embedded, is used to store fetched content and must accept manifests
+// before their layers are present.
+func NewRegistryPullThroughCache(ctx context.Context, embedded distribution.Namespace, config configuration.Proxy) (distribution.Namespace, error) {
+	var transport http.RoundTripper
+	if config.Username != "" {
+		transport = &basicAuthTransport{
+			username: config.Username,
+			password: config.Password,
+		}
+	}
+
+	remote, err := client.NewWithTransport(config.RemoteURL, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	context.GetLogger(ctx).Infof("starting pull through cache from %s", config.RemoteURL)
+
+	return &proxyingRegistry{
+		embedded: embedded,
+		remote:   remote,
+		ttl:      ttl,
+		tags:     &tagFreshness{validated: make(map[string]time.Time)},
+		inflight: &inflightBlobs{fetching: make(map[string]struct{})},
+	}, nil
+}
+
+func (pr *proxyingRegistry) Scope() distribution.Scope {
+	return distribution.GlobalScope
+}
+
+func (pr *proxyingRegistry) Catalog(ctx context.Context) distribution.CatalogService {
+	// Only the locally cached repositories are listed.
+	return pr.embedded.Catalog(ctx)
+}
+
+func (pr *proxyingRegistry) Repository(ctx context.Context, name string) (distribution.Repository, error) {
+	localRepo, err := pr.embedded.Repository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxiedRepository{
+		Repository: localRepo,
+		ctx:        ctx,
+		registry:   pr,
+	}, nil
+}
+
+// proxiedRepository serves manifests and blobs through the pull through
+// cache. Signatures are served from local storage.
+type proxiedRepository struct {
+	distribution.Repository
+	ctx      context.Context
+	registry *proxyingRegistry
+}
+
+func (pr *proxiedRepository) Manifests() distribution.ManifestService {
+	return &proxyManifestStore{
+		ctx:            pr.ctx,
+		repositoryName: pr.Name(),
+		localManifests: pr.Repository.Manifests(),
+		registry:       pr.registry,
+	}
+}
+
+func (pr *proxiedRepository) Blobs(ctx context.Context) distribution.BlobStore {
+	return &proxyBlobStore{
+		repositoryName: pr.Name(),
+		localStore:     pr.Repository.Blobs(ctx),
+		registry:       pr.registry,
+	}
+}
+
+// tagFreshness records the time each tag was last fetched from the remote.
+type tagFreshness struct {
+	mu        sync.Mutex
+	validated map[string]time.Time
+}
+
+// fresh returns true if the tag was validated within ttl.
+func (tf *tagFreshness) fresh(name, tag string, ttl time.Duration) bool {
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+
+	validated, ok := tf.validated[name+":"+tag]
+	return ok && time.Since(validated) < ttl
+}
+
+// touch marks the tag as validated now.
+func (tf *tagFreshness) touch(name, tag string) {
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+
+	tf.validated[name+":"+tag] = time.Now()
+}
+
+// inflightBlobs is the set of blobs currently being fetched into local
+// storage.
+type inflightBlobs struct {
+	mu       sync.Mutex
+	fetching map[string]struct{}
+}
+
+// acquire returns true if the caller is now responsible for storing the
+// blob. It returns false if another request is already storing it.
+func (ib *inflightBlobs) acquire(key string) bool {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	if _, ok := ib.fetching[key]; ok {
+		return false
+	}
+	ib.fetching[key] = struct{}{}
+	return true
+}
+
+func (ib *inflightBlobs) release(key string) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	delete(ib.fetching, key)
+}
+
+// basicAuthTransport adds basic auth credentials to each request sent to the
+// remote registry.
+type basicAuthTransport struct {
+	username string
+	password string
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Copy the request, as a RoundTripper must not modify it.
+	authReq := new(http.Request)
+	*authReq = *req
+	authReq.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		authReq.Header[k] = v
+	}
+	authReq.SetBasicAuth(t.username, t.password)
+
+	return http.DefaultTransport.RoundTrip(authReq)
+}
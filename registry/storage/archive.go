@@ -0,0 +1,434 @@
+package storage
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// archiveIndexName is the name of the archive member describing its content.
+// It is the first member of the archive.
+const archiveIndexName = "index.json"
+
+// RepositoryArchive summarizes the content exported to or imported from an
+// archive.
+type RepositoryArchive struct {
+	Repository string `json:"repository"`
+	Manifests  int    `json:"manifests"`
+	Blobs      int    `json:"blobs"`
+	Tags       int    `json:"tags"`
+
+	// Bytes is the size of the blob data.
+	Bytes int64 `json:"bytes"`
+}
+
+// archiveIndex describes the content of an archive. The blobs and the
+// manifests follow it, under blobs/<algorithm>/<hex digest> and
+// manifests/<algorithm>/<hex digest>.
+type archiveIndex struct {
+	Repository string `json:"repository"`
+
+	// Blobs lists the blobs referenced by the manifests, each once. A blob
+	// is keyed by the digest that references it, which may be a tarsum.
+	Blobs []distribution.Descriptor `json:"blobs"`
+
+	// Manifests lists the manifests, with their media type, so that the
+	// manifests referenced by a manifest list come before it.
+	Manifests []distribution.Descriptor `json:"manifests"`
+
+	// Tags maps each tag to the manifest it references.
+	Tags map[string]digest.Digest `json:"tags"`
+}
+
+// archiveMemberName returns the name of the member holding the blob or the
+// manifest identified by dgst.
+func archiveMemberName(dir string, dgst digest.Digest) string {
+	return path.Join(dir, dgst.Algorithm(), dgst.Hex())
+}
+
+// ExportRepository writes the manifests, tags and blobs of the named
+// repository to w, as a tar archive. If tag is not empty, only the tag and
+// the manifest it references are exported. Otherwise, every manifest
+// revision of the repository is exported, tagged or not. Schema1 manifests
+// are exported with all their signatures. Blob data is read from the blob
+// store and written once, however many manifests reference it.
+// 将 repository 导出为 tar 归档
+func ExportRepository(ctx context.Context, registry distribution.Namespace, driver storageDriver.StorageDriver, name, tag string, w io.Writer) (RepositoryArchive, error) {
+	exported := RepositoryArchive{Repository: name}
+
+	repo, err := registry.Repository(ctx, name)
+	if err != nil {
+		return exported, err
+	}
+
+	if exists, err := repositoryExists(ctx, driver, name); err != nil {
+		return exported, err
+	} else if !exists {
+		return exported, distribution.ErrRepositoryUnknown{Name: name}
+	}
+
+	index := archiveIndex{
+		Repository: name,
+		Blobs:      []distribution.Descriptor{},
+		Manifests:  []distribution.Descriptor{},
+		Tags:       make(map[string]digest.Digest),
+	}
+
+	var tags []string
+	if tag != "" {
+		tags = []string{tag}
+	} else {
+		tags, err = repo.Manifests().Tags()
+		if err != nil {
+			if _, ok := err.(distribution.ErrRepositoryUnknown); !ok {
+				return exported, err
+			}
+		}
+	}
+
+	var revisions []digest.Digest
+	for _, tag := range tags {
+		currentPath, err := defaultPathMapper.path(manifestTagCurrentPathSpec{name: name, tag: tag})
+		if err != nil {
+			return exported, err
+		}
+
+		content, err := driver.GetContent(ctx, currentPath)
+		if err != nil {
+			if _, ok := err.(storageDriver.PathNotFoundError); ok {
+				return exported, distribution.ErrManifestUnknown{Name: name, Tag: tag}
+			}
+			return exported, err
+		}
+
+		revision, err := digest.ParseDigest(string(content))
+		if err != nil {
+			return exported, err
+		}
+
+		index.Tags[tag] = revision
+		revisions = append(revisions, revision)
+	}
+
+	if tag == "" {
+		root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+		if err != nil {
+			return exported, err
+		}
+
+		linked, err := linkedDigests(ctx, driver, path.Join(root, name, "_manifests", "revisions"))
+		if err != nil {
+			return exported, err
+		}
+		revisions = append(revisions, linked...)
+	}
+
+	// Walk the manifests, collecting the blobs they reference and ordering
+	// manifest lists after their manifests.
+	manifests := make(map[digest.Digest]distribution.Manifest)
+	blobs := make(map[digest.Digest]struct{})
+	var visit func(revision digest.Digest) error
+	visit = func(revision digest.Digest) error {
+		if _, ok := manifests[revision]; ok {
+			return nil
+		}
+
+		m, err := repo.Manifests().Get(revision)
+		if err != nil {
+			return err
+		}
+		manifests[revision] = m
+
+		for _, reference := range m.References() {
+			isManifest, err := repo.Manifests().Exists(reference.Digest)
+			if err != nil {
+				return err
+			}
+
+			if isManifest {
+				if err := visit(reference.Digest); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, ok := blobs[reference.Digest]; ok {
+				continue
+			}
+
+			desc, err := repo.Blobs(ctx).Stat(ctx, reference.Digest)
+			if err != nil {
+				return err
+			}
+
+			blobs[reference.Digest] = struct{}{}
+			index.Blobs = append(index.Blobs, distribution.Descriptor{
+				MediaType: desc.MediaType,
+				Digest:    reference.Digest,
+				Length:    desc.Length,
+			})
+		}
+
+		desc, err := m.Descriptor()
+		if err != nil {
+			return err
+		}
+
+		index.Manifests = append(index.Manifests, distribution.Descriptor{
+			MediaType: desc.MediaType,
+			Digest:    revision,
+			Length:    int64(len(m.Content())),
+		})
+		return nil
+	}
+
+	for _, revision := range revisions {
+		if err := visit(revision); err != nil {
+			return exported, err
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	now := time.Now()
+
+	p, err := json.MarshalIndent(index, "", "   ")
+	if err != nil {
+		return exported, err
+	}
+
+	if err := writeArchiveMember(tw, archiveIndexName, now, int64(len(p)), strings.NewReader(string(p))); err != nil {
+		return exported, err
+	}
+
+	for _, desc := range index.Blobs {
+		rc, err := repo.Blobs(ctx).Open(ctx, desc.Digest)
+		if err != nil {
+			return exported, err
+		}
+
+		err = writeArchiveMember(tw, archiveMemberName("blobs", desc.Digest), now, desc.Length, rc)
+		rc.Close()
+		if err != nil {
+			return exported, err
+		}
+
+		exported.Blobs++
+		exported.Bytes += desc.Length
+	}
+
+	for _, desc := range index.Manifests {
+		content := manifests[desc.Digest].Content()
+		if err := writeArchiveMember(tw, archiveMemberName("manifests", desc.Digest), now, int64(len(content)), strings.NewReader(string(content))); err != nil {
+			return exported, err
+		}
+
+		exported.Manifests++
+	}
+	exported.Tags = len(index.Tags)
+
+	return exported, tw.Close()
+}
+
+// writeArchiveMember writes size bytes read from r to the archive, as the
+// named member.
+func writeArchiveMember(tw *tar.Writer, name string, modTime time.Time, size int64, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(tw, io.LimitReader(r, size))
+	if err != nil {
+		return err
+	}
+
+	if n != size {
+		return fmt.Errorf("short read writing %s: %d != %d", name, n, size)
+	}
+
+	return nil
+}
+
+// ImportRepository reads an archive written by ExportRepository from r,
+// committing its content to the repository it was exported from. Blobs are
+// written through the blob store, so their digests are verified and they are
+// linked into the repository. Manifests are then put through the manifest
+// store, which verifies them, including the signatures of schema1 manifests
+// and the trust policies of the registry, before the tags are applied.
+//
+// Content already present in the repository is committed again. If the
+// import fails, the content committed so far is left in place.
+// 从 tar 归档导入 repository
+func ImportRepository(ctx context.Context, registry distribution.Namespace, r io.Reader) (RepositoryArchive, error) {
+	var imported RepositoryArchive
+
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return imported, fmt.Errorf("invalid archive: %v", err)
+	}
+
+	if hdr.Name != archiveIndexName {
+		return imported, fmt.Errorf("invalid archive: %s must be the first member, found %s", archiveIndexName, hdr.Name)
+	}
+
+	var index archiveIndex
+	if err := json.NewDecoder(tr).Decode(&index); err != nil {
+		return imported, fmt.Errorf("invalid archive index: %v", err)
+	}
+	imported.Repository = index.Repository
+
+	repo, err := registry.Repository(ctx, index.Repository)
+	if err != nil {
+		return imported, err
+	}
+
+	blobs := make(map[string]distribution.Descriptor, len(index.Blobs))
+	for _, desc := range index.Blobs {
+		blobs[archiveMemberName("blobs", desc.Digest)] = desc
+	}
+
+	manifests := make(map[string]distribution.Descriptor, len(index.Manifests))
+	for _, desc := range index.Manifests {
+		manifests[archiveMemberName("manifests", desc.Digest)] = desc
+	}
+
+	// Blobs are committed as they are read. Manifests are kept until all
+	// the blobs they reference are in place.
+	contents := make(map[digest.Digest][]byte, len(index.Manifests))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return imported, fmt.Errorf("invalid archive: %v", err)
+		}
+
+		if desc, ok := blobs[hdr.Name]; ok {
+			if hdr.Size != desc.Length {
+				return imported, fmt.Errorf("invalid archive: size of %s does not match the index: %d != %d", hdr.Name, hdr.Size, desc.Length)
+			}
+
+			if err := importBlob(ctx, repo, desc, tr); err != nil {
+				return imported, err
+			}
+
+			delete(blobs, hdr.Name)
+			imported.Blobs++
+			imported.Bytes += desc.Length
+			continue
+		}
+
+		if desc, ok := manifests[hdr.Name]; ok {
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return imported, err
+			}
+
+			contents[desc.Digest] = content
+			delete(manifests, hdr.Name)
+			continue
+		}
+
+		return imported, fmt.Errorf("invalid archive: unexpected member %s", hdr.Name)
+	}
+
+	var missing []string
+	for name := range blobs {
+		missing = append(missing, name)
+	}
+	for name := range manifests {
+		missing = append(missing, name)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return imported, fmt.Errorf("invalid archive: missing %s", strings.Join(missing, ", "))
+	}
+
+	revisions := make(map[digest.Digest]distribution.Manifest, len(index.Manifests))
+	for _, desc := range index.Manifests {
+		m, err := distribution.UnmarshalManifest(desc.MediaType, contents[desc.Digest])
+		if err != nil {
+			return imported, fmt.Errorf("invalid manifest %v: %v", desc.Digest, err)
+		}
+
+		mdesc, err := m.Descriptor()
+		if err != nil {
+			return imported, err
+		}
+
+		if mdesc.Digest != desc.Digest {
+			return imported, distribution.ErrManifestVerification{
+				fmt.Errorf("digest of manifest does not match the archive: %v != %v", mdesc.Digest, desc.Digest),
+			}
+		}
+
+		if err := repo.Manifests().Put(m, ""); err != nil {
+			return imported, err
+		}
+
+		revisions[desc.Digest] = m
+		imported.Manifests++
+	}
+
+	var tags []string
+	for tag := range index.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		m, ok := revisions[index.Tags[tag]]
+		if !ok {
+			return imported, fmt.Errorf("invalid archive: tag %s references unknown manifest %v", tag, index.Tags[tag])
+		}
+
+		if err := repo.Manifests().Put(m, tag); err != nil {
+			return imported, err
+		}
+		imported.Tags++
+	}
+
+	return imported, nil
+}
+
+// importBlob writes the blob data read from r through a blob upload, which
+// verifies it against the digest and the length of desc before linking it
+// into the repository.
+func importBlob(ctx context.Context, repo distribution.Repository, desc distribution.Descriptor, r io.Reader) error {
+	bw, err := repo.Blobs(ctx).Create(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(bw, r, desc.Length); err != nil {
+		bw.Cancel(ctx)
+		return err
+	}
+
+	if _, err := bw.Commit(ctx, distribution.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Length: desc.Length}); err != nil {
+		bw.Cancel(ctx)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestExportImportRepository(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "v1")
+	v1 := putTestManifest(t, env, "v1")
+	putTestManifest(t, env, "v2")
+
+	var archive bytes.Buffer
+	exported, err := ExportRepository(env.ctx, env.registry, env.driver, env.name, "", &archive)
+	if err != nil {
+		t.Fatalf("unexpected error exporting repository: %v", err)
+	}
+
+	if exported.Manifests != 2 || exported.Tags != 2 || exported.Blobs != 4 || exported.Bytes == 0 {
+		t.Fatalf("unexpected export: %#v", exported)
+	}
+
+	newRegistry := func() (context.Context, *registry) {
+		ctx := context.Background()
+		return ctx, NewRegistryWithDriver(ctx, inmemory.New(), cache.NewInMemoryBlobDescriptorCacheProvider()).(*registry)
+	}
+
+	ctx, reg := newRegistry()
+	imported, err := ImportRepository(ctx, reg, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error importing repository: %v", err)
+	}
+
+	if imported != exported {
+		t.Fatalf("unexpected import: %#v != %#v", imported, exported)
+	}
+
+	repo, err := reg.Repository(ctx, env.name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	for _, tag := range []string{"v1", "v2"} {
+		m, err := repo.Manifests().GetByTag(tag)
+		if err != nil {
+			t.Fatalf("unexpected error getting imported tag %s: %v", tag, err)
+		}
+
+		for _, reference := range m.References() {
+			if _, err := repo.Blobs(ctx).Stat(ctx, reference.Digest); err != nil {
+				t.Fatalf("unexpected error statting imported layer %v: %v", reference.Digest, err)
+			}
+		}
+	}
+
+	report, err := CheckStorage(ctx, reg.blobStore.driver, false)
+	if err != nil {
+		t.Fatalf("unexpected error checking imported storage: %v", err)
+	}
+
+	if len(report.Inconsistencies) != 0 {
+		t.Fatalf("unexpected inconsistencies after import: %#v", report.Inconsistencies)
+	}
+
+	// Exporting a tag only includes the manifest it references.
+	archive.Reset()
+	exported, err = ExportRepository(env.ctx, env.registry, env.driver, env.name, "v1", &archive)
+	if err != nil {
+		t.Fatalf("unexpected error exporting tag: %v", err)
+	}
+
+	if exported.Manifests != 1 || exported.Tags != 1 || exported.Blobs != len(v1.FSLayers) {
+		t.Fatalf("unexpected export of tag: %#v", exported)
+	}
+
+	if _, err := ExportRepository(env.ctx, env.registry, env.driver, env.name, "unknown", ioutil.Discard); err == nil {
+		t.Fatalf("expected error exporting unknown tag")
+	}
+
+	// An archive with altered blob data is rejected.
+	var tampered bytes.Buffer
+	tr := tar.NewReader(bytes.NewReader(archive.Bytes()))
+	tw := tar.NewWriter(&tampered)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error reading archive: %v", err)
+		}
+
+		p, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("unexpected error reading archive: %v", err)
+		}
+
+		if strings.HasPrefix(hdr.Name, "blobs/") {
+			p[0] ^= 0xff
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("unexpected error writing archive: %v", err)
+		}
+
+		if _, err := tw.Write(p); err != nil {
+			t.Fatalf("unexpected error writing archive: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected error writing archive: %v", err)
+	}
+
+	ctx, reg = newRegistry()
+	if _, err := ImportRepository(ctx, reg, &tampered); err == nil {
+		t.Fatalf("expected error importing tampered archive")
+	}
+}
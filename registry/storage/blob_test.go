@@ -141,6 +141,73 @@ func TestSimpleBlobUpload(t *testing.T) {
 	}
 }
 
+// TestBlobDelete ensures that deleting a blob from one repository revokes
+// access through that repository only.
+func TestBlobDelete(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, cache.NewInMemoryBlobDescriptorCacheProvider())
+
+	var blobStores []distribution.BlobStore
+	for _, name := range []string{"foo/bar", "foo/baz"} {
+		repository, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+		blobStores = append(blobStores, repository.Blobs(ctx))
+	}
+
+	p := []byte("some blob content")
+	var desc distribution.Descriptor
+	for _, bs := range blobStores {
+		var err error
+		desc, err = bs.Put(ctx, "application/octet-stream", p)
+		if err != nil {
+			t.Fatalf("unexpected error putting blob: %v", err)
+		}
+
+		// populate the descriptor caches
+		if _, err := bs.Stat(ctx, desc.Digest); err != nil {
+			t.Fatalf("unexpected error checking for existence: %v", err)
+		}
+	}
+
+	if err := blobStores[0].Delete(ctx, desc.Digest); err != nil {
+		t.Fatalf("unexpected error deleting blob: %v", err)
+	}
+
+	if _, err := blobStores[0].Stat(ctx, desc.Digest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error after delete: %v", err)
+	}
+
+	if _, err := blobStores[0].Open(ctx, desc.Digest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error opening deleted blob: %v", err)
+	}
+
+	if err := blobStores[0].Delete(ctx, desc.Digest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error deleting blob twice: %v", err)
+	}
+
+	// The other repository must not be affected.
+	statDesc, err := blobStores[1].Stat(ctx, desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error checking for existence in other repository: %v", err)
+	}
+
+	if statDesc != desc {
+		t.Fatalf("descriptors not equal: %v != %v", statDesc, desc)
+	}
+
+	content, err := blobStores[1].Get(ctx, desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error reading blob from other repository: %v", err)
+	}
+
+	if !bytes.Equal(content, p) {
+		t.Fatalf("unexpected blob content: %q != %q", content, p)
+	}
+}
+
 // TestSimpleBlobRead just creates a simple blob file and ensures that basic
 // open, read, seek, read works. More specific edge cases should be covered in
 // other tests.
@@ -336,3 +403,167 @@ func addBlob(ctx context.Context, bs distribution.BlobIngester, desc distributio
 
 	return wr.Commit(ctx, desc)
 }
+
+func TestBlobMount(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, cache.NewInMemoryBlobDescriptorCacheProvider())
+
+	source, err := registry.Repository(ctx, "foo/source")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	target, err := registry.Repository(ctx, "foo/target")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	desc, err := source.Blobs(ctx).Put(ctx, "application/octet-stream", []byte("some blob content"))
+	if err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	bs := target.Blobs(ctx)
+	if _, err := bs.Stat(ctx, desc.Digest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error before mount: %v", err)
+	}
+
+	// Mounting from a repository that doesn't hold the blob must fail.
+	if _, err := bs.Mount(ctx, "foo/other", desc.Digest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error mounting from wrong repository: %v", err)
+	}
+
+	mounted, err := bs.Mount(ctx, source.Name(), desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error mounting blob: %v", err)
+	}
+
+	if mounted.Digest != desc.Digest || mounted.Length != desc.Length {
+		t.Fatalf("unexpected mounted descriptor: %#v != %#v", mounted, desc)
+	}
+
+	p, err := bs.Get(ctx, desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error getting mounted blob: %v", err)
+	}
+
+	if string(p) != "some blob content" {
+		t.Fatalf("unexpected mounted blob content: %q", p)
+	}
+}
+
+// TestBlobUploadDigestAlgorithms checks that blobs uploaded to a repository
+// configured for sha512 are hashed, resumed and stored under sha512 digests,
+// whatever the algorithm of the digest provided on commit.
+func TestBlobUploadDigestAlgorithms(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, cache.NewInMemoryBlobDescriptorCacheProvider(), UseDigestAlgorithms([]DigestAlgorithm{
+		{Pattern: "secure/*", Algorithm: "sha512"},
+	}))
+
+	digestOf := func(alg string, p []byte) digest.Digest {
+		digester, err := digest.NewDigesterForAlgorithm(alg)
+		if err != nil {
+			t.Fatalf("unexpected error creating digester: %v", err)
+		}
+		digester.Write(p)
+		return digester.Digest()
+	}
+
+	upload := func(name string, p []byte, dgst digest.Digest) distribution.Descriptor {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+		bs := repo.Blobs(ctx)
+
+		// Resume the upload before committing it, so that the hash state
+		// stored on close is used when available.
+		bw, err := bs.Create(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error starting upload: %v", err)
+		}
+
+		if _, err := bw.Write(p); err != nil {
+			t.Fatalf("unexpected error writing upload: %v", err)
+		}
+
+		if err := bw.Close(); err != nil {
+			t.Fatalf("unexpected error closing upload: %v", err)
+		}
+
+		bw, err = bs.Resume(ctx, bw.ID())
+		if err != nil {
+			t.Fatalf("unexpected error resuming upload: %v", err)
+		}
+
+		desc, err := bw.Commit(ctx, distribution.Descriptor{Digest: dgst})
+		if err != nil {
+			t.Fatalf("unexpected error committing upload with %v: %v", dgst, err)
+		}
+
+		// The blob is also available under the provided digest.
+		if _, err := bs.Stat(ctx, dgst); err != nil {
+			t.Fatalf("unexpected error statting blob by provided digest %v: %v", dgst, err)
+		}
+
+		blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: desc.Digest})
+		if err != nil {
+			t.Fatalf("unexpected error getting blob path: %v", err)
+		}
+
+		content, err := driver.GetContent(ctx, blobPath)
+		if err != nil || !bytes.Equal(content, p) {
+			t.Fatalf("unexpected blob content at %s: %v", blobPath, err)
+		}
+
+		return desc
+	}
+
+	p := make([]byte, 1<<16)
+	for i := range p {
+		p[i] = byte(i)
+	}
+
+	// The hash state of the first chunk is stored under the algorithm.
+	repo, err := registry.Repository(ctx, "secure/app")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	bw, err := repo.Blobs(ctx).Create(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	bw.Write(p[:10])
+	bw.Close()
+
+	hashStatePath, err := defaultPathMapper.path(uploadHashStatePathSpec{name: "secure/app", id: bw.ID(), alg: "sha512", offset: 10})
+	if err != nil {
+		t.Fatalf("unexpected error getting hash state path: %v", err)
+	}
+
+	// Without resumable digests, uploads are hashed in full on commit.
+	if bw.(*blobWriter).resumableDigester == nil {
+		t.Logf("resumable digests disabled, skipping hash state check")
+	} else if _, err := driver.Stat(ctx, hashStatePath); err != nil {
+		t.Fatalf("expected sha512 hash state at %s: %v", hashStatePath, err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		provided string
+		expected string
+	}{
+		{name: "secure/app", provided: "sha512", expected: "sha512"},
+		{name: "secure/app", provided: "sha256", expected: "sha512"},
+		{name: "foo/bar", provided: "sha512", expected: "sha256"},
+		{name: "foo/bar", provided: "sha384", expected: "sha256"},
+	} {
+		desc := upload(tc.name, p, digestOf(tc.provided, p))
+		if desc.Digest != digestOf(tc.expected, p) {
+			t.Fatalf("unexpected canonical digest for %s with %s: %v", tc.name, tc.provided, desc.Digest)
+		}
+	}
+}
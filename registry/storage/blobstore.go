@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
@@ -15,6 +17,10 @@ type blobStore struct {
 	driver  driver.StorageDriver
 	pm      *pathMapper
 	statter distribution.BlobStatter
+
+	// touchLinked, if set, records the time a blob is linked into a
+	// repository, for an online garbage collection.
+	touchLinked bool
 }
 
 var _ distribution.BlobProvider = &blobStore{}
@@ -188,3 +194,20 @@ func (bs *blobStatter) Stat(ctx context.Context, dgst digest.Digest) (distributi
 		Digest:    dgst,
 	}, nil
 }
+
+// touch records the current time in the touchedat file of the blob, if
+// touchLinked is set. An online garbage collection does not sweep a blob
+// touched during the collection, so blobs linked while it runs are kept
+// without rewriting their data.
+func (bs *blobStore) touch(ctx context.Context, dgst digest.Digest) error {
+	if !bs.touchLinked {
+		return nil
+	}
+
+	touchedAtPath, err := bs.pm.path(blobTouchedAtPathSpec{digest: dgst})
+	if err != nil {
+		return err
+	}
+
+	return bs.driver.PutContent(ctx, touchedAtPath, []byte(time.Now().UTC().Format(time.RFC3339)))
+}
@@ -54,11 +54,17 @@ func (bw *blobWriter) Commit(ctx context.Context, desc distribution.Descriptor)
 		return distribution.Descriptor{}, err
 	}
 
+	// Check the quotas before moving the data, so a rejected upload does
+	// not leave an unreferenced blob behind.
+	if err := bw.blobStore.checkQuotas(ctx, canonical); err != nil {
+		return distribution.Descriptor{}, err
+	}
+
 	if err := bw.moveBlob(ctx, canonical); err != nil {
 		return distribution.Descriptor{}, err
 	}
 
-	if err := bw.blobStore.linkBlob(ctx, canonical, desc.Digest); err != nil {
+	if err := bw.blobStore.createLinks(ctx, canonical, desc.Digest); err != nil {
 		return distribution.Descriptor{}, err
 	}
 
@@ -125,6 +131,15 @@ func (bw *blobWriter) Close() error {
 	return bw.bufferedFileWriter.Close()
 }
 
+// digestAlgorithm returns the algorithm of the canonical digest of the blob.
+func (bw *blobWriter) digestAlgorithm() string {
+	if bw.blobStore.digestAlgorithm == "" {
+		return digest.CanonicalAlgorithm
+	}
+
+	return bw.blobStore.digestAlgorithm
+}
+
 // validateBlob checks the data against the digest, returning an error if it
 // does not match. The canonical descriptor is returned.
 func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descriptor) (distribution.Descriptor, error) {
@@ -181,7 +196,7 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 
 		if canonical.Algorithm() == desc.Digest.Algorithm() {
 			// Common case: client and server prefer the same canonical digest
-			// algorithm - currently SHA256.
+			// algorithm - SHA256, unless configured for the repository.
 			verified = desc.Digest == canonical
 		} else {
 			// The client wants to use a different digest algorithm. They'll just
@@ -195,7 +210,10 @@ func (bw *blobWriter) validateBlob(ctx context.Context, desc distribution.Descri
 	}
 
 	if fullHash {
-		digester := digest.NewCanonicalDigester()
+		digester, err := digest.NewDigesterForAlgorithm(bw.digestAlgorithm())
+		if err != nil {
+			return distribution.Descriptor{}, err
+		}
 
 		digestVerifier, err := digest.NewDigestVerifier(desc.Digest)
 		if err != nil {
@@ -265,7 +283,8 @@ func (bw *blobWriter) moveBlob(ctx context.Context, desc distribution.Descriptor
 		// If the path exists, we can assume that the content has already
 		// been uploaded, since the blob storage is content-addressable.
 		// While it may be corrupted, detection of such corruption belongs
-		// elsewhere.
+		// elsewhere. The blob is touched when it is linked, for an online
+		// garbage collection.
 		return nil
 	}
 
@@ -5,5 +5,11 @@ package storage
 import "github.com/docker/distribution/digest"
 
 func (bw *blobWriter) setupResumableDigester() {
-	bw.resumableDigester = digest.NewCanonicalResumableDigester()
+	resumableDigester, err := digest.NewResumableDigester(bw.digestAlgorithm())
+	if err != nil {
+		// The upload is hashed in full when it is committed.
+		return
+	}
+
+	bw.resumableDigester = resumableDigester
 }
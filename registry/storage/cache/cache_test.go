@@ -16,6 +16,7 @@ func checkBlobDescriptorCache(t *testing.T, provider BlobDescriptorCacheProvider
 
 	checkBlobDescriptorCacheEmptyRepository(t, ctx, provider)
 	checkBlobDescriptorCacheSetAndRead(t, ctx, provider)
+	checkBlobDescriptorCacheClear(t, ctx, provider)
 }
 
 func checkBlobDescriptorCacheEmptyRepository(t *testing.T, ctx context.Context, provider BlobDescriptorCacheProvider) {
@@ -139,3 +140,55 @@ func checkBlobDescriptorCacheSetAndRead(t *testing.T, ctx context.Context, provi
 		t.Fatalf("unexpected descriptor: %#v != %#v", desc, expected)
 	}
 }
+
+func checkBlobDescriptorCacheClear(t *testing.T, ctx context.Context, provider BlobDescriptorCacheProvider) {
+	localDigest := digest.Digest("sha384:def")
+	expected := distribution.Descriptor{
+		Digest:    "sha256:def",
+		Length:    10,
+		MediaType: "application/octet-stream"}
+
+	cache, err := provider.RepositoryScoped("foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting scoped cache: %v", err)
+	}
+
+	if err := cache.SetDescriptor(ctx, localDigest, expected); err != nil {
+		t.Fatalf("error setting descriptor: %v", err)
+	}
+
+	if err := cache.Clear(ctx, ""); err != digest.ErrDigestInvalidFormat {
+		t.Fatalf("expected error clearing cache item with empty digest: %v", err)
+	}
+
+	if err := cache.Clear(ctx, localDigest); err != nil {
+		t.Fatalf("unexpected error clearing descriptor: %v", err)
+	}
+
+	if _, err := cache.Stat(ctx, localDigest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error after clear: %v", err)
+	}
+
+	if err := cache.Clear(ctx, localDigest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error clearing missing descriptor: %v", err)
+	}
+
+	// Clearing the repository entry must leave the global cache alone, since
+	// other repositories may still reference the blob.
+	desc, err := provider.Stat(ctx, localDigest)
+	if err != nil {
+		t.Fatalf("unexpected error getting global descriptor: %v", err)
+	}
+
+	if desc != expected {
+		t.Fatalf("unexpected descriptor: %#v != %#v", desc, expected)
+	}
+
+	if err := provider.Clear(ctx, localDigest); err != nil {
+		t.Fatalf("unexpected error clearing global descriptor: %v", err)
+	}
+
+	if _, err := provider.Stat(ctx, localDigest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected unknown blob error after global clear: %v", err)
+	}
+}
@@ -62,6 +62,10 @@ func (imbdcp *inMemoryBlobDescriptorCacheProvider) SetDescriptor(ctx context.Con
 	return err
 }
 
+func (imbdcp *inMemoryBlobDescriptorCacheProvider) Clear(ctx context.Context, dgst digest.Digest) error {
+	return imbdcp.global.Clear(ctx, dgst)
+}
+
 // repositoryScopedInMemoryBlobDescriptorCache provides the request scoped
 // repository cache. Instances are not thread-safe but the delegated
 // operations are.
@@ -101,6 +105,23 @@ func (rsimbdcp *repositoryScopedInMemoryBlobDescriptorCache) SetDescriptor(ctx c
 	return rsimbdcp.parent.SetDescriptor(ctx, dgst, desc)
 }
 
+// Clear removes the descriptor from the repository cache only. The global
+// cache is left intact, since the blob may be linked by other repositories.
+func (rsimbdcp *repositoryScopedInMemoryBlobDescriptorCache) Clear(ctx context.Context, dgst digest.Digest) error {
+	if rsimbdcp.repository == nil {
+		// have to read back value since we may have allocated elsewhere.
+		rsimbdcp.parent.mu.RLock()
+		rsimbdcp.repository = rsimbdcp.parent.repositories[rsimbdcp.repo]
+		rsimbdcp.parent.mu.RUnlock()
+
+		if rsimbdcp.repository == nil {
+			return distribution.ErrBlobUnknown
+		}
+	}
+
+	return rsimbdcp.repository.Clear(ctx, dgst)
+}
+
 // mapBlobDescriptorCache provides a simple map-based implementation of the
 // descriptor cache.
 type mapBlobDescriptorCache struct {
@@ -147,3 +168,19 @@ func (mbdc *mapBlobDescriptorCache) SetDescriptor(ctx context.Context, dgst dige
 	mbdc.descriptors[dgst] = desc
 	return nil
 }
+
+func (mbdc *mapBlobDescriptorCache) Clear(ctx context.Context, dgst digest.Digest) error {
+	if err := validateDigest(dgst); err != nil {
+		return err
+	}
+
+	mbdc.mu.Lock()
+	defer mbdc.mu.Unlock()
+
+	if _, ok := mbdc.descriptors[dgst]; !ok {
+		return distribution.ErrBlobUnknown
+	}
+
+	delete(mbdc.descriptors, dgst)
+	return nil
+}
@@ -119,6 +119,28 @@ func (rbds *redisBlobDescriptorService) setDescriptor(ctx context.Context, conn
 	return nil
 }
 
+// Clear removes the descriptor hash for the digest.
+func (rbds *redisBlobDescriptorService) Clear(ctx context.Context, dgst digest.Digest) error {
+	if err := validateDigest(dgst); err != nil {
+		return err
+	}
+
+	conn := rbds.pool.Get()
+	defer conn.Close()
+
+	// Not atomic in redis <= 2.3
+	reply, err := redis.Int(conn.Do("HDEL", rbds.blobDescriptorHashKey(dgst), "digest", "length", "mediatype"))
+	if err != nil {
+		return err
+	}
+
+	if reply == 0 {
+		return distribution.ErrBlobUnknown
+	}
+
+	return nil
+}
+
 func (rbds *redisBlobDescriptorService) blobDescriptorHashKey(dgst digest.Digest) string {
 	return "blobs::" + dgst.String()
 }
@@ -215,6 +237,33 @@ func (rsrbds *repositoryScopedRedisBlobDescriptorService) setDescriptor(ctx cont
 	return nil
 }
 
+// Clear removes the digest from the repository membership set, along with any
+// repository specific descriptor data. The global descriptor is left intact,
+// since the blob may be linked by other repositories.
+func (rsrbds *repositoryScopedRedisBlobDescriptorService) Clear(ctx context.Context, dgst digest.Digest) error {
+	if err := validateDigest(dgst); err != nil {
+		return err
+	}
+
+	conn := rsrbds.upstream.pool.Get()
+	defer conn.Close()
+
+	removed, err := redis.Int(conn.Do("SREM", rsrbds.repositoryBlobSetKey(rsrbds.repo), dgst))
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return distribution.ErrBlobUnknown
+	}
+
+	if _, err := conn.Do("DEL", rsrbds.blobDescriptorHashKey(dgst)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (rsrbds *repositoryScopedRedisBlobDescriptorService) blobDescriptorHashKey(dgst digest.Digest) string {
 	return "repository::" + rsrbds.repo + "::blobs::" + dgst.String()
 }
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// catalog lists the repositories in the registry by walking the repository
+// root. A directory is considered to be a repository if it contains a
+// "_manifests" directory.
+// 遍历 repository 根目录，列出所有的 repository
+type catalog struct {
+	ctx    context.Context
+	driver storageDriver.StorageDriver
+	pm     *pathMapper
+}
+
+var _ distribution.CatalogService = &catalog{}
+
+// Get returns up to n repository names, in lexical order, starting after
+// last.
+func (c *catalog) Get(n int, last string) ([]string, bool, error) {
+	repos, err := c.all()
+	if err != nil {
+		return nil, false, err
+	}
+
+	repos, more := v2.Paginate(repos, n, last)
+	return repos, more, nil
+}
+
+// all returns the sorted names of every repository in the registry.
+func (c *catalog) all() ([]string, error) {
+	root, err := c.pm.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []string
+	err = Walk(c.ctx, c.driver, root, func(fileInfo storageDriver.FileInfo) error {
+		if !fileInfo.IsDir() {
+			return nil
+		}
+
+		filePath := fileInfo.Path()
+		_, file := path.Split(filePath)
+
+		if file == "_manifests" {
+			repos = append(repos, strings.TrimPrefix(path.Dir(filePath), root+"/"))
+		}
+
+		// Reserved directories never contain nested repositories.
+		if strings.HasPrefix(file, "_") {
+			return ErrSkipDir
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if isWalkRootNotFound(err, root) {
+			return nil, nil // no repositories
+		}
+		return nil, err
+	}
+
+	sort.Strings(repos)
+	return repos, nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestCatalog(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, driver, cache.NewInMemoryBlobDescriptorCacheProvider())
+
+	catalog := registry.Catalog(ctx)
+
+	repos, more, err := catalog.Get(0, "")
+	if err != nil {
+		t.Fatalf("unexpected error listing empty catalog: %v", err)
+	}
+
+	if len(repos) != 0 || more {
+		t.Fatalf("expected empty catalog, got %v (more=%v)", repos, more)
+	}
+
+	names := []string{"foo", "foo/bar", "foo/bar/baz", "qux", "zoo/bar"}
+	for _, name := range names {
+		env := &manifestStoreTestEnv{
+			ctx:      ctx,
+			driver:   driver,
+			registry: registry,
+			name:     name,
+			tag:      "latest",
+		}
+
+		env.repository, err = registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		putTestManifest(t, env, env.tag)
+	}
+
+	// A repository with only an upload in progress has no manifests and
+	// should not be listed.
+	repo, err := registry.Repository(ctx, "uploads/only")
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+
+	if _, err := repo.Blobs(ctx).Create(ctx); err != nil {
+		t.Fatalf("unexpected error creating upload: %v", err)
+	}
+
+	repos, more, err = catalog.Get(0, "")
+	if err != nil {
+		t.Fatalf("unexpected error listing catalog: %v", err)
+	}
+
+	if !reflect.DeepEqual(repos, names) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, names)
+	}
+
+	if more {
+		t.Fatalf("unexpected more results for unpaginated catalog")
+	}
+
+	// Walk the catalog in pages of two.
+	var (
+		paged []string
+		last  string
+	)
+	for {
+		var page []string
+		page, more, err = catalog.Get(2, last)
+		if err != nil {
+			t.Fatalf("unexpected error listing catalog page: %v", err)
+		}
+
+		if len(page) > 2 {
+			t.Fatalf("page exceeds requested size: %v", page)
+		}
+
+		paged = append(paged, page...)
+		if !more {
+			break
+		}
+		last = page[len(page)-1]
+	}
+
+	if !reflect.DeepEqual(paged, names) {
+		t.Fatalf("unexpected paginated repositories: %v != %v", paged, names)
+	}
+}
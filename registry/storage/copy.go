@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/libtrust"
+)
+
+// RepositoryCopy summarizes the content copied from one repository to
+// another.
+type RepositoryCopy struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Layers    int    `json:"layers"`
+	Manifests int    `json:"manifests"`
+	Tags      int    `json:"tags"`
+}
+
+// CopyRepository copies the repository named from into a new repository named
+// to, within the same storage. The layers of the source are linked into the
+// destination and its manifest revisions and tags are recreated under the new
+// name, so no blob data is copied. Schema1 manifests embed the repository
+// name, so they are re-signed with key, changing their digest, and the
+// detached signatures of the original revisions are lost. Manifest lists
+// referencing them are rewritten to the new digests. The other manifests keep
+// their digest and any signatures attached to them.
+//
+// The destination must not exist. If the copy fails, the partial destination
+// is removed. Uploads in progress and tag history are not copied.
+// 在同一存储内复制 repository，只创建链接
+func CopyRepository(ctx context.Context, registry distribution.Namespace, driver storageDriver.StorageDriver, from, to string, key libtrust.PrivateKey) (RepositoryCopy, error) {
+	copied := RepositoryCopy{From: from, To: to}
+
+	source, err := registry.Repository(ctx, from)
+	if err != nil {
+		return copied, err
+	}
+
+	destination, err := registry.Repository(ctx, to)
+	if err != nil {
+		return copied, err
+	}
+
+	if exists, err := repositoryExists(ctx, driver, from); err != nil {
+		return copied, err
+	} else if !exists {
+		return copied, distribution.ErrRepositoryUnknown{Name: from}
+	}
+
+	if exists, err := repositoryExists(ctx, driver, to); err != nil {
+		return copied, err
+	} else if exists {
+		return copied, distribution.ErrRepositoryExists{Name: to}
+	}
+
+	copied, err = copyRepository(ctx, driver, source, destination, key)
+	if err != nil {
+		if err := removeRepository(ctx, driver, destination); err != nil {
+			context.GetLogger(ctx).Errorf("error removing partial copy of %s to %s: %v", from, to, err)
+		}
+	}
+
+	return copied, err
+}
+
+// RenameRepository copies the repository named from to a new repository
+// named to, as CopyRepository does, then removes the source. Uploads in
+// progress in the source are abandoned. The rename is not atomic: clients
+// may see both repositories while it runs.
+// 重命名 repository
+func RenameRepository(ctx context.Context, registry distribution.Namespace, driver storageDriver.StorageDriver, from, to string, key libtrust.PrivateKey) (RepositoryCopy, error) {
+	copied, err := CopyRepository(ctx, registry, driver, from, to, key)
+	if err != nil {
+		return copied, err
+	}
+
+	source, err := registry.Repository(ctx, from)
+	if err != nil {
+		return copied, err
+	}
+
+	return copied, removeRepository(ctx, driver, source)
+}
+
+// copyRepository links the layers and recreates the manifests and tags of
+// source in destination.
+func copyRepository(ctx context.Context, driver storageDriver.StorageDriver, source, destination distribution.Repository, key libtrust.PrivateKey) (RepositoryCopy, error) {
+	copied := RepositoryCopy{From: source.Name(), To: destination.Name()}
+
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return copied, err
+	}
+
+	layers, err := linkedDigests(ctx, driver, path.Join(root, source.Name(), "_layers"))
+	if err != nil {
+		return copied, err
+	}
+
+	// Layers are linked first, so that the manifests referencing them pass
+	// verification.
+	blobs := destination.Blobs(ctx)
+	canonicals := make(map[digest.Digest]struct{})
+	for _, dgst := range layers {
+		desc, err := blobs.Mount(ctx, source.Name(), dgst)
+		if err != nil {
+			return copied, err
+		}
+		canonicals[desc.Digest] = struct{}{}
+	}
+	copied.Layers = len(canonicals)
+
+	revisions, err := linkedDigests(ctx, driver, path.Join(root, source.Name(), "_manifests", "revisions"))
+	if err != nil {
+		return copied, err
+	}
+
+	// renamed maps the revisions of the source to the manifests stored in
+	// the destination.
+	renamed := make(map[digest.Digest]distribution.Manifest)
+	var copyRevision func(revision digest.Digest) (distribution.Manifest, error)
+	copyRevision = func(revision digest.Digest) (distribution.Manifest, error) {
+		if m, ok := renamed[revision]; ok {
+			return m, nil
+		}
+
+		m, err := source.Manifests().Get(revision)
+		if err != nil {
+			return nil, err
+		}
+
+		switch sm := m.(type) {
+		case *manifest.SignedManifest:
+			unsigned := sm.Manifest
+			unsigned.Name = destination.Name()
+
+			if m, err = manifest.Sign(&unsigned, key); err != nil {
+				return nil, err
+			}
+		case *manifestlist.DeserializedManifestList:
+			// The manifests of a list are copied before it, so that it
+			// passes verification. Re-signed schema1 manifests have a
+			// new digest, which the list is rewritten to reference.
+			descriptors := make([]manifestlist.ManifestDescriptor, len(sm.Manifests))
+			rewritten := false
+			for i, descriptor := range sm.Manifests {
+				child, err := copyRevision(descriptor.Digest)
+				if err != nil {
+					return nil, err
+				}
+
+				desc, err := child.Descriptor()
+				if err != nil {
+					return nil, err
+				}
+
+				if desc.Digest != descriptor.Digest {
+					descriptor.Digest = desc.Digest
+					descriptor.Size = desc.Length
+					rewritten = true
+				}
+				descriptors[i] = descriptor
+			}
+
+			if rewritten {
+				if m, err = manifestlist.FromDescriptors(descriptors); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err := destination.Manifests().Put(m, ""); err != nil {
+			return nil, err
+		}
+
+		// Signatures only hold for the content they signed, so only those
+		// of unchanged revisions are kept.
+		desc, err := m.Descriptor()
+		if err != nil {
+			return nil, err
+		}
+
+		if desc.Digest == revision {
+			if err := copySignatures(source, destination, revision); err != nil {
+				return nil, err
+			}
+		}
+
+		renamed[revision] = m
+		copied.Manifests++
+		return m, nil
+	}
+
+	for _, revision := range revisions {
+		if _, err := copyRevision(revision); err != nil {
+			return copied, err
+		}
+	}
+
+	tags, err := source.Manifests().Tags()
+	if err != nil {
+		switch err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			return copied, nil // no tags
+		default:
+			return copied, err
+		}
+	}
+
+	for _, tag := range tags {
+		currentPath, err := defaultPathMapper.path(manifestTagCurrentPathSpec{name: source.Name(), tag: tag})
+		if err != nil {
+			return copied, err
+		}
+
+		content, err := driver.GetContent(ctx, currentPath)
+		if err != nil {
+			return copied, err
+		}
+
+		revision, err := digest.ParseDigest(string(content))
+		if err != nil {
+			return copied, err
+		}
+
+		// Revisions pushed before revisions had their own link set are
+		// only found through their tags.
+		m, err := copyRevision(revision)
+		if err != nil {
+			return copied, err
+		}
+
+		if err := destination.Manifests().Put(m, tag); err != nil {
+			return copied, err
+		}
+		copied.Tags++
+	}
+
+	return copied, nil
+}
+
+// copySignatures links the signatures attached to revision in source under
+// the same revision in destination. They were verified when attached, so
+// they are not verified again.
+func copySignatures(source, destination distribution.Repository, revision digest.Digest) error {
+	signatures, err := source.Signatures().Get(revision)
+	if err != nil || len(signatures) == 0 {
+		return err
+	}
+
+	if repo, ok := destination.(*repository); ok {
+		return newSignatureStore(repo.ctx, repo, repo.blobStore).put(revision, signatures...)
+	}
+
+	return destination.Signatures().Put(revision, signatures...)
+}
+
+// repositoryExists returns true if the named repository has layers or
+// manifests linked into it.
+func repositoryExists(ctx context.Context, driver storageDriver.StorageDriver, name string) (bool, error) {
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return false, err
+	}
+
+	for _, dir := range []string{"_layers", "_manifests"} {
+		found, err := exists(ctx, driver, path.Join(root, name, dir))
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	return false, nil
+}
+
+// removeRepository removes the links and uploads of the repository, and
+// clears its layers from the repository scoped descriptor cache, as deleting
+// each of them would. Repositories nested under the name are left in place.
+func removeRepository(ctx context.Context, driver storageDriver.StorageDriver, repo distribution.Repository) error {
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+
+	var descriptorCache distribution.BlobDescriptorService
+	if repo, ok := repo.(*repository); ok {
+		descriptorCache = repo.descriptorCache
+
+		if repo.quotaUsage != nil {
+			defer repo.quotaUsage.invalidate(repo.Name())
+		}
+	}
+
+	var layers []digest.Digest
+	if descriptorCache != nil {
+		layers, err = linkedDigests(ctx, driver, path.Join(root, repo.Name(), "_layers"))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range []string{"_layers", "_manifests", "_uploads"} {
+		if err := driver.Delete(ctx, path.Join(root, repo.Name(), dir)); err != nil {
+			switch err.(type) {
+			case storageDriver.PathNotFoundError:
+			default:
+				return err
+			}
+		}
+	}
+
+	// The links are gone, so the cache cannot be filled again from them.
+	for _, dgst := range layers {
+		if err := descriptorCache.Clear(ctx, dgst); err != nil && err != distribution.ErrBlobUnknown {
+			context.GetLogger(ctx).Errorf("error clearing descriptor %v of %s from cache: %v", dgst, repo.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// linkedDigests walks a link set, such as the layers or the manifest
+// revisions of a repository, returning the digests naming its links. A blob
+// linked under several digests, such as a tarsum and its canonical digest,
+// is returned once for each of them. The signatures linked under manifest
+// revisions are skipped.
+func linkedDigests(ctx context.Context, driver storageDriver.StorageDriver, root string) ([]digest.Digest, error) {
+	var dgsts []digest.Digest
+	err := Walk(ctx, driver, root, func(fileInfo storageDriver.FileInfo) error {
+		_, file := path.Split(fileInfo.Path())
+
+		if fileInfo.IsDir() {
+			if file == "signatures" {
+				return ErrSkipDir
+			}
+			return nil
+		}
+
+		if dgst, ok := linkDigestFromPath(root, fileInfo.Path()); ok {
+			dgsts = append(dgsts, dgst)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if isWalkRootNotFound(err, root) {
+			return nil, nil // empty link set
+		}
+		return nil, err
+	}
+
+	return dgsts, nil
+}
+
+// linkDigestFromPath returns the digest naming the link file at p, relative
+// to the root of a link set, reversing digestPathComponents.
+func linkDigestFromPath(root, p string) (digest.Digest, bool) {
+	components := strings.Split(strings.TrimPrefix(p, root+"/"), "/")
+
+	var dgst digest.Digest
+	switch {
+	case len(components) == 3 && components[2] == "link":
+		dgst = digest.NewDigestFromHex(components[0], components[1])
+	case len(components) == 5 && components[0] == "tarsum" && components[4] == "link":
+		dgst = digest.Digest(fmt.Sprintf("tarsum.%s+%s:%s", components[1], components[2], components[3]))
+	default:
+		return "", false
+	}
+
+	if err := dgst.Validate(); err != nil {
+		return "", false
+	}
+
+	return dgst, true
+}
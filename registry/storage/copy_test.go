@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/libtrust"
+)
+
+func TestCopyRepository(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "v1")
+	v1 := putTestManifest(t, env, "v1")
+	putTestManifest(t, env, "v2")
+
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	copied, err := CopyRepository(env.ctx, env.registry, env.driver, env.name, "foo/baz", key)
+	if err != nil {
+		t.Fatalf("unexpected error copying repository: %v", err)
+	}
+
+	expected := RepositoryCopy{From: env.name, To: "foo/baz", Layers: 4, Manifests: 2, Tags: 2}
+	if copied != expected {
+		t.Fatalf("unexpected copy summary: %#v != %#v", copied, expected)
+	}
+
+	checkTags := func(name string, expected ...string) distribution.Repository {
+		repo, err := env.registry.Repository(env.ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository: %v", err)
+		}
+
+		tags, err := repo.Manifests().Tags()
+		if err != nil {
+			t.Fatalf("unexpected error listing tags of %s: %v", name, err)
+		}
+
+		if !reflect.DeepEqual(tags, expected) {
+			t.Fatalf("unexpected tags of %s: %v != %v", name, tags, expected)
+		}
+
+		return repo
+	}
+
+	checkTags(env.name, "v1", "v2")
+	dest := checkTags("foo/baz", "v1", "v2")
+
+	m, err := dest.Manifests().GetByTag("v1")
+	if err != nil {
+		t.Fatalf("unexpected error getting copied manifest: %v", err)
+	}
+
+	sm := m.(*manifest.SignedManifest)
+	if sm.Name != "foo/baz" {
+		t.Fatalf("unexpected name in copied manifest: %q", sm.Name)
+	}
+
+	if _, err := manifest.Verify(sm); err != nil {
+		t.Fatalf("unexpected error verifying copied manifest: %v", err)
+	}
+
+	for _, layer := range v1.FSLayers {
+		if _, err := dest.Blobs(env.ctx).Stat(env.ctx, layer.BlobSum); err != nil {
+			t.Fatalf("unexpected error checking copied layer: %v", err)
+		}
+	}
+
+	if _, err := CopyRepository(env.ctx, env.registry, env.driver, env.name, "foo/baz", key); err != (distribution.ErrRepositoryExists{Name: "foo/baz"}) {
+		t.Fatalf("expected repository exists error, got %v", err)
+	}
+
+	if _, err := CopyRepository(env.ctx, env.registry, env.driver, "foo/unknown", "foo/other", key); err != (distribution.ErrRepositoryUnknown{Name: "foo/unknown"}) {
+		t.Fatalf("expected repository unknown error, got %v", err)
+	}
+
+	if _, err := RenameRepository(env.ctx, env.registry, env.driver, "foo/baz", "foo/baz/renamed", key); err != nil {
+		t.Fatalf("unexpected error renaming repository: %v", err)
+	}
+
+	checkTags("foo/baz/renamed", "v1", "v2")
+
+	repos, _, err := env.registry.Catalog(env.ctx).Get(0, "")
+	if err != nil {
+		t.Fatalf("unexpected error listing repositories: %v", err)
+	}
+
+	if !reflect.DeepEqual(repos, []string{env.name, "foo/baz/renamed"}) {
+		t.Fatalf("unexpected repositories after rename: %v", repos)
+	}
+}
+
+// TestCopyRepositoryManifestList checks that a manifest list is copied after
+// the manifests it references, whatever the order of their digests, and that
+// it is rewritten to reference the re-signed schema1 manifests.
+func TestCopyRepositoryManifestList(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/multiarch", "latest")
+	amd64 := putTestManifest(t, env, "amd64")
+	arm64 := putTestSchema2Manifest(t, env, "arm64")
+
+	descriptors := make([]manifestlist.ManifestDescriptor, 2)
+	for i, m := range []distribution.Manifest{amd64, arm64} {
+		desc, err := m.Descriptor()
+		if err != nil {
+			t.Fatalf("unexpected error getting manifest descriptor: %v", err)
+		}
+		descriptors[i].Descriptor = schema2.NewDescriptor(desc)
+		descriptors[i].Platform = manifestlist.PlatformSpec{OS: "linux", Architecture: []string{"amd64", "arm64"}[i]}
+	}
+
+	// Vary the list until its digest sorts before the manifests it
+	// references, so that walking the revisions in order finds it first.
+	var dml *manifestlist.DeserializedManifestList
+	for i := 0; ; i++ {
+		descriptors[1].Platform.Variant = fmt.Sprintf("v%d", i)
+
+		var err error
+		dml, err = manifestlist.FromDescriptors(descriptors)
+		if err != nil {
+			t.Fatalf("unexpected error creating manifest list: %v", err)
+		}
+
+		desc, err := dml.Descriptor()
+		if err != nil {
+			t.Fatalf("unexpected error getting manifest list descriptor: %v", err)
+		}
+
+		if desc.Digest < descriptors[0].Digest && desc.Digest < descriptors[1].Digest {
+			break
+		}
+	}
+
+	if err := env.repository.Manifests().Put(dml, env.tag); err != nil {
+		t.Fatalf("unexpected error putting manifest list: %v", err)
+	}
+
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	copied, err := CopyRepository(env.ctx, env.registry, env.driver, env.name, "foo/copy", key)
+	if err != nil {
+		t.Fatalf("unexpected error copying repository: %v", err)
+	}
+
+	expected := RepositoryCopy{From: env.name, To: "foo/copy", Layers: 4, Manifests: 3, Tags: 3}
+	if copied != expected {
+		t.Fatalf("unexpected copy summary: %#v != %#v", copied, expected)
+	}
+
+	dest, err := env.registry.Repository(env.ctx, "foo/copy")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	descriptorOf := func(tag string) distribution.Descriptor {
+		m, err := dest.Manifests().GetByTag(tag)
+		if err != nil {
+			t.Fatalf("unexpected error getting copied manifest %s: %v", tag, err)
+		}
+
+		desc, err := m.Descriptor()
+		if err != nil {
+			t.Fatalf("unexpected error getting manifest descriptor: %v", err)
+		}
+
+		return desc
+	}
+
+	m, err := dest.Manifests().GetByTag(env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error getting copied manifest list: %v", err)
+	}
+
+	list, ok := m.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		t.Fatalf("unexpected manifest type: %T", m)
+	}
+
+	// The schema1 manifest was re-signed, the schema2 manifest kept its
+	// digest.
+	if resigned := descriptorOf("amd64").Digest; list.Manifests[0].Digest != resigned || resigned == descriptors[0].Digest {
+		t.Fatalf("unexpected reference to schema1 manifest: %v, re-signed as %v", list.Manifests[0].Digest, resigned)
+	}
+
+	if list.Manifests[1].Digest != descriptors[1].Digest || descriptorOf("arm64").Digest != descriptors[1].Digest {
+		t.Fatalf("unexpected reference to schema2 manifest: %v != %v", list.Manifests[1].Digest, descriptors[1].Digest)
+	}
+
+	if list.Manifests[1].Platform != descriptors[1].Platform {
+		t.Fatalf("unexpected platform: %#v != %#v", list.Manifests[1].Platform, descriptors[1].Platform)
+	}
+
+	// Renaming the source clears its layers from the descriptor cache.
+	layer := arm64.Layers[0].Digest
+	if _, err := env.repository.Blobs(env.ctx).Stat(env.ctx, layer); err != nil {
+		t.Fatalf("unexpected error checking layer: %v", err)
+	}
+
+	if _, err := RenameRepository(env.ctx, env.registry, env.driver, env.name, "foo/renamed", key); err != nil {
+		t.Fatalf("unexpected error renaming repository: %v", err)
+	}
+
+	source, err := env.registry.Repository(env.ctx, env.name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	if _, err := source.Blobs(env.ctx).Stat(env.ctx, layer); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected layer to be unknown in renamed repository, got %v", err)
+	}
+}
+
+// TestCopyRepositorySignatures checks that the signatures attached to a
+// revision are copied with it, unless the revision is re-signed.
+func TestCopyRepositorySignatures(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/signed", "v1")
+	sm := putTestManifest(t, env, "v1")
+	dm := putTestSchema2Manifest(t, env, "v2")
+
+	resignedDesc, err := sm.Descriptor()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest descriptor: %v", err)
+	}
+
+	unchangedDesc, err := dm.Descriptor()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest descriptor: %v", err)
+	}
+
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	detached, err := manifest.Sign(&sm.Manifest, otherKey)
+	if err != nil {
+		t.Fatalf("unexpected error signing manifest: %v", err)
+	}
+
+	signatures, err := detached.Signatures()
+	if err != nil {
+		t.Fatalf("unexpected error getting signatures: %v", err)
+	}
+
+	if err := env.repository.Signatures().Put(resignedDesc.Digest, signatures...); err != nil {
+		t.Fatalf("unexpected error attaching signatures: %v", err)
+	}
+
+	// Only schema1 signatures are accepted through the signature service,
+	// so link one under the schema2 revision directly.
+	signature := []byte(`{"signature": "opaque"}`)
+	repo := env.repository.(*repository)
+	if err := newSignatureStore(env.ctx, repo, repo.blobStore).put(unchangedDesc.Digest, signature); err != nil {
+		t.Fatalf("unexpected error linking signature: %v", err)
+	}
+
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	if _, err := CopyRepository(env.ctx, env.registry, env.driver, env.name, "foo/copy", key); err != nil {
+		t.Fatalf("unexpected error copying repository: %v", err)
+	}
+
+	dest, err := env.registry.Repository(env.ctx, "foo/copy")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	copiedSignatures, err := dest.Signatures().Get(unchangedDesc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error fetching copied signatures: %v", err)
+	}
+
+	if !reflect.DeepEqual(copiedSignatures, [][]byte{signature}) {
+		t.Fatalf("unexpected signatures of unchanged revision: %q", copiedSignatures)
+	}
+
+	m, err := dest.Manifests().GetByTag("v1")
+	if err != nil {
+		t.Fatalf("unexpected error getting copied manifest: %v", err)
+	}
+
+	desc, err := m.Descriptor()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest descriptor: %v", err)
+	}
+
+	// The re-signed revision only carries the signature of the copy.
+	resigned, err := dest.Signatures().Get(desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error fetching signatures of re-signed revision: %v", err)
+	}
+
+	if len(resigned) != 1 {
+		t.Fatalf("unexpected number of signatures of re-signed revision: %d != 1", len(resigned))
+	}
+
+	if original, err := dest.Signatures().Get(resignedDesc.Digest); err != nil || len(original) != 0 {
+		t.Fatalf("unexpected signatures of original revision in copy: %d, %v", len(original), err)
+	}
+}
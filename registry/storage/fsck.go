@@ -0,0 +1,469 @@
+package storage
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// Kinds of inconsistencies reported by CheckStorage.
+const (
+	// FsckInvalidLink is reported for link files that do not hold a digest.
+	FsckInvalidLink = "invalid link"
+
+	// FsckDanglingLayer is reported for layer links to missing blobs.
+	FsckDanglingLayer = "dangling layer link"
+
+	// FsckDanglingRevision is reported for manifest revision links to
+	// missing blobs.
+	FsckDanglingRevision = "dangling revision link"
+
+	// FsckDanglingSignature is reported for signature links to missing
+	// blobs.
+	FsckDanglingSignature = "dangling signature link"
+
+	// FsckMissingCurrent is reported for tags without a current link.
+	FsckMissingCurrent = "tag without current link"
+
+	// FsckUnknownRevision is reported for tags whose current link
+	// references a revision unknown to the repository.
+	FsckUnknownRevision = "tag references unknown revision"
+
+	// FsckMissingIndexEntry is reported for tags whose current revision is
+	// missing from their index.
+	FsckMissingIndexEntry = "current revision missing from tag index"
+
+	// FsckDanglingIndexEntry is reported for tag index entries referencing
+	// revisions unknown to the repository.
+	FsckDanglingIndexEntry = "tag index references unknown revision"
+)
+
+// FsckReport summarizes a run of CheckStorage.
+// 存储一致性检查的报告
+type FsckReport struct {
+	// Repositories is the number of repositories checked.
+	Repositories int `json:"repositories"`
+
+	// Links is the number of layer, revision and signature links checked.
+	Links int `json:"links"`
+
+	// Tags is the number of tags checked.
+	Tags int `json:"tags"`
+
+	// Inconsistencies lists the problems found, by repository.
+	Inconsistencies []Inconsistency `json:"inconsistencies"`
+}
+
+// Inconsistency describes a problem found in the storage layout.
+type Inconsistency struct {
+	Repository string `json:"repository"`
+
+	// Kind is one of the Fsck constants.
+	Kind string `json:"kind"`
+
+	// Path is the file or directory at fault.
+	Path string `json:"path"`
+
+	// Tag is the tag at fault, if any.
+	Tag string `json:"tag,omitempty"`
+
+	// Digest is the digest of the missing or unknown content, if any.
+	Digest digest.Digest `json:"digest,omitempty"`
+
+	// Repaired is true if the problem was fixed.
+	Repaired bool `json:"repaired"`
+}
+
+// CheckStorage walks the repositories in the storage, as laid out by the
+// path mapper, reporting links to missing blobs, tags that cannot be
+// resolved and tag indexes out of step with their current revision.
+//
+// If repair is true, the problems that can be fixed without losing content
+// are fixed. Links to missing blobs and invalid links are removed, as are
+// the tags that cannot be resolved and the index entries of unknown
+// revisions, as when a manifest is deleted. Missing index entries are added.
+// The registry must not accept writes while repairing, since an upload or a
+// push in progress looks inconsistent.
+// 检查存储的一致性，可选修复
+func CheckStorage(ctx context.Context, driver storageDriver.StorageDriver, repair bool) (FsckReport, error) {
+	fsck := &fsckChecker{
+		ctx:    ctx,
+		driver: driver,
+		repair: repair,
+		blobs:  make(map[digest.Digest]bool),
+		report: FsckReport{Inconsistencies: []Inconsistency{}},
+	}
+
+	var err error
+	fsck.root, err = defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return fsck.report, err
+	}
+
+	repos, err := fsck.repositories()
+	if err != nil {
+		return fsck.report, err
+	}
+
+	for _, name := range repos {
+		if err := fsck.checkRepository(name); err != nil {
+			return fsck.report, err
+		}
+		fsck.report.Repositories++
+	}
+
+	return fsck.report, nil
+}
+
+// fsckChecker holds the state of a run of CheckStorage.
+type fsckChecker struct {
+	ctx    context.Context
+	driver storageDriver.StorageDriver
+	repair bool
+	root   string
+
+	// blobs caches whether the data of a blob exists.
+	blobs map[digest.Digest]bool
+
+	report FsckReport
+}
+
+// repositories returns the names of the repositories with layers or
+// manifests, sorted.
+func (fsck *fsckChecker) repositories() ([]string, error) {
+	found := make(map[string]struct{})
+	err := Walk(fsck.ctx, fsck.driver, fsck.root, func(fileInfo storageDriver.FileInfo) error {
+		if !fileInfo.IsDir() {
+			return nil
+		}
+
+		filePath := fileInfo.Path()
+		_, file := path.Split(filePath)
+
+		if file == "_layers" || file == "_manifests" {
+			found[strings.TrimPrefix(path.Dir(filePath), fsck.root+"/")] = struct{}{}
+		}
+
+		// Reserved directories never contain nested repositories.
+		if strings.HasPrefix(file, "_") {
+			return ErrSkipDir
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if isWalkRootNotFound(err, fsck.root) {
+			return nil, nil // no repositories
+		}
+		return nil, err
+	}
+
+	var repos []string
+	for name := range found {
+		repos = append(repos, name)
+	}
+	sort.Strings(repos)
+
+	return repos, nil
+}
+
+// checkRepository checks the layers, revisions and tags of the repository,
+// in that order, so that tags are checked against the intact revisions.
+func (fsck *fsckChecker) checkRepository(name string) error {
+	layers := path.Join(fsck.root, name, "_layers")
+	if _, err := fsck.checkLinks(name, layers, func(string) string { return FsckDanglingLayer }); err != nil {
+		return err
+	}
+
+	revisionsRoot := path.Join(fsck.root, name, "_manifests", "revisions")
+	broken, err := fsck.checkLinks(name, revisionsRoot, func(p string) string {
+		if strings.Contains(strings.TrimPrefix(p, revisionsRoot), "/signatures/") {
+			return FsckDanglingSignature
+		}
+		return FsckDanglingRevision
+	})
+	if err != nil {
+		return err
+	}
+
+	revisions, err := linkedDigests(fsck.ctx, fsck.driver, revisionsRoot)
+	if err != nil {
+		return err
+	}
+
+	// Broken revisions are unknown, whether or not they were removed.
+	known := make(map[digest.Digest]struct{}, len(revisions))
+	for _, revision := range revisions {
+		linkPath, err := defaultPathMapper.path(manifestRevisionLinkPathSpec{name: name, revision: revision})
+		if err != nil {
+			return err
+		}
+
+		if _, ok := broken[linkPath]; !ok {
+			known[revision] = struct{}{}
+		}
+	}
+
+	return fsck.checkTags(name, known)
+}
+
+// checkLinks checks every link file under root, reporting invalid links and
+// links to missing blobs as the kind returned for their path. When
+// repairing, the directory holding such links is removed. The paths of the
+// broken links are returned.
+func (fsck *fsckChecker) checkLinks(name, root string, kind func(string) string) (map[string]struct{}, error) {
+	var problems []Inconsistency
+
+	err := Walk(fsck.ctx, fsck.driver, root, func(fileInfo storageDriver.FileInfo) error {
+		if fileInfo.IsDir() || path.Base(fileInfo.Path()) != "link" {
+			return nil
+		}
+
+		fsck.report.Links++
+
+		problem, err := fsck.checkLink(name, fileInfo.Path(), kind(fileInfo.Path()))
+		if err != nil {
+			return err
+		}
+
+		if problem != nil {
+			problems = append(problems, *problem)
+		}
+
+		return nil
+	})
+
+	if err != nil && !isWalkRootNotFound(err, root) {
+		return nil, err
+	}
+
+	// Repair once the walk is over, so that it does not change the
+	// directories being walked.
+	broken := make(map[string]struct{}, len(problems))
+	for _, problem := range problems {
+		if fsck.repair {
+			if err := fsck.remove(path.Dir(problem.Path)); err != nil {
+				return nil, err
+			}
+			problem.Repaired = true
+		}
+
+		broken[problem.Path] = struct{}{}
+		fsck.report.Inconsistencies = append(fsck.report.Inconsistencies, problem)
+	}
+
+	return broken, nil
+}
+
+// checkLink reads the link at linkPath, returning the problem found with it,
+// if any.
+func (fsck *fsckChecker) checkLink(name, linkPath, kind string) (*Inconsistency, error) {
+	content, err := fsck.driver.GetContent(fsck.ctx, linkPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dgst, err := digest.ParseDigest(string(content))
+	if err != nil {
+		return &Inconsistency{Repository: name, Kind: FsckInvalidLink, Path: linkPath}, nil
+	}
+
+	exists, err := fsck.blobExists(dgst)
+	if err != nil || exists {
+		return nil, err
+	}
+
+	return &Inconsistency{Repository: name, Kind: kind, Path: linkPath, Digest: dgst}, nil
+}
+
+// checkTags checks that each tag of the repository has a current link to a
+// known revision, which is present in its index, and that its index only
+// references known revisions.
+func (fsck *fsckChecker) checkTags(name string, known map[digest.Digest]struct{}) error {
+	tagsPath, err := defaultPathMapper.path(manifestTagsPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	entries, err := fsck.driver.List(fsck.ctx, tagsPath)
+	if err != nil {
+		switch err.(type) {
+		case storageDriver.PathNotFoundError:
+			return nil // no tags
+		default:
+			return err
+		}
+	}
+	sort.Strings(entries)
+
+	for _, entry := range entries {
+		_, tag := path.Split(entry)
+		fsck.report.Tags++
+
+		unresolved, err := fsck.checkCurrent(name, tag, known)
+		if err != nil {
+			return err
+		}
+
+		// The index of a tag that cannot be resolved is not checked, as
+		// the whole tag is reported.
+		if unresolved {
+			continue
+		}
+
+		if err := fsck.checkIndex(name, tag, known); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCurrent checks the current link of the tag, returning true if the tag
+// cannot be resolved.
+func (fsck *fsckChecker) checkCurrent(name, tag string, known map[digest.Digest]struct{}) (bool, error) {
+	currentPath, err := defaultPathMapper.path(manifestTagCurrentPathSpec{name: name, tag: tag})
+	if err != nil {
+		return false, err
+	}
+
+	problem := Inconsistency{Repository: name, Tag: tag, Path: currentPath}
+
+	content, err := fsck.driver.GetContent(fsck.ctx, currentPath)
+	switch err.(type) {
+	case nil:
+		revision, err := digest.ParseDigest(string(content))
+		if err != nil {
+			problem.Kind = FsckInvalidLink
+		} else if _, ok := known[revision]; !ok {
+			problem.Kind = FsckUnknownRevision
+			problem.Digest = revision
+		} else {
+			return false, fsck.checkIndexEntry(name, tag, revision)
+		}
+	case storageDriver.PathNotFoundError:
+		problem.Kind = FsckMissingCurrent
+	default:
+		return false, err
+	}
+
+	// The tag cannot be resolved, so it is removed, as when its revision
+	// is deleted.
+	if fsck.repair {
+		tagPath, err := defaultPathMapper.path(manifestTagPathSpec{name: name, tag: tag})
+		if err != nil {
+			return false, err
+		}
+
+		if err := fsck.remove(tagPath); err != nil {
+			return false, err
+		}
+		problem.Repaired = true
+	}
+
+	fsck.report.Inconsistencies = append(fsck.report.Inconsistencies, problem)
+	return true, nil
+}
+
+// checkIndexEntry checks that the current revision of the tag is in its
+// index, adding it when repairing.
+func (fsck *fsckChecker) checkIndexEntry(name, tag string, revision digest.Digest) error {
+	entryPath, err := defaultPathMapper.path(manifestTagIndexEntryLinkPathSpec{name: name, tag: tag, revision: revision})
+	if err != nil {
+		return err
+	}
+
+	found, err := exists(fsck.ctx, fsck.driver, entryPath)
+	if err != nil || found {
+		return err
+	}
+
+	problem := Inconsistency{Repository: name, Kind: FsckMissingIndexEntry, Tag: tag, Path: entryPath, Digest: revision}
+	if fsck.repair {
+		if err := fsck.driver.PutContent(fsck.ctx, entryPath, []byte(revision)); err != nil {
+			return err
+		}
+		context.GetLogger(fsck.ctx).Infof("fsck: added %v to the index of %s:%s", revision, name, tag)
+		problem.Repaired = true
+	}
+
+	fsck.report.Inconsistencies = append(fsck.report.Inconsistencies, problem)
+	return nil
+}
+
+// checkIndex checks that the index of the tag only references known
+// revisions, removing the other entries when repairing.
+func (fsck *fsckChecker) checkIndex(name, tag string, known map[digest.Digest]struct{}) error {
+	indexPath, err := defaultPathMapper.path(manifestTagIndexPathSpec{name: name, tag: tag})
+	if err != nil {
+		return err
+	}
+
+	revisions, err := linkedDigests(fsck.ctx, fsck.driver, indexPath)
+	if err != nil {
+		return err
+	}
+
+	for _, revision := range revisions {
+		if _, ok := known[revision]; ok {
+			continue
+		}
+
+		entryPath, err := defaultPathMapper.path(manifestTagIndexEntryPathSpec{name: name, tag: tag, revision: revision})
+		if err != nil {
+			return err
+		}
+
+		problem := Inconsistency{Repository: name, Kind: FsckDanglingIndexEntry, Tag: tag, Path: entryPath, Digest: revision}
+		if fsck.repair {
+			if err := fsck.remove(entryPath); err != nil {
+				return err
+			}
+			problem.Repaired = true
+		}
+
+		fsck.report.Inconsistencies = append(fsck.report.Inconsistencies, problem)
+	}
+
+	return nil
+}
+
+// blobExists returns whether the data of the blob is in the blob store.
+func (fsck *fsckChecker) blobExists(dgst digest.Digest) (bool, error) {
+	if found, ok := fsck.blobs[dgst]; ok {
+		return found, nil
+	}
+
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		return false, err
+	}
+
+	found, err := exists(fsck.ctx, fsck.driver, blobPath)
+	if err != nil {
+		return false, err
+	}
+
+	fsck.blobs[dgst] = found
+	return found, nil
+}
+
+// remove deletes p as part of a repair. A path that is already gone is not
+// an error.
+func (fsck *fsckChecker) remove(p string) error {
+	context.GetLogger(fsck.ctx).Infof("fsck: removing %s", p)
+	if err := fsck.driver.Delete(fsck.ctx, p); err != nil {
+		switch err.(type) {
+		case storageDriver.PathNotFoundError:
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"path"
+	"testing"
+
+	"github.com/docker/distribution/digest"
+)
+
+func TestCheckStorage(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "v1")
+	v1 := putTestManifest(t, env, "v1")
+	putTestManifest(t, env, "v2")
+
+	report, err := CheckStorage(env.ctx, env.driver, false)
+	if err != nil {
+		t.Fatalf("unexpected error checking storage: %v", err)
+	}
+
+	if report.Repositories != 1 || report.Tags != 2 || report.Links == 0 || len(report.Inconsistencies) != 0 {
+		t.Fatalf("unexpected report of consistent storage: %#v", report)
+	}
+
+	resolve := func(tag string) digest.Digest {
+		currentPath, err := pm.path(manifestTagCurrentPathSpec{name: env.name, tag: tag})
+		if err != nil {
+			t.Fatalf("unexpected error resolving path: %v", err)
+		}
+
+		content, err := env.driver.GetContent(env.ctx, currentPath)
+		if err != nil {
+			t.Fatalf("unexpected error reading current link of %s: %v", tag, err)
+		}
+
+		return digest.Digest(content)
+	}
+
+	deleteBlob := func(dgst digest.Digest) {
+		blobPath, err := pm.path(blobDataPathSpec{digest: dgst})
+		if err != nil {
+			t.Fatalf("unexpected error resolving path: %v", err)
+		}
+
+		if err := env.driver.Delete(env.ctx, path.Dir(blobPath)); err != nil {
+			t.Fatalf("unexpected error deleting blob: %v", err)
+		}
+	}
+
+	put := func(spec pathSpec, content string) string {
+		p, err := pm.path(spec)
+		if err != nil {
+			t.Fatalf("unexpected error resolving path: %v", err)
+		}
+
+		if err := env.driver.PutContent(env.ctx, p, []byte(content)); err != nil {
+			t.Fatalf("unexpected error writing %s: %v", p, err)
+		}
+
+		return p
+	}
+
+	// A layer whose data is lost.
+	layer, err := env.repository.Blobs(env.ctx).Stat(env.ctx, v1.FSLayers[0].BlobSum)
+	if err != nil {
+		t.Fatalf("unexpected error statting layer: %v", err)
+	}
+	deleteBlob(layer.Digest)
+
+	// A layer link that does not hold a digest.
+	put(layerLinkPathSpec{name: env.name, digest: "sha256:0123456789abcdef"}, "garbage")
+
+	// v2 references a revision whose data is lost.
+	v2Revision := resolve("v2")
+	deleteBlob(v2Revision)
+
+	// v1 is missing from its index, which references an unknown revision.
+	v1Revision := resolve("v1")
+	indexEntryPath, err := pm.path(manifestTagIndexEntryPathSpec{name: env.name, tag: "v1", revision: v1Revision})
+	if err != nil {
+		t.Fatalf("unexpected error resolving path: %v", err)
+	}
+
+	if err := env.driver.Delete(env.ctx, indexEntryPath); err != nil {
+		t.Fatalf("unexpected error deleting index entry: %v", err)
+	}
+
+	unknown := digest.Digest("sha256:fedcba9876543210")
+	put(manifestTagIndexEntryLinkPathSpec{name: env.name, tag: "v1", revision: unknown}, unknown.String())
+
+	// v3 was never completely tagged.
+	put(manifestTagIndexEntryLinkPathSpec{name: env.name, tag: "v3", revision: v1Revision}, v1Revision.String())
+
+	expected := map[string]int{
+		FsckDanglingLayer:      2, // linked by tarsum and canonical digest
+		FsckInvalidLink:        1,
+		FsckDanglingRevision:   1,
+		FsckUnknownRevision:    1,
+		FsckMissingIndexEntry:  1,
+		FsckDanglingIndexEntry: 1,
+		FsckMissingCurrent:     1,
+	}
+
+	checkReport := func(report FsckReport, repaired bool) {
+		found := make(map[string]int)
+		for _, problem := range report.Inconsistencies {
+			if problem.Repository != env.name || problem.Repaired != repaired {
+				t.Fatalf("unexpected inconsistency: %#v", problem)
+			}
+			found[problem.Kind]++
+		}
+
+		for kind, count := range expected {
+			if found[kind] != count {
+				t.Fatalf("unexpected count of %q: %d != %d in %#v", kind, found[kind], count, report.Inconsistencies)
+			}
+		}
+
+		if len(found) != len(expected) {
+			t.Fatalf("unexpected inconsistencies: %#v", report.Inconsistencies)
+		}
+	}
+
+	report, err = CheckStorage(env.ctx, env.driver, false)
+	if err != nil {
+		t.Fatalf("unexpected error checking storage: %v", err)
+	}
+	checkReport(report, false)
+
+	report, err = CheckStorage(env.ctx, env.driver, true)
+	if err != nil {
+		t.Fatalf("unexpected error repairing storage: %v", err)
+	}
+	checkReport(report, true)
+
+	report, err = CheckStorage(env.ctx, env.driver, false)
+	if err != nil {
+		t.Fatalf("unexpected error checking repaired storage: %v", err)
+	}
+
+	if len(report.Inconsistencies) != 0 {
+		t.Fatalf("unexpected inconsistencies after repair: %#v", report.Inconsistencies)
+	}
+
+	tags, err := env.repository.Manifests().Tags()
+	if err != nil {
+		t.Fatalf("unexpected error listing tags: %v", err)
+	}
+
+	if len(tags) != 1 || tags[0] != "v1" {
+		t.Fatalf("unexpected tags after repair: %v", tags)
+	}
+
+	if _, err := env.repository.Manifests().GetByTag("v1"); err != nil {
+		t.Fatalf("unexpected error getting repaired tag: %v", err)
+	}
+
+	if exists, err := env.repository.Manifests().Exists(v2Revision); err != nil || exists {
+		t.Fatalf("expected dangling revision to be removed: %v, %v", exists, err)
+	}
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// MarkAndSweep performs a mark and sweep of the registry global blob store.
+// Every blob referenced by a link in any repository, whether a layer link, a
+// manifest revision, a signature or a tag, is marked. The data of all
+// unmarked blobs is then removed. If dryRun is true, nothing is deleted.
+//
+// Blobs whose data was modified after the start of the mark phase, less the
+// grace period, are never swept, so a new blob pushed while the collection
+// runs is kept as long as it is linked within the grace period. A registry
+// created with EnableOnlineGC also touches the blobs it links, and blobs
+// touched since then are not swept either. This covers the existing blobs
+// pushed again or mounted into a repository after the mark phase. With a
+// sufficient grace period and online garbage collection enabled on every
+// instance, MarkAndSweep can be run while the registry is serving. Otherwise
+// the registry must not accept writes while it runs.
+//
+// The descriptors of the swept blobs are returned, with the length set to the
+// size of the blob data.
+// 标记所有被引用的 blob，然后删除没有被引用的 blob
+func MarkAndSweep(ctx context.Context, driver storageDriver.StorageDriver, gracePeriod time.Duration, dryRun bool) ([]distribution.Descriptor, error) {
+	olderThan := time.Now().Add(-gracePeriod)
+
+	marked, err := markBlobs(ctx, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return sweepBlobs(ctx, driver, marked, olderThan, dryRun)
+}
+
+// markBlobs walks all repositories, collecting the target of every link.
+func markBlobs(ctx context.Context, driver storageDriver.StorageDriver) (map[digest.Digest]struct{}, error) {
+	marked := make(map[digest.Digest]struct{})
+
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Any error fails the whole mark phase: missing a mark would result in
+	// deleting referenced data.
+	err = Walk(ctx, driver, root, func(fileInfo storageDriver.FileInfo) error {
+		_, file := path.Split(fileInfo.Path())
+
+		if fileInfo.IsDir() {
+			if file == "_uploads" {
+				return ErrSkipDir
+			}
+
+			return nil
+		}
+
+		if file != "link" {
+			return nil
+		}
+
+		content, err := driver.GetContent(ctx, fileInfo.Path())
+		if err != nil {
+			return err
+		}
+
+		dgst, err := digest.ParseDigest(string(content))
+		if err != nil {
+			return err
+		}
+
+		marked[dgst] = struct{}{}
+		return nil
+	})
+
+	if err != nil && !isWalkRootNotFound(err, root) {
+		return nil, err
+	}
+
+	// no repositories, nothing is referenced.
+	return marked, nil
+}
+
+// sweepBlobs removes the data of every blob in the global blob store that is
+// not present in marked and was last modified before olderThan.
+func sweepBlobs(ctx context.Context, driver storageDriver.StorageDriver, marked map[digest.Digest]struct{}, olderThan time.Time, dryRun bool) ([]distribution.Descriptor, error) {
+	root, err := defaultPathMapper.path(blobsPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		swept []distribution.Descriptor
+		paths []string
+	)
+
+	err = Walk(ctx, driver, root, func(fileInfo storageDriver.FileInfo) error {
+		if fileInfo.IsDir() {
+			return nil
+		}
+
+		dgst, ok := blobDigestFromPath(root, fileInfo.Path())
+		if !ok {
+			return nil
+		}
+
+		if _, ok := marked[dgst]; ok {
+			return nil
+		}
+
+		if !fileInfo.ModTime().Before(olderThan) {
+			// written during or just before the mark phase, it may be
+			// about to be linked.
+			return nil
+		}
+
+		blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+		if err != nil {
+			return err
+		}
+
+		swept = append(swept, distribution.Descriptor{
+			Digest: dgst,
+			Length: fileInfo.Size(),
+		})
+		paths = append(paths, path.Dir(blobPath))
+
+		return nil
+	})
+
+	if err != nil {
+		if isWalkRootNotFound(err, root) {
+			return nil, nil // no blobs
+		}
+		return nil, err
+	}
+
+	if dryRun {
+		return swept, nil
+	}
+
+	var deleted []distribution.Descriptor
+	for i, blobPath := range paths {
+		// Check the blob again, right before deleting it, in case it was
+		// pushed or linked again since the walk.
+		fileInfo, err := driver.Stat(ctx, path.Join(blobPath, "data"))
+		if err != nil {
+			switch err.(type) {
+			case storageDriver.PathNotFoundError:
+				continue // already gone
+			default:
+				return deleted, err
+			}
+		}
+
+		if !fileInfo.ModTime().Before(olderThan) {
+			context.GetLogger(ctx).Infof("not sweeping blob %v: modified during collection", swept[i].Digest)
+			continue
+		}
+
+		touched, err := blobTouchedSince(ctx, driver, swept[i].Digest, olderThan)
+		if err != nil {
+			return deleted, err
+		}
+
+		if touched {
+			context.GetLogger(ctx).Infof("not sweeping blob %v: linked during collection", swept[i].Digest)
+			continue
+		}
+
+		context.GetLogger(ctx).Infof("sweeping blob %v (%d bytes)", swept[i].Digest, swept[i].Length)
+		if err := driver.Delete(ctx, blobPath); err != nil {
+			return deleted, err
+		}
+
+		deleted = append(deleted, swept[i])
+	}
+
+	return deleted, nil
+}
+
+// blobTouchedSince reports whether the blob was linked into a repository at
+// or after t, according to its touchedat file. Blobs are only touched when
+// the registry has online garbage collection enabled.
+func blobTouchedSince(ctx context.Context, driver storageDriver.StorageDriver, dgst digest.Digest, t time.Time) (bool, error) {
+	touchedAtPath, err := defaultPathMapper.path(blobTouchedAtPathSpec{digest: dgst})
+	if err != nil {
+		return false, err
+	}
+
+	fileInfo, err := driver.Stat(ctx, touchedAtPath)
+	if err != nil {
+		switch err.(type) {
+		case storageDriver.PathNotFoundError:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return !fileInfo.ModTime().Before(t), nil
+}
+
+// blobDigestFromPath recovers the digest of a blob from the path of its data
+// file, relative to the blob store root. Only paths of the form
+// <algorithm>/<first two hex bytes of digest>/<hex digest>/data are
+// recognized. Anything else is left alone.
+func blobDigestFromPath(root, p string) (digest.Digest, bool) {
+	components := strings.Split(strings.TrimPrefix(p, root+"/"), "/")
+	if len(components) != 4 || components[3] != "data" {
+		return "", false
+	}
+
+	dgst := digest.NewDigestFromHex(components[0], components[2])
+	if err := dgst.Validate(); err != nil {
+		return "", false
+	}
+
+	if expected, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst}); err != nil || expected != p {
+		return "", false
+	}
+
+	return dgst, true
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+func TestMarkAndSweep(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+
+	// An empty registry has nothing to sweep.
+	swept, err := MarkAndSweep(env.ctx, env.driver, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error collecting empty registry: %v", err)
+	}
+
+	if len(swept) != 0 {
+		t.Fatalf("unexpected blobs swept from empty registry: %v", swept)
+	}
+
+	kept := putTestManifest(t, env, env.tag)
+	deleted := putTestManifest(t, env, "othertag")
+
+	deletedPayload, err := deleted.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest payload: %v", err)
+	}
+
+	deletedDigest, err := digest.FromBytes(deletedPayload)
+	if err != nil {
+		t.Fatalf("unexpected error digesting manifest payload: %v", err)
+	}
+
+	if err := env.repository.Manifests().Delete(deletedDigest); err != nil {
+		t.Fatalf("unexpected error deleting manifest: %v", err)
+	}
+
+	// Unlink one of the layers of the deleted manifest, leaving its data
+	// orphaned.
+	blobs := env.repository.Blobs(env.ctx)
+	orphan := deleted.FSLayers[0].BlobSum
+	orphanDesc, err := blobs.Stat(env.ctx, orphan)
+	if err != nil {
+		t.Fatalf("unexpected error stating layer: %v", err)
+	}
+
+	if err := blobs.Delete(env.ctx, orphan); err != nil {
+		t.Fatalf("unexpected error deleting layer: %v", err)
+	}
+
+	// The deleted manifest, its signature and the orphaned layer should be
+	// swept. The signature digest isn't known here, so it is only counted.
+	expected := map[digest.Digest]struct{}{
+		deletedDigest:     {},
+		orphanDesc.Digest: {},
+	}
+
+	checkSwept := func(swept []distribution.Descriptor) {
+		if len(swept) != len(expected)+1 {
+			t.Fatalf("unexpected blobs swept: %v", swept)
+		}
+
+		sweptDigests := make(map[digest.Digest]struct{})
+		for _, desc := range swept {
+			if desc.Length <= 0 {
+				t.Fatalf("unexpected length for swept blob %v: %d", desc.Digest, desc.Length)
+			}
+
+			sweptDigests[desc.Digest] = struct{}{}
+		}
+
+		for dgst := range expected {
+			if _, ok := sweptDigests[dgst]; !ok {
+				t.Fatalf("expected blob %v to be swept: %v", dgst, swept)
+			}
+		}
+
+		for _, fsLayer := range kept.FSLayers {
+			desc, err := blobs.Stat(env.ctx, fsLayer.BlobSum)
+			if err != nil {
+				t.Fatalf("unexpected error stating kept layer: %v", err)
+			}
+
+			if _, ok := sweptDigests[desc.Digest]; ok {
+				t.Fatalf("referenced blob %v should not be swept", desc.Digest)
+			}
+		}
+	}
+
+	// A dry run reports the blobs without deleting them.
+	swept, err = MarkAndSweep(env.ctx, env.driver, 0, true)
+	if err != nil {
+		t.Fatalf("unexpected error during dry run: %v", err)
+	}
+	checkSwept(swept)
+
+	for dgst := range expected {
+		if _, err := env.registry.(*registry).blobStore.statter.Stat(env.ctx, dgst); err != nil {
+			t.Fatalf("blob %v should not be deleted by a dry run: %v", dgst, err)
+		}
+	}
+
+	swept, err = MarkAndSweep(env.ctx, env.driver, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error collecting garbage: %v", err)
+	}
+	checkSwept(swept)
+
+	for dgst := range expected {
+		blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+		if err != nil {
+			t.Fatalf("unexpected error getting blob path: %v", err)
+		}
+
+		if _, err := env.driver.Stat(env.ctx, blobPath); err == nil {
+			t.Fatalf("blob %v should have been swept", dgst)
+		}
+	}
+
+	// Everything referenced by the remaining manifest must be intact.
+	if _, err := env.repository.Manifests().GetByTag(env.tag); err != nil {
+		t.Fatalf("unexpected error fetching kept manifest: %v", err)
+	}
+
+	for _, fsLayer := range kept.FSLayers {
+		if _, err := blobs.Get(env.ctx, fsLayer.BlobSum); err != nil {
+			t.Fatalf("unexpected error reading kept layer %v: %v", fsLayer.BlobSum, err)
+		}
+	}
+
+	// A second pass has nothing left to do.
+	swept, err = MarkAndSweep(env.ctx, env.driver, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error collecting garbage: %v", err)
+	}
+
+	if len(swept) != 0 {
+		t.Fatalf("unexpected blobs swept on second pass: %v", swept)
+	}
+}
+
+// TestMarkAndSweepGracePeriod ensures that blobs written within the grace
+// period are never swept, even if they are not yet referenced.
+func TestMarkAndSweepGracePeriod(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	blobs := env.repository.Blobs(env.ctx)
+
+	// Simulate a push in progress: the blob data has been written but it
+	// hasn't been linked into the repository yet.
+	desc, err := blobs.Put(env.ctx, "application/octet-stream", []byte("in flight"))
+	if err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	if err := blobs.Delete(env.ctx, desc.Digest); err != nil {
+		t.Fatalf("unexpected error unlinking blob: %v", err)
+	}
+
+	swept, err := MarkAndSweep(env.ctx, env.driver, time.Hour, false)
+	if err != nil {
+		t.Fatalf("unexpected error collecting garbage: %v", err)
+	}
+
+	if len(swept) != 0 {
+		t.Fatalf("blobs within the grace period should not be swept: %v", swept)
+	}
+
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: desc.Digest})
+	if err != nil {
+		t.Fatalf("unexpected error getting blob path: %v", err)
+	}
+
+	if _, err := env.driver.Stat(env.ctx, blobPath); err != nil {
+		t.Fatalf("blob within the grace period was removed: %v", err)
+	}
+
+	// Once outside of the grace period, the blob can be collected.
+	swept, err = MarkAndSweep(env.ctx, env.driver, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected error collecting garbage: %v", err)
+	}
+
+	if len(swept) != 1 || swept[0].Digest != desc.Digest {
+		t.Fatalf("unexpected blobs swept: %v", swept)
+	}
+}
+
+// TestMarkAndSweepMountDuringCollection ensures that an existing blob mounted
+// into a repository after the mark phase is not swept when online garbage
+// collection is enabled.
+func TestMarkAndSweepMountDuringCollection(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+
+	for _, onlineGC := range []bool{false, true} {
+		var options []RegistryOption
+		if onlineGC {
+			options = append(options, EnableOnlineGC())
+		}
+		registry := NewRegistryWithDriver(env.ctx, env.driver, nil, options...)
+
+		source, err := registry.Repository(env.ctx, "foo/source")
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		destination, err := registry.Repository(env.ctx, "foo/destination")
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		content := []byte(fmt.Sprintf("mounted with online gc %v", onlineGC))
+		desc, err := source.Blobs(env.ctx).Put(env.ctx, "application/octet-stream", content)
+		if err != nil {
+			t.Fatalf("unexpected error putting blob: %v", err)
+		}
+
+		// The blob data predates the collection.
+		time.Sleep(10 * time.Millisecond)
+		olderThan := time.Now()
+
+		// The mark phase walks the destination before the mount and the
+		// source after the blob is deleted from it, so the blob isn't
+		// marked although it stays linked.
+		if _, err := destination.Blobs(env.ctx).Mount(env.ctx, source.Name(), desc.Digest); err != nil {
+			t.Fatalf("unexpected error mounting blob: %v", err)
+		}
+
+		if err := source.Blobs(env.ctx).Delete(env.ctx, desc.Digest); err != nil {
+			t.Fatalf("unexpected error deleting blob: %v", err)
+		}
+
+		swept, err := sweepBlobs(env.ctx, env.driver, map[digest.Digest]struct{}{}, olderThan, false)
+		if err != nil {
+			t.Fatalf("unexpected error sweeping blobs: %v", err)
+		}
+
+		if !onlineGC {
+			// Without online garbage collection, the mounted blob is lost.
+			if len(swept) != 1 || swept[0].Digest != desc.Digest {
+				t.Fatalf("expected the mounted blob to be swept, got %v", swept)
+			}
+			continue
+		}
+
+		if len(swept) != 0 {
+			t.Fatalf("mounted blob should not be swept: %v", swept)
+		}
+
+		if _, err := destination.Blobs(env.ctx).Get(env.ctx, desc.Digest); err != nil {
+			t.Fatalf("unexpected error getting mounted blob: %v", err)
+		}
+	}
+}
+
+// failingListDriver fails to list the directories whose path ends with fail,
+// as a storage backend throttling requests would.
+type failingListDriver struct {
+	storageDriver.StorageDriver
+	fail string
+}
+
+func (d *failingListDriver) List(ctx context.Context, p string) ([]string, error) {
+	if strings.HasSuffix(p, d.fail) {
+		return nil, fmt.Errorf("list %s: request throttled", p)
+	}
+
+	return d.StorageDriver.List(ctx, p)
+}
+
+// TestMarkAndSweepListError ensures that an error listing a directory deep in
+// a repository fails the collection instead of sweeping the blobs that the
+// unlisted links reference.
+func TestMarkAndSweepListError(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	putTestManifest(t, env, env.tag)
+
+	sizes, err := blobDataSizes(env.ctx, env.driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing blobs: %v", err)
+	}
+
+	driver := &failingListDriver{StorageDriver: env.driver, fail: "/_layers"}
+	swept, err := MarkAndSweep(env.ctx, driver, 0, false)
+	if err == nil {
+		t.Fatalf("expected error collecting garbage with a failing driver")
+	}
+
+	if len(swept) != 0 {
+		t.Fatalf("unexpected blobs swept: %v", swept)
+	}
+
+	after, err := blobDataSizes(env.ctx, env.driver)
+	if err != nil {
+		t.Fatalf("unexpected error listing blobs: %v", err)
+	}
+
+	if len(after) != len(sizes) {
+		t.Fatalf("blobs were swept after a failed mark: %d != %d", len(after), len(sizes))
+	}
+}
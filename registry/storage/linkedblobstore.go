@@ -2,6 +2,7 @@ package storage
 
 import (
 	"net/http"
+	"path"
 	"time"
 
 	"code.google.com/p/go-uuid/uuid"
@@ -21,11 +22,23 @@ type linkedBlobStore struct {
 	repository distribution.Repository
 	ctx        context.Context // only to be used where context can't come through method args
 
+	// descriptorCache, if set, is the repository scoped descriptor cache,
+	// which must be cleared when a blob link is removed.
+	descriptorCache distribution.BlobDescriptorService
+
 	// linkPath allows one to control the repository blob link set to which
 	// the blob store dispatches. This is required because manifest and layer
 	// blobs have not yet been fully merged. At some point, this functionality
 	// should be removed an the blob links folder should be merged.
 	linkPath func(pm *pathMapper, name string, dgst digest.Digest) (string, error)
+
+	// quotaUsage, if set, enforces the quotas matching the repository
+	// before linking a blob into it.
+	quotaUsage *quotaUsage
+
+	// digestAlgorithm is the algorithm of the canonical digest of uploaded
+	// blobs. If empty, digest.CanonicalAlgorithm is used.
+	digestAlgorithm string
 }
 
 var _ distribution.BlobStore = &linkedBlobStore{}
@@ -81,6 +94,51 @@ func (lbs *linkedBlobStore) Put(ctx context.Context, mediaType string, p []byte)
 	return desc, lbs.linkBlob(ctx, desc)
 }
 
+// Delete removes the link to the blob from the repository, revoking access to
+// it through this repository. The blob data is left in the global blob store,
+// since other repositories may still link it.
+func (lbs *linkedBlobStore) Delete(ctx context.Context, dgst digest.Digest) error {
+	canonical, err := lbs.Stat(ctx, dgst) // access check
+	if err != nil {
+		return err
+	}
+
+	dgsts := []digest.Digest{dgst}
+	if canonical.Digest != dgst {
+		dgsts = append(dgsts, canonical.Digest)
+	}
+
+	for _, dgst := range dgsts {
+		blobLinkPath, err := lbs.linkPath(lbs.pm, lbs.repository.Name(), dgst)
+		if err != nil {
+			return err
+		}
+
+		// Remove the directory containing the link, so the entry disappears
+		// from the repository entirely.
+		if err := lbs.blobStore.driver.Delete(ctx, path.Dir(blobLinkPath)); err != nil {
+			switch err.(type) {
+			case driver.PathNotFoundError:
+				// The alias may not have been linked.
+			default:
+				return err
+			}
+		}
+
+		if lbs.descriptorCache != nil {
+			if err := lbs.descriptorCache.Clear(ctx, dgst); err != nil && err != distribution.ErrBlobUnknown {
+				context.GetLogger(ctx).Errorf("error clearing descriptor %v from cache: %v", dgst, err)
+			}
+		}
+	}
+
+	if lbs.quotaUsage != nil {
+		lbs.quotaUsage.invalidate(lbs.repository.Name())
+	}
+
+	return nil
+}
+
 // Writer begins a blob write session, returning a handle.
 func (lbs *linkedBlobStore) Create(ctx context.Context) (distribution.BlobWriter, error) {
 	context.GetLogger(ctx).Debug("(*linkedBlobStore).Writer")
@@ -172,15 +230,83 @@ func (lbs *linkedBlobStore) newBlobUpload(ctx context.Context, uuid, path string
 	return bw, nil
 }
 
+// Mount links the blob identified by dgst from the repository named
+// sourceRepo into this repository. The blob data is shared, so no content is
+// copied.
+// 跨 repository 挂载 blob，只需要创建链接
+func (lbs *linkedBlobStore) Mount(ctx context.Context, sourceRepo string, dgst digest.Digest) (distribution.Descriptor, error) {
+	context.GetLogger(ctx).Debug("(*linkedBlobStore).Mount")
+
+	sourceLinkPath, err := lbs.linkPath(lbs.pm, sourceRepo, dgst)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	// The link in the source repository serves as the access check.
+	target, err := lbs.blobStore.readlink(ctx, sourceLinkPath)
+	if err != nil {
+		switch err := err.(type) {
+		case driver.PathNotFoundError:
+			return distribution.Descriptor{}, distribution.ErrBlobUnknown
+		default:
+			return distribution.Descriptor{}, err
+		}
+	}
+
+	canonical, err := lbs.blobStore.statter.Stat(ctx, target)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	if err := lbs.linkBlob(ctx, canonical, dgst); err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	return canonical, nil
+}
+
 // linkBlob links a valid, written blob into the registry under the named
-// repository for the upload controller.
+// repository for the upload controller, provided that doing so does not
+// exceed a quota.
 func (lbs *linkedBlobStore) linkBlob(ctx context.Context, canonical distribution.Descriptor, aliases ...digest.Digest) error {
+	if err := lbs.checkQuotas(ctx, canonical); err != nil {
+		return err
+	}
+
+	return lbs.createLinks(ctx, canonical, aliases...)
+}
+
+// checkQuotas returns distribution.ErrQuotaExceeded if linking the blob into
+// the repository would exceed one of the configured quotas.
+func (lbs *linkedBlobStore) checkQuotas(ctx context.Context, canonical distribution.Descriptor) error {
+	if lbs.quotaUsage == nil {
+		return nil
+	}
+
+	// Relinking a blob does not change the usage of the repository, so
+	// avoid walking the repositories in the common case.
+	if _, err := lbs.statter.Stat(ctx, canonical.Digest); err == nil {
+		return nil
+	}
+
+	return lbs.quotaUsage.check(ctx, lbs.driver, lbs.repository.Name(), canonical)
+}
+
+// createLinks writes the links for the canonical digest and its aliases,
+// without checking quotas.
+func (lbs *linkedBlobStore) createLinks(ctx context.Context, canonical distribution.Descriptor, aliases ...digest.Digest) error {
 	dgsts := append([]digest.Digest{canonical.Digest}, aliases...)
 
 	// TODO(stevvooe): Need to write out mediatype for only canonical hash
 	// since we don't care about the aliases. They are generally unused except
 	// for tarsum but those versions don't care about mediatype.
 
+	// Touch the blob before linking it, so that an online garbage
+	// collection checking the blob after the link is written keeps it.
+	if err := lbs.blobStore.touch(ctx, canonical.Digest); err != nil {
+		return err
+	}
+
 	// Don't make duplicate links.
 	seenDigests := make(map[digest.Digest]struct{}, len(dgsts))
 
@@ -200,6 +326,10 @@ func (lbs *linkedBlobStore) linkBlob(ctx context.Context, canonical distribution
 		}
 	}
 
+	if lbs.quotaUsage != nil {
+		lbs.quotaUsage.linked(lbs.repository.Name(), canonical)
+	}
+
 	return nil
 }
 
@@ -212,6 +342,13 @@ type linkedBlobStatter struct {
 	// blobs have not yet been fully merged. At some point, this functionality
 	// should be removed an the blob links folder should be merged.
 	linkPath func(pm *pathMapper, name string, dgst digest.Digest) (string, error)
+
+	// legacyLinkPath, if set, is tried when the directory of the link at
+	// linkPath exists but holds no link. Manifest revisions used to be linked
+	// with the layers, keeping only their signatures under the revision
+	// directory, and revisions pushed before they got their own link set are
+	// still found there.
+	legacyLinkPath func(pm *pathMapper, name string, dgst digest.Digest) (string, error)
 }
 
 var _ distribution.BlobStatter = &linkedBlobStatter{}
@@ -223,6 +360,10 @@ func (lbs *linkedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (dis
 	}
 
 	target, err := lbs.blobStore.readlink(ctx, blobLinkPath)
+	if _, ok := err.(driver.PathNotFoundError); ok && lbs.legacyLinkPath != nil {
+		target, err = lbs.readLegacyLink(ctx, blobLinkPath, dgst)
+	}
+
 	if err != nil {
 		switch err := err.(type) {
 		case driver.PathNotFoundError:
@@ -230,10 +371,6 @@ func (lbs *linkedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (dis
 		default:
 			return distribution.Descriptor{}, err
 		}
-
-		// TODO(stevvooe): For backwards compatibility with data in "_layers", we
-		// need to hit layerLinkPath, as well. Or, somehow migrate to the new path
-		// layout.
 	}
 
 	if target != dgst {
@@ -247,6 +384,22 @@ func (lbs *linkedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (dis
 	return lbs.blobStore.statter.Stat(ctx, target)
 }
 
+// readLegacyLink reads the link to dgst at legacyLinkPath, if the directory of
+// the missing link at blobLinkPath exists. Otherwise, a digest linked with
+// the layers would be mistaken for a manifest revision.
+func (lbs *linkedBlobStatter) readLegacyLink(ctx context.Context, blobLinkPath string, dgst digest.Digest) (digest.Digest, error) {
+	if _, err := lbs.blobStore.driver.Stat(ctx, path.Dir(blobLinkPath)); err != nil {
+		return "", err
+	}
+
+	legacyLinkPath, err := lbs.legacyLinkPath(lbs.pm, lbs.repository.Name(), dgst)
+	if err != nil {
+		return "", err
+	}
+
+	return lbs.blobStore.readlink(ctx, legacyLinkPath)
+}
+
 // blobLinkPath provides the path to the blob link, also known as layers.
 func blobLinkPath(pm *pathMapper, name string, dgst digest.Digest) (string, error) {
 	return pm.path(layerLinkPathSpec{name: name, digest: dgst})
@@ -254,5 +407,5 @@ func blobLinkPath(pm *pathMapper, name string, dgst digest.Digest) (string, erro
 
 // manifestRevisionLinkPath provides the path to the manifest revision link.
 func manifestRevisionLinkPath(pm *pathMapper, name string, dgst digest.Digest) (string, error) {
-	return pm.path(layerLinkPathSpec{name: name, digest: dgst})
+	return pm.path(manifestRevisionLinkPathSpec{name: name, revision: dgst})
 }
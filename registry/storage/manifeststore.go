@@ -1,12 +1,17 @@
 package storage
 
 import (
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/libtrust"
 )
 
@@ -34,12 +39,12 @@ func (ms *manifestStore) Exists(dgst digest.Digest) (bool, error) {
 	return true, nil
 }
 
-func (ms *manifestStore) Get(dgst digest.Digest) (*manifest.SignedManifest, error) {
+func (ms *manifestStore) Get(dgst digest.Digest) (distribution.Manifest, error) {
 	context.GetLogger(ms.ctx).Debug("(*manifestStore).Get")
 	return ms.revisionStore.get(ms.ctx, dgst)
 }
 
-func (ms *manifestStore) Put(manifest *manifest.SignedManifest) error {
+func (ms *manifestStore) Put(manifest distribution.Manifest, tag string) error {
 	context.GetLogger(ms.ctx).Debug("(*manifestStore).Put")
 
 	// Verify the manifest.
@@ -53,14 +58,45 @@ func (ms *manifestStore) Put(manifest *manifest.SignedManifest) error {
 		return err
 	}
 
+	if tag == "" {
+		return nil
+	}
+
 	// Now, tag the manifest
-	return ms.tagStore.tag(manifest.Tag, revision.Digest)
+	return ms.tagStore.tag(tag, revision.Digest)
 }
 
-// Delete removes the revision of the specified manfiest.
+// Delete removes the revision of the specified manfiest. Any tags pointing at
+// the revision are removed, as well as its entries in the tag indexes.
 func (ms *manifestStore) Delete(dgst digest.Digest) error {
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).Delete - unsupported")
-	return fmt.Errorf("deletion of manifests not supported")
+	context.GetLogger(ms.ctx).Debug("(*manifestStore).Delete")
+
+	exists, err := ms.Exists(dgst)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return distribution.ErrManifestUnknownRevision{
+			Name:     ms.repository.Name(),
+			Revision: dgst,
+		}
+	}
+
+	// Remove the tags first, so that no tag is left pointing at a missing
+	// revision if the revision removal fails.
+	if err := ms.tagStore.untagRevision(dgst); err != nil {
+		return err
+	}
+
+	return ms.revisionStore.delete(ms.ctx, dgst)
+}
+
+// DeleteByTag removes the tag from the repository. The revision that the tag
+// points at is left in place and remains available by digest.
+func (ms *manifestStore) DeleteByTag(tag string) error {
+	context.GetLogger(ms.ctx).Debug("(*manifestStore).DeleteByTag")
+	return ms.tagStore.delete(tag)
 }
 
 func (ms *manifestStore) Tags() ([]string, error) {
@@ -73,7 +109,15 @@ func (ms *manifestStore) ExistsByTag(tag string) (bool, error) {
 	return ms.tagStore.exists(tag)
 }
 
-func (ms *manifestStore) GetByTag(tag string) (*manifest.SignedManifest, error) {
+// ResolveTag returns the digest of the revision referenced by the tag, without
+// reading the manifest. It returns distribution.ErrManifestUnknown if the tag
+// does not exist.
+func (ms *manifestStore) ResolveTag(tag string) (digest.Digest, error) {
+	context.GetLogger(ms.ctx).Debug("(*manifestStore).ResolveTag")
+	return ms.tagStore.resolve(tag)
+}
+
+func (ms *manifestStore) GetByTag(tag string) (distribution.Manifest, error) {
 	context.GetLogger(ms.ctx).Debug("(*manifestStore).GetByTag")
 	dgst, err := ms.tagStore.resolve(tag)
 	if err != nil {
@@ -84,43 +128,165 @@ func (ms *manifestStore) GetByTag(tag string) (*manifest.SignedManifest, error)
 }
 
 // verifyManifest ensures that the manifest content is valid from the
-// perspective of the registry. It ensures that the signature is valid for the
-// enclosed payload. As a policy, the registry only tries to store valid
-// content, leaving trust policies of that content up to consumers.
-func (ms *manifestStore) verifyManifest(ctx context.Context, mnfst *manifest.SignedManifest) error {
+// perspective of the registry. For schema1, it ensures that the signature is
+// valid for the enclosed payload. Schema2 manifests and manifest lists are
+// unsigned and are only checked against the content they reference. Unless
+// a trust policy applies to the repository, the registry only tries to store
+// valid content, leaving trust policies of that content up to consumers.
+func (ms *manifestStore) verifyManifest(ctx context.Context, mnfst distribution.Manifest) error {
 	var errs distribution.ErrManifestVerification
-	if mnfst.Name != ms.repository.Name() {
-		errs = append(errs, fmt.Errorf("repository name does not match manifest name"))
+
+	if roots := ms.repository.trustRoots(); roots != nil {
+		if err := verifyTrust(mnfst, roots); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	if _, err := manifest.Verify(mnfst); err != nil {
-		switch err {
-		case libtrust.ErrMissingSignatureKey, libtrust.ErrInvalidJSONContent, libtrust.ErrMissingSignatureKey:
-			errs = append(errs, distribution.ErrManifestUnverified{})
-		default:
-			if err.Error() == "invalid signature" { // TODO(stevvooe): This should be exported by libtrust
+	switch mnfst := mnfst.(type) {
+	case *manifest.SignedManifest:
+		if mnfst.Name != ms.repository.Name() {
+			errs = append(errs, fmt.Errorf("repository name does not match manifest name"))
+		}
+
+		if _, err := manifest.Verify(mnfst); err != nil {
+			switch err {
+			case libtrust.ErrMissingSignatureKey, libtrust.ErrInvalidJSONContent, libtrust.ErrMissingSignatureKey:
 				errs = append(errs, distribution.ErrManifestUnverified{})
-			} else {
-				errs = append(errs, err)
+			default:
+				if err.Error() == "invalid signature" { // TODO(stevvooe): This should be exported by libtrust
+					errs = append(errs, distribution.ErrManifestUnverified{})
+				} else {
+					errs = append(errs, err)
+				}
 			}
 		}
+
+		errs = append(errs, ms.verifyBlobReferences(ctx, mnfst.References())...)
+	case *schema2.DeserializedManifest:
+		// The manifest is addressed by the digest of its content, so there
+		// is no signature to check.
+		errs = append(errs, ms.verifyBlobReferences(ctx, mnfst.References())...)
+	case *manifestlist.DeserializedManifestList:
+		errs = append(errs, ms.verifyManifestReferences(mnfst.References())...)
+	default:
+		return fmt.Errorf("unsupported manifest type %T", mnfst)
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// verifyTrust checks that the manifest carries at least one signature whose
+// certificate chain verifies against the roots. Each signature is checked on
+// its own, so signatures chaining to other roots do not reject the manifest.
+// Schema2 manifests and manifest lists are unsigned, so they are never
+// trusted.
+// 校验 manifest 签名的证书链
+func verifyTrust(mnfst distribution.Manifest, roots *x509.CertPool) error {
+	sm, ok := mnfst.(*manifest.SignedManifest)
+	if !ok {
+		return distribution.ErrManifestUntrusted{Reason: "the manifest format is unsigned"}
+	}
+
+	payload, err := sm.Payload()
+	if err != nil {
+		return distribution.ErrManifestUntrusted{Reason: err.Error()}
+	}
+
+	// The signatures are read from the envelope rather than with
+	// sm.Signatures, which sorts them by key id and fails on chained
+	// signatures carrying no key.
+	var envelope struct {
+		Signatures []json.RawMessage `json:"signatures"`
+	}
+	if err := json.Unmarshal(sm.Raw, &envelope); err != nil {
+		return distribution.ErrManifestUntrusted{Reason: err.Error()}
+	}
+
+	var reasons []string
+	for _, signature := range envelope.Signatures {
+		js, err := libtrust.NewJSONSignature(payload, signature)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+
+		// Signatures without a chain verify with no chains.
+		chains, err := js.VerifyChains(roots)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+
+		if len(chains) > 0 {
+			return nil
+		}
+	}
+
+	if len(reasons) == 0 {
+		return distribution.ErrManifestUntrusted{Reason: "no signature carries a certificate chain"}
+	}
+
+	return distribution.ErrManifestUntrusted{Reason: strings.Join(reasons, "; ")}
+}
+
+// verifyBlobReferences checks that the blobs referenced by a manifest are
+// present in the repository.
+func (ms *manifestStore) verifyBlobReferences(ctx context.Context, references []distribution.Descriptor) []error {
+	if ms.repository.skipLayerVerification {
+		return nil
 	}
 
-	for _, fsLayer := range mnfst.FSLayers {
-		_, err := ms.repository.Blobs(ctx).Stat(ctx, fsLayer.BlobSum)
+	var errs []error
+	for _, reference := range references {
+		desc, err := ms.repository.Blobs(ctx).Stat(ctx, reference.Digest)
 		if err != nil {
 			if err != distribution.ErrBlobUnknown {
 				errs = append(errs, err)
 			}
 
 			// On error here, we always append unknown blob errors.
-			errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: fsLayer.BlobSum})
+			errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: reference.Digest})
+			continue
+		}
+
+		// Schema2 descriptors carry the length of the referenced blob,
+		// which must match the content in the registry.
+		if reference.Length != 0 && reference.Length != desc.Length {
+			errs = append(errs, fmt.Errorf("length of blob %v does not match manifest: %d != %d", reference.Digest, desc.Length, reference.Length))
 		}
 	}
 
-	if len(errs) != 0 {
-		return errs
+	return errs
+}
+
+// verifyManifestReferences checks that the manifests referenced by a manifest
+// list are present in the repository, in the same way that the blobs of an
+// image manifest are checked.
+// manifest list 引用的每个 manifest 都必须已存在于 repository 中
+func (ms *manifestStore) verifyManifestReferences(references []distribution.Descriptor) []error {
+	var errs []error
+	for _, reference := range references {
+		desc, err := ms.revisionStore.blobStore.Stat(ms.ctx, reference.Digest)
+		if err != nil {
+			if err != distribution.ErrBlobUnknown {
+				errs = append(errs, err)
+			}
+
+			errs = append(errs, distribution.ErrManifestUnknownRevision{
+				Name:     ms.repository.Name(),
+				Revision: reference.Digest,
+			})
+			continue
+		}
+
+		if reference.Length != 0 && reference.Length != desc.Length {
+			errs = append(errs, fmt.Errorf("length of manifest %v does not match manifest list: %d != %d", reference.Digest, desc.Length, reference.Length))
+		}
 	}
 
-	return nil
+	return errs
 }
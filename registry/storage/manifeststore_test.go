@@ -2,14 +2,20 @@ package storage
 
 import (
 	"bytes"
+	"crypto/x509"
+	"encoding/json"
 	"io"
+	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/registry/storage/cache"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
@@ -102,7 +108,7 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("error signing manifest: %v", err)
 	}
 
-	err = ms.Put(sm)
+	err = ms.Put(sm, env.tag)
 	if err == nil {
 		t.Fatalf("expected errors putting manifest")
 	}
@@ -138,7 +144,7 @@ func TestManifestStorage(t *testing.T) {
 		}
 	}
 
-	if err = ms.Put(sm); err != nil {
+	if err = ms.Put(sm, env.tag); err != nil {
 		t.Fatalf("unexpected error putting manifest: %v", err)
 	}
 
@@ -160,7 +166,7 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("fetched manifest not equal: %#v != %#v", fetchedManifest, sm)
 	}
 
-	fetchedJWS, err := libtrust.ParsePrettySignature(fetchedManifest.Raw, "signatures")
+	fetchedJWS, err := libtrust.ParsePrettySignature(fetchedManifest.Content(), "signatures")
 	if err != nil {
 		t.Fatalf("unexpected error parsing jws: %v", err)
 	}
@@ -243,7 +249,7 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("unexpected number of signatures: %d != %d", len(sigs2), 1)
 	}
 
-	if err = ms.Put(sm2); err != nil {
+	if err = ms.Put(sm2, env.tag); err != nil {
 		t.Fatalf("unexpected error putting manifest: %v", err)
 	}
 
@@ -252,7 +258,7 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("unexpected error fetching manifest: %v", err)
 	}
 
-	if _, err := manifest.Verify(fetched); err != nil {
+	if _, err := manifest.Verify(fetched.(*manifest.SignedManifest)); err != nil {
 		t.Fatalf("unexpected error verifying manifest: %v", err)
 	}
 
@@ -267,7 +273,7 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("unexpected error getting expected signatures: %v", err)
 	}
 
-	receivedJWS, err := libtrust.ParsePrettySignature(fetched.Raw, "signatures")
+	receivedJWS, err := libtrust.ParsePrettySignature(fetched.Content(), "signatures")
 	if err != nil {
 		t.Fatalf("unexpected error parsing jws: %v", err)
 	}
@@ -292,11 +298,654 @@ func TestManifestStorage(t *testing.T) {
 		}
 	}
 
-	// TODO(stevvooe): Currently, deletes are not supported due to some
-	// complexity around managing tag indexes. We'll add this support back in
-	// when the manifest format has settled. For now, we expect an error for
-	// all deletes.
-	if err := ms.Delete(dgst); err == nil {
-		t.Fatalf("unexpected an error deleting manifest by digest: %v", err)
+	// Delete the manifest by digest and ensure that the revision and the tag
+	// pointing at it are gone.
+	if err := ms.Delete(dgst); err != nil {
+		t.Fatalf("unexpected error deleting manifest by digest: %v", err)
 	}
+
+	exists, err = ms.Exists(dgst)
+	if err != nil {
+		t.Fatalf("error checking manifest existence by digest: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("manifest %s should not exist after delete", dgst)
+	}
+
+	if _, err := ms.Get(dgst); true {
+		switch err.(type) {
+		case distribution.ErrManifestUnknownRevision:
+			break
+		default:
+			t.Fatalf("expected manifest unknown revision error: %#v", err)
+		}
+	}
+
+	exists, err = ms.ExistsByTag(env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error checking manifest existence: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("tag %q should have been removed with the manifest", env.tag)
+	}
+
+	tags, err = ms.Tags()
+	if err != nil {
+		t.Fatalf("unexpected error fetching tags: %v", err)
+	}
+
+	if len(tags) != 0 {
+		t.Fatalf("unexpected tags after delete: %v", tags)
+	}
+
+	// The layers must still be available to the repository.
+	for _, fsLayer := range m.FSLayers {
+		if _, err := env.repository.Blobs(env.ctx).Stat(env.ctx, fsLayer.BlobSum); err != nil {
+			t.Fatalf("unexpected error stating layer %v after manifest delete: %v", fsLayer.BlobSum, err)
+		}
+	}
+
+	// A second delete should report the revision as unknown.
+	if err := ms.Delete(dgst); true {
+		switch err.(type) {
+		case distribution.ErrManifestUnknownRevision:
+			break
+		default:
+			t.Fatalf("expected manifest unknown revision error: %#v", err)
+		}
+	}
+}
+
+func TestManifestStorageDeleteByTag(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	ms := env.repository.Manifests()
+
+	sm := putTestManifest(t, env, env.tag)
+
+	payload, err := sm.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest payload: %v", err)
+	}
+
+	dgst, err := digest.FromBytes(payload)
+	if err != nil {
+		t.Fatalf("unexpected error digesting manifest payload: %v", err)
+	}
+
+	if err := ms.DeleteByTag(env.tag); err != nil {
+		t.Fatalf("unexpected error deleting tag: %v", err)
+	}
+
+	exists, err := ms.ExistsByTag(env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error checking tag existence: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("tag %q should not exist after delete", env.tag)
+	}
+
+	// The revision must be left in place.
+	exists, err = ms.Exists(dgst)
+	if err != nil {
+		t.Fatalf("unexpected error checking manifest existence: %v", err)
+	}
+
+	if !exists {
+		t.Fatalf("manifest %s should still exist after untag", dgst)
+	}
+
+	if err := ms.DeleteByTag(env.tag); true {
+		switch err.(type) {
+		case distribution.ErrManifestUnknown:
+			break
+		default:
+			t.Fatalf("expected manifest unknown error: %#v", err)
+		}
+	}
+}
+
+// TestManifestStorageLegacyRevisionLink checks that revisions linked with the
+// layers, as they were before revisions had their own link set, can still be
+// fetched and deleted.
+func TestManifestStorageLegacyRevisionLink(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	ms := env.repository.Manifests()
+
+	sm := putTestManifest(t, env, env.tag)
+
+	payload, err := sm.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest payload: %v", err)
+	}
+
+	dgst, err := digest.FromBytes(payload)
+	if err != nil {
+		t.Fatalf("unexpected error digesting manifest payload: %v", err)
+	}
+
+	linkPath, err := pm.path(manifestRevisionLinkPathSpec{name: env.name, revision: dgst})
+	if err != nil {
+		t.Fatalf("unexpected error resolving path: %v", err)
+	}
+
+	legacyLinkPath, err := pm.path(layerLinkPathSpec{name: env.name, digest: dgst})
+	if err != nil {
+		t.Fatalf("unexpected error resolving path: %v", err)
+	}
+
+	if err := env.driver.Move(env.ctx, linkPath, legacyLinkPath); err != nil {
+		t.Fatalf("unexpected error moving revision link: %v", err)
+	}
+
+	fetched, err := ms.GetByTag(env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching legacy revision: %v", err)
+	}
+
+	if !bytes.Equal(fetched.Content(), sm.Content()) {
+		t.Fatalf("fetched manifest does not match: %s != %s", fetched.Content(), sm.Content())
+	}
+
+	if err := ms.Delete(dgst); err != nil {
+		t.Fatalf("unexpected error deleting legacy revision: %v", err)
+	}
+
+	exists, err := ms.Exists(dgst)
+	if err != nil {
+		t.Fatalf("unexpected error checking manifest existence: %v", err)
+	}
+
+	if exists {
+		t.Fatalf("manifest %s should not exist after delete", dgst)
+	}
+
+	if _, err := env.driver.Stat(env.ctx, legacyLinkPath); err == nil {
+		t.Fatalf("legacy revision link should be removed")
+	}
+}
+
+// TestSchema2ManifestStorage checks that schema2 manifests are verified
+// against the referenced blobs and stored unsigned, as pushed.
+func TestSchema2ManifestStorage(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/schema2", "thetag")
+	ms := env.repository.Manifests()
+	blobs := env.repository.Blobs(env.ctx)
+
+	config := []byte(`{"architecture": "amd64"}`)
+	configDigest, err := digest.FromBytes(config)
+	if err != nil {
+		t.Fatalf("unexpected error digesting config: %v", err)
+	}
+
+	m := schema2.Manifest{
+		SchemaVersion: schema2.SchemaVersion,
+		MediaType:     schema2.MediaTypeManifest,
+		Config: schema2.Descriptor{
+			MediaType: schema2.MediaTypeConfig,
+			Size:      int64(len(config)),
+			Digest:    configDigest,
+		},
+	}
+
+	layer := []byte("not really a layer")
+	layerDigest, err := digest.FromBytes(layer)
+	if err != nil {
+		t.Fatalf("unexpected error digesting layer: %v", err)
+	}
+
+	m.Layers = append(m.Layers, schema2.Descriptor{
+		MediaType: schema2.MediaTypeLayer,
+		Size:      int64(len(layer)),
+		Digest:    layerDigest,
+	})
+
+	dm, err := schema2.FromStruct(m)
+	if err != nil {
+		t.Fatalf("unexpected error creating manifest: %v", err)
+	}
+
+	// Neither the config nor the layer are present yet.
+	switch err := ms.Put(dm, env.tag).(type) {
+	case distribution.ErrManifestVerification:
+		if len(err) != 2 {
+			t.Fatalf("expected 2 verification errors: %#v", err)
+		}
+
+		for _, err := range err {
+			if _, ok := err.(distribution.ErrManifestBlobUnknown); !ok {
+				t.Fatalf("unexpected error type: %v", err)
+			}
+		}
+	default:
+		t.Fatalf("unexpected error verifying manifest: %v", err)
+	}
+
+	if _, err := blobs.Put(env.ctx, schema2.MediaTypeConfig, config); err != nil {
+		t.Fatalf("unexpected error putting config: %v", err)
+	}
+
+	if _, err := blobs.Put(env.ctx, schema2.MediaTypeLayer, layer); err != nil {
+		t.Fatalf("unexpected error putting layer: %v", err)
+	}
+
+	// The length in the manifest must match the stored blob.
+	wrongLength := m
+	wrongLength.Layers = []schema2.Descriptor{m.Layers[0]}
+	wrongLength.Layers[0].Size++
+
+	invalid, err := schema2.FromStruct(wrongLength)
+	if err != nil {
+		t.Fatalf("unexpected error creating manifest: %v", err)
+	}
+
+	if err := ms.Put(invalid, env.tag); err == nil {
+		t.Fatalf("expected error putting manifest with wrong layer length")
+	}
+
+	if err := ms.Put(dm, env.tag); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	desc, err := dm.Descriptor()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest descriptor: %v", err)
+	}
+
+	fetchedByTag, err := ms.GetByTag(env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest: %v", err)
+	}
+
+	fetchedByDigest, err := ms.Get(desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest by digest: %v", err)
+	}
+
+	for _, fetched := range []distribution.Manifest{fetchedByTag, fetchedByDigest} {
+		if !reflect.DeepEqual(fetched, dm) {
+			t.Fatalf("fetched manifest not equal: %#v != %#v", fetched, dm)
+		}
+	}
+
+	// No signatures are stored for schema2 manifests.
+	sigs, err := env.repository.Signatures().Get(desc.Digest)
+	switch err.(type) {
+	case nil:
+		if len(sigs) != 0 {
+			t.Fatalf("unexpected signatures for schema2 manifest: %d", len(sigs))
+		}
+	case driver.PathNotFoundError:
+	default:
+		t.Fatalf("unexpected error fetching signatures: %v", err)
+	}
+}
+
+// TestManifestListStorage checks that a manifest list is only accepted once
+// the manifests it references are present in the repository.
+func TestManifestListStorage(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/multiarch", "thetag")
+	ms := env.repository.Manifests()
+
+	sm := putTestManifest(t, env, "amd64")
+	desc, err := sm.Descriptor()
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest descriptor: %v", err)
+	}
+
+	unknown, err := digest.FromBytes([]byte("not a manifest"))
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	amd64 := manifestlist.ManifestDescriptor{
+		Descriptor: schema2.NewDescriptor(desc),
+		Platform:   manifestlist.PlatformSpec{Architecture: "amd64", OS: "linux"},
+	}
+
+	arm64 := manifestlist.ManifestDescriptor{
+		Descriptor: schema2.Descriptor{MediaType: manifest.ManifestMediaType, Digest: unknown},
+		Platform:   manifestlist.PlatformSpec{Architecture: "arm64", OS: "linux", Variant: "v8"},
+	}
+
+	invalid, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{amd64, arm64})
+	if err != nil {
+		t.Fatalf("unexpected error creating manifest list: %v", err)
+	}
+
+	switch err := ms.Put(invalid, env.tag).(type) {
+	case distribution.ErrManifestVerification:
+		if len(err) != 1 {
+			t.Fatalf("expected 1 verification error: %#v", err)
+		}
+
+		if err, ok := err[0].(distribution.ErrManifestUnknownRevision); !ok || err.Revision != unknown {
+			t.Fatalf("unexpected verification error: %#v", err)
+		}
+	default:
+		t.Fatalf("unexpected error verifying manifest list: %v", err)
+	}
+
+	dml, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{amd64})
+	if err != nil {
+		t.Fatalf("unexpected error creating manifest list: %v", err)
+	}
+
+	if err := ms.Put(dml, env.tag); err != nil {
+		t.Fatalf("unexpected error putting manifest list: %v", err)
+	}
+
+	fetched, err := ms.GetByTag(env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest list: %v", err)
+	}
+
+	if !reflect.DeepEqual(fetched, dml) {
+		t.Fatalf("fetched manifest list not equal: %#v != %#v", fetched, dml)
+	}
+}
+
+// putTestManifest pushes a signed manifest with random layers to the
+// repository in env, under the provided tag.
+func TestSignatureStorePut(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	sm := putTestManifest(t, env, env.tag)
+
+	payload, err := sm.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	dgst, err := digest.FromBytes(payload)
+	if err != nil {
+		t.Fatalf("unexpected error digesting payload: %v", err)
+	}
+
+	sign := func(m manifest.Manifest) [][]byte {
+		pk, err := libtrust.GenerateECP256PrivateKey()
+		if err != nil {
+			t.Fatalf("unexpected error generating private key: %v", err)
+		}
+
+		signed, err := manifest.Sign(&m, pk)
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+
+		signatures, err := signed.Signatures()
+		if err != nil {
+			t.Fatalf("unexpected error getting signatures: %v", err)
+		}
+
+		return signatures
+	}
+
+	signatures := env.repository.Signatures()
+	if err := signatures.Put(dgst, sign(sm.Manifest)...); err != nil {
+		t.Fatalf("unexpected error attaching signatures: %v", err)
+	}
+
+	other := sm.Manifest
+	other.Tag = "other"
+	if err := signatures.Put(dgst, sign(other)...); err != (distribution.ErrManifestUnverified{}) {
+		t.Fatalf("expected unverified manifest error, got %v", err)
+	}
+
+	unknown := digest.Digest("sha256:" + strings.Repeat("0", 64))
+	if err := signatures.Put(unknown, sign(sm.Manifest)...); err != (distribution.ErrManifestUnknownRevision{Name: env.name, Revision: unknown}) {
+		t.Fatalf("expected unknown revision error, got %v", err)
+	}
+
+	stored, err := signatures.Get(dgst)
+	if err != nil {
+		t.Fatalf("unexpected error fetching signatures: %v", err)
+	}
+
+	if len(stored) != 2 {
+		t.Fatalf("unexpected number of signatures: %d != 2", len(stored))
+	}
+
+	fetched, err := env.repository.Manifests().Get(dgst)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest: %v", err)
+	}
+
+	if keys, err := manifest.Verify(fetched.(*manifest.SignedManifest)); err != nil || len(keys) != 2 {
+		t.Fatalf("unexpected verification of fetched manifest: %v, %v", keys, err)
+	}
+}
+
+func TestManifestTrustPolicy(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "prod/app", "v1")
+
+	generateKey := func() libtrust.PrivateKey {
+		key, err := libtrust.GenerateECP256PrivateKey()
+		if err != nil {
+			t.Fatalf("unexpected error generating private key: %v", err)
+		}
+		return key
+	}
+
+	rootKey, otherRootKey, signingKey := generateKey(), generateKey(), generateKey()
+
+	rootCert, err := libtrust.GenerateCACert(rootKey, rootKey)
+	if err != nil {
+		t.Fatalf("unexpected error generating root certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+
+	registry := NewRegistryWithDriver(env.ctx, env.driver, cache.NewInMemoryBlobDescriptorCacheProvider(), RequireTrustedSignatures([]TrustPolicy{
+		{Pattern: "prod/*", Roots: roots},
+	}))
+
+	sign := func(name string, issuer libtrust.PrivateKey) *manifest.SignedManifest {
+		m := manifest.Manifest{
+			Versioned: manifest.Versioned{
+				SchemaVersion: 1,
+			},
+			Name: name,
+			Tag:  "v1",
+		}
+
+		if issuer == nil {
+			sm, err := manifest.Sign(&m, signingKey)
+			if err != nil {
+				t.Fatalf("error signing manifest: %v", err)
+			}
+			return sm
+		}
+
+		cert, err := libtrust.GenerateCACert(issuer, signingKey)
+		if err != nil {
+			t.Fatalf("unexpected error generating signing certificate: %v", err)
+		}
+
+		sm, err := manifest.SignWithChain(&m, signingKey, []*x509.Certificate{cert})
+		if err != nil {
+			t.Fatalf("error signing manifest: %v", err)
+		}
+		return sm
+	}
+
+	// combine joins the signatures of manifests signed over the same payload.
+	// libtrust cannot format several chained signatures, so the signature
+	// entries are appended to the raw signatures array of the first manifest.
+	combine := func(sms ...*manifest.SignedManifest) *manifest.SignedManifest {
+		raw := sms[0].Raw
+		end := bytes.LastIndex(raw, []byte("]"))
+
+		var joined []byte
+		joined = append(joined, raw[:end]...)
+		for _, sm := range sms[1:] {
+			var envelope struct {
+				Signatures []json.RawMessage `json:"signatures"`
+			}
+			if err := json.Unmarshal(sm.Raw, &envelope); err != nil {
+				t.Fatalf("unexpected error unmarshaling signatures: %v", err)
+			}
+
+			for _, signature := range envelope.Signatures {
+				joined = append(joined, ',')
+				joined = append(joined, signature...)
+			}
+		}
+		joined = append(joined, raw[end:]...)
+
+		var sm manifest.SignedManifest
+		if err := json.Unmarshal(joined, &sm); err != nil {
+			t.Fatalf("unexpected error unmarshaling manifest: %v", err)
+		}
+		return &sm
+	}
+
+	put := func(name string, sm *manifest.SignedManifest) error {
+		repo, err := registry.Repository(env.ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repo: %v", err)
+		}
+
+		return repo.Manifests().Put(sm, "v1")
+	}
+
+	checkUntrusted := func(err error) {
+		verr, ok := err.(distribution.ErrManifestVerification)
+		if !ok || len(verr) != 1 {
+			t.Fatalf("expected a single verification error, got %v", err)
+		}
+
+		if _, ok := verr[0].(distribution.ErrManifestUntrusted); !ok {
+			t.Fatalf("expected untrusted manifest error, got %v", verr[0])
+		}
+	}
+
+	checkUntrusted(put("prod/app", sign("prod/app", nil)))
+	checkUntrusted(put("prod/app", sign("prod/app", otherRootKey)))
+
+	if err := put("prod/app", sign("prod/app", rootKey)); err != nil {
+		t.Fatalf("unexpected error putting trusted manifest: %v", err)
+	}
+
+	// A single signature chaining to the roots is enough, whatever the
+	// other signatures chain to. The revision store cannot hold several
+	// chained signatures, so these are checked against the roots directly.
+	for _, sm := range []*manifest.SignedManifest{
+		combine(sign("prod/app", otherRootKey), sign("prod/app", rootKey)),
+		combine(sign("prod/app", rootKey), sign("prod/app", otherRootKey)),
+		combine(sign("prod/app", nil), sign("prod/app", rootKey)),
+	} {
+		if _, err := manifest.Verify(sm); err != nil {
+			t.Fatalf("unexpected error verifying combined manifest: %v", err)
+		}
+
+		if err := verifyTrust(sm, roots); err != nil {
+			t.Fatalf("unexpected error checking manifest with a trusted signature: %v", err)
+		}
+	}
+
+	err = verifyTrust(combine(sign("prod/app", otherRootKey), sign("prod/app", nil)), roots)
+	if _, ok := err.(distribution.ErrManifestUntrusted); !ok {
+		t.Fatalf("expected untrusted manifest error, got %v", err)
+	}
+
+	// Repositories without a trust policy accept any valid signature.
+	if err := put("dev/app", sign("dev/app", nil)); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+}
+
+func putTestManifest(t *testing.T, env *manifestStoreTestEnv, tag string) *manifest.SignedManifest {
+	m := manifest.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: env.name,
+		Tag:  tag,
+	}
+
+	for i := 0; i < 2; i++ {
+		rs, ds, err := testutil.CreateRandomTarFile()
+		if err != nil {
+			t.Fatalf("unexpected error generating test layer file")
+		}
+		dgst := digest.Digest(ds)
+
+		wr, err := env.repository.Blobs(env.ctx).Create(env.ctx)
+		if err != nil {
+			t.Fatalf("unexpected error creating test upload: %v", err)
+		}
+
+		if _, err := io.Copy(wr, rs); err != nil {
+			t.Fatalf("unexpected error copying to upload: %v", err)
+		}
+
+		if _, err := wr.Commit(env.ctx, distribution.Descriptor{Digest: dgst}); err != nil {
+			t.Fatalf("unexpected error finishing upload: %v", err)
+		}
+
+		m.FSLayers = append(m.FSLayers, manifest.FSLayer{
+			BlobSum: dgst,
+		})
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	sm, err := manifest.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	if err := env.repository.Manifests().Put(sm, tag); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	return sm
+}
+
+// putTestSchema2Manifest pushes a schema2 manifest with a random layer to the
+// repository in env, under the provided tag.
+func putTestSchema2Manifest(t *testing.T, env *manifestStoreTestEnv, tag string) *schema2.DeserializedManifest {
+	blobs := env.repository.Blobs(env.ctx)
+
+	config, err := blobs.Put(env.ctx, schema2.MediaTypeConfig, []byte(`{"architecture": "arm64"}`))
+	if err != nil {
+		t.Fatalf("unexpected error putting config: %v", err)
+	}
+
+	rs, _, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("unexpected error generating test layer file")
+	}
+
+	p, err := ioutil.ReadAll(rs)
+	if err != nil {
+		t.Fatalf("unexpected error reading test layer file: %v", err)
+	}
+
+	layer, err := blobs.Put(env.ctx, schema2.MediaTypeLayer, p)
+	if err != nil {
+		t.Fatalf("unexpected error putting layer: %v", err)
+	}
+
+	dm, err := schema2.FromStruct(schema2.Manifest{
+		SchemaVersion: schema2.SchemaVersion,
+		MediaType:     schema2.MediaTypeManifest,
+		Config:        schema2.NewDescriptor(config),
+		Layers:        []schema2.Descriptor{schema2.NewDescriptor(layer)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating manifest: %v", err)
+	}
+
+	if err := env.repository.Manifests().Put(dm, tag); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	return dm
 }
@@ -36,6 +36,8 @@ const storagePathVersion = "v2"
 // 						hashstates/<algorithm>/<offset>
 //			-> blob/<algorithm>
 //				<split directory content addressable storage>
+//			-> quarantine/<algorithm>
+//				<corrupt blob data, moved out of the blob store>
 //
 // The storage backend layout is broken up into a content- addressable blob
 // store and repositories. The content-addressable blob store holds most data
@@ -92,9 +94,15 @@ const storagePathVersion = "v2"
 //
 //	Blob Store:
 //
+// 	blobsPathSpec:                  <root>/v2/blobs/
 // 	blobPathSpec:                   <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>
 // 	blobDataPathSpec:               <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>/data
 // 	blobMediaTypePathSpec:               <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>/data
+// 	blobTouchedAtPathSpec:          <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>/touchedat
+//
+//	Quarantine:
+//
+// 	quarantineDataPathSpec:         <root>/v2/quarantine/<algorithm>/<first two hex bytes of digest>/<hex digest>/data
 //
 // For more information on the semantic meaning of each path and their
 // contents, please see the path spec documentation.
@@ -252,6 +260,24 @@ func (pm *pathMapper) path(spec pathSpec) (string, error) {
 		components = append(components, "data")
 		blobPathPrefix := append(rootPrefix, "blobs")
 		return path.Join(append(blobPathPrefix, components...)...), nil
+	case blobTouchedAtPathSpec:
+		components, err := digestPathComponents(v.digest, true)
+		if err != nil {
+			return "", err
+		}
+
+		components = append(components, "touchedat")
+		return path.Join(append(append(rootPrefix, "blobs"), components...)...), nil
+	case quarantineDataPathSpec:
+		components, err := digestPathComponents(v.digest, true)
+		if err != nil {
+			return "", err
+		}
+
+		components = append(components, "data")
+		return path.Join(append(append(rootPrefix, "quarantine"), components...)...), nil
+	case blobsPathSpec:
+		return path.Join(append(rootPrefix, "blobs")...), nil
 
 	case uploadDataPathSpec:
 		return path.Join(append(repoPrefix, v.name, "_uploads", v.id, "data")...), nil
@@ -409,6 +435,12 @@ var blobAlgorithmReplacer = strings.NewReplacer(
 
 // func (blobPathSpec) pathSpec() {}
 
+// blobsPathSpec contains the path for the root of the registry global blob
+// store.
+type blobsPathSpec struct{}
+
+func (blobsPathSpec) pathSpec() {}
+
 // blobDataPathSpec contains the path for the registry global blob store. For
 // now, this contains layer data, exclusively.
 type blobDataPathSpec struct {
@@ -417,6 +449,24 @@ type blobDataPathSpec struct {
 
 func (blobDataPathSpec) pathSpec() {}
 
+// blobTouchedAtPathSpec contains the path of the file recording the last
+// time a blob was linked into a repository. It is only written when online
+// garbage collection is enabled.
+type blobTouchedAtPathSpec struct {
+	digest digest.Digest
+}
+
+func (blobTouchedAtPathSpec) pathSpec() {}
+
+// quarantineDataPathSpec contains the path where the data of a corrupt blob
+// is kept once it is moved out of the blob store, until an operator removes
+// it.
+type quarantineDataPathSpec struct {
+	digest digest.Digest
+}
+
+func (quarantineDataPathSpec) pathSpec() {}
+
 // uploadDataPathSpec defines the path parameters of the data file for
 // uploads.
 type uploadDataPathSpec struct {
@@ -107,6 +107,25 @@ func TestPathMapper(t *testing.T) {
 			},
 			expected: "/pathmapper-test/blobs/tarsum/v1/sha256/ab/abcdefabcdefabcdef908909909/data",
 		},
+		{
+			spec: blobDataPathSpec{
+				digest: digest.Digest("sha512:abcdefabcdefabcdef908909909"),
+			},
+			expected: "/pathmapper-test/blobs/sha512/ab/abcdefabcdefabcdef908909909/data",
+		},
+		{
+			spec: quarantineDataPathSpec{
+				digest: digest.Digest("sha256:abcdefabcdefabcdef908909909"),
+			},
+			expected: "/pathmapper-test/quarantine/sha256/ab/abcdefabcdefabcdef908909909/data",
+		},
+		{
+			spec: layerLinkPathSpec{
+				name:   "foo/bar",
+				digest: "sha384:abcdef0123456789",
+			},
+			expected: "/pathmapper-test/repositories/foo/bar/_layers/sha384/abcdef0123456789/link",
+		},
 
 		{
 			spec: uploadDataPathSpec{
@@ -122,6 +141,15 @@ func TestPathMapper(t *testing.T) {
 			},
 			expected: "/pathmapper-test/repositories/foo/bar/_uploads/asdf-asdf-asdf-adsf/startedat",
 		},
+		{
+			spec: uploadHashStatePathSpec{
+				name:   "foo/bar",
+				id:     "asdf-asdf-asdf-adsf",
+				alg:    "sha512",
+				offset: 1024,
+			},
+			expected: "/pathmapper-test/repositories/foo/bar/_uploads/asdf-asdf-asdf-adsf/hashstates/sha512/1024",
+		},
 	} {
 		p, err := pm.path(testcase.spec)
 		if err != nil {
@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// Quota limits the number of bytes stored by the repositories whose names
+// match Pattern, in the syntax of path.Match. The limit applies to the
+// combined usage of all matching repositories, so a pattern without
+// wildcards limits a single repository, while a pattern such as "team/*"
+// limits a namespace.
+// 按 repository 名称模式限制存储的字节数
+type Quota struct {
+	Pattern string
+	Limit   int64
+}
+
+// QuotaUsage reports the bytes used by the repositories matching a quota.
+type QuotaUsage struct {
+	Quota
+	Used int64
+}
+
+// Usage returns the number of bytes used by the named repository, along with
+// the usage of each of the quotas whose pattern matches the name. A blob
+// counts once towards each repository it is linked into, whether as a layer,
+// a manifest revision or a signature.
+func Usage(ctx context.Context, driver storageDriver.StorageDriver, quotas []Quota, name string) (int64, []QuotaUsage, error) {
+	applicable := matchingQuotas(quotas, name)
+
+	repos, err := linkedBlobs(ctx, driver, "", func(repo string) bool {
+		return repo == name || len(matchingQuotas(applicable, repo)) > 0
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sizes := newBlobSizes(ctx, driver)
+
+	used, err := sizes.sum(repos[name])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	usages := make([]QuotaUsage, 0, len(applicable))
+	for _, quota := range applicable {
+		quotaUsed, err := sizes.quotaUsed(quota, repos)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		usages = append(usages, QuotaUsage{Quota: quota, Used: quotaUsed})
+	}
+
+	return used, usages, nil
+}
+
+// quotaUsageTTL is how long the usage of a quota is trusted before the
+// repositories matching it are walked again, to account for the links written
+// or removed by other registry instances and by the storage commands.
+const quotaUsageTTL = time.Minute
+
+// quotaUsage enforces quotas, keeping the usage of each of them so that a
+// write does not walk the matching repositories every time. Links created
+// through the registry are added to the usage as they are written, while
+// removing links invalidates the usage of the quotas matching the repository,
+// which is walked again on the next check.
+type quotaUsage struct {
+	quotas []Quota
+
+	mu     sync.Mutex
+	usages map[string]*cachedQuotaUsage // by quota pattern
+}
+
+// cachedQuotaUsage is the usage of a quota at the time of the walk, updated
+// with the links created since.
+type cachedQuotaUsage struct {
+	// repos maps each matching repository to the size of the blobs linked
+	// into it, by canonical digest.
+	repos   map[string]map[digest.Digest]int64
+	used    int64
+	expires time.Time
+}
+
+func newQuotaUsage(quotas []Quota) *quotaUsage {
+	return &quotaUsage{
+		quotas: quotas,
+		usages: make(map[string]*cachedQuotaUsage),
+	}
+}
+
+// check returns distribution.ErrQuotaExceeded if linking the blob described
+// by canonical into the named repository would take any of the quotas
+// matching the name over its limit. Linking a blob that is already linked
+// into the repository never changes its usage.
+//
+// Quotas are enforced softly: the check is not atomic with the link that
+// follows it, so concurrent writes may overrun a quota by the size of the
+// blobs in flight, and writes through other registry instances are only
+// accounted for once the usage expires.
+func (qu *quotaUsage) check(ctx context.Context, driver storageDriver.StorageDriver, name string, canonical distribution.Descriptor) error {
+	for _, quota := range matchingQuotas(qu.quotas, name) {
+		used, linked, err := qu.usage(ctx, driver, quota, name, canonical.Digest)
+		if err != nil {
+			return err
+		}
+
+		if linked {
+			return nil
+		}
+
+		if used+canonical.Length > quota.Limit {
+			return distribution.ErrQuotaExceeded{
+				Name:    name,
+				Pattern: quota.Pattern,
+				Limit:   quota.Limit,
+				Used:    used,
+				Size:    canonical.Length,
+			}
+		}
+	}
+
+	return nil
+}
+
+// usage returns the bytes used by the repositories matching quota, and
+// whether dgst is linked into the named repository, walking the repositories
+// if the usage is unknown or expired.
+func (qu *quotaUsage) usage(ctx context.Context, driver storageDriver.StorageDriver, quota Quota, name string, dgst digest.Digest) (int64, bool, error) {
+	qu.mu.Lock()
+	cached, ok := qu.usages[quota.Pattern]
+	if ok && time.Now().Before(cached.expires) {
+		_, linked := cached.repos[name][dgst]
+		used := cached.used
+		qu.mu.Unlock()
+		return used, linked, nil
+	}
+	qu.mu.Unlock()
+
+	// Walk without holding the lock, so that the checks of other quotas
+	// are not blocked meanwhile.
+	cached, err := walkQuotaUsage(ctx, driver, quota)
+	if err != nil {
+		return 0, false, err
+	}
+
+	qu.mu.Lock()
+	defer qu.mu.Unlock()
+
+	qu.usages[quota.Pattern] = cached
+	_, linked := cached.repos[name][dgst]
+	return cached.used, linked, nil
+}
+
+// linked adds the blob described by canonical, just linked into the named
+// repository, to the usage of the quotas matching the name.
+func (qu *quotaUsage) linked(name string, canonical distribution.Descriptor) {
+	qu.mu.Lock()
+	defer qu.mu.Unlock()
+
+	for pattern, cached := range qu.usages {
+		if matched, _ := path.Match(pattern, name); !matched {
+			continue
+		}
+
+		blobs, ok := cached.repos[name]
+		if !ok {
+			blobs = make(map[digest.Digest]int64)
+			cached.repos[name] = blobs
+		}
+
+		if _, ok := blobs[canonical.Digest]; ok {
+			continue
+		}
+
+		blobs[canonical.Digest] = canonical.Length
+		cached.used += canonical.Length
+	}
+}
+
+// invalidate discards the usage of the quotas matching the named repository,
+// after links were removed from it.
+func (qu *quotaUsage) invalidate(name string) {
+	qu.mu.Lock()
+	defer qu.mu.Unlock()
+
+	for pattern := range qu.usages {
+		if matched, _ := path.Match(pattern, name); matched {
+			delete(qu.usages, pattern)
+		}
+	}
+}
+
+// walkQuotaUsage walks the repositories matching quota, looking up the size
+// of the blobs linked into them.
+func walkQuotaUsage(ctx context.Context, driver storageDriver.StorageDriver, quota Quota) (*cachedQuotaUsage, error) {
+	repos, err := linkedBlobs(ctx, driver, quotaPrefix(quota.Pattern), func(repo string) bool {
+		return len(matchingQuotas([]Quota{quota}, repo)) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cached := &cachedQuotaUsage{
+		repos:   make(map[string]map[digest.Digest]int64, len(repos)),
+		expires: time.Now().Add(quotaUsageTTL),
+	}
+
+	sizes := newBlobSizes(ctx, driver)
+	for name, blobs := range repos {
+		cached.repos[name] = make(map[digest.Digest]int64, len(blobs))
+		for dgst := range blobs {
+			size, err := sizes.size(dgst)
+			if err != nil {
+				return nil, err
+			}
+
+			cached.repos[name][dgst] = size
+			cached.used += size
+		}
+	}
+
+	return cached, nil
+}
+
+// quotaPrefix returns the leading components of the pattern that hold no
+// wildcard. Only the repositories under them can match the pattern.
+func quotaPrefix(pattern string) string {
+	components := strings.Split(pattern, "/")
+	for i, component := range components {
+		if strings.ContainsAny(component, `*?[\`) {
+			return strings.Join(components[:i], "/")
+		}
+	}
+
+	return pattern
+}
+
+// matchingQuotas returns the quotas whose pattern matches the repository
+// name.
+func matchingQuotas(quotas []Quota, name string) []Quota {
+	var matching []Quota
+	for _, quota := range quotas {
+		// Patterns are validated when the registry is configured.
+		if matched, _ := path.Match(quota.Pattern, name); matched {
+			matching = append(matching, quota)
+		}
+	}
+
+	return matching
+}
+
+// linkedBlobs walks the repositories under prefix for which match returns
+// true, collecting the set of blobs linked into each of them. Uploads in
+// progress are not counted.
+func linkedBlobs(ctx context.Context, driver storageDriver.StorageDriver, prefix string, match func(name string) bool) (map[string]map[digest.Digest]struct{}, error) {
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make(map[string]map[digest.Digest]struct{})
+
+	from := path.Join(root, prefix)
+	err = Walk(ctx, driver, from, func(fileInfo storageDriver.FileInfo) error {
+		filePath := fileInfo.Path()
+		dir, file := path.Split(filePath)
+
+		if fileInfo.IsDir() {
+			if !strings.HasPrefix(file, "_") {
+				return nil // part of a repository name
+			}
+
+			if file != "_layers" && file != "_manifests" {
+				return ErrSkipDir
+			}
+
+			// Only descend into the link sets of matching repositories.
+			if !match(strings.TrimPrefix(path.Clean(dir), root+"/")) {
+				return ErrSkipDir
+			}
+
+			return nil
+		}
+
+		if file != "link" {
+			return nil
+		}
+
+		name := repositoryFromLinkPath(root, filePath)
+		if name == "" {
+			return nil
+		}
+
+		content, err := driver.GetContent(ctx, filePath)
+		if err != nil {
+			return err
+		}
+
+		dgst, err := digest.ParseDigest(string(content))
+		if err != nil {
+			return err
+		}
+
+		if repos[name] == nil {
+			repos[name] = make(map[digest.Digest]struct{})
+		}
+		repos[name][dgst] = struct{}{}
+
+		return nil
+	})
+
+	if err != nil {
+		if isWalkRootNotFound(err, from) {
+			return repos, nil // no repositories
+		}
+		return nil, err
+	}
+
+	return repos, nil
+}
+
+// repositoryFromLinkPath returns the name of the repository owning the link
+// file at p, or an empty string if p is not within a link set. Repository
+// name components never start with an underscore, so the first one that does
+// ends the name.
+func repositoryFromLinkPath(root, p string) string {
+	components := strings.Split(strings.TrimPrefix(p, root+"/"), "/")
+	for i, component := range components {
+		if component == "_layers" || component == "_manifests" {
+			return strings.Join(components[:i], "/")
+		}
+	}
+
+	return ""
+}
+
+// blobSizes looks up and remembers the size of the blobs in the global blob
+// store.
+type blobSizes struct {
+	ctx    context.Context
+	driver storageDriver.StorageDriver
+	sizes  map[digest.Digest]int64
+}
+
+func newBlobSizes(ctx context.Context, driver storageDriver.StorageDriver) *blobSizes {
+	return &blobSizes{
+		ctx:    ctx,
+		driver: driver,
+		sizes:  make(map[digest.Digest]int64),
+	}
+}
+
+// size returns the size of the blob data. Links to blobs whose data is
+// missing, such as those swept by the garbage collector, count for nothing.
+func (bs *blobSizes) size(dgst digest.Digest) (int64, error) {
+	if size, ok := bs.sizes[dgst]; ok {
+		return size, nil
+	}
+
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	fileInfo, err := bs.driver.Stat(bs.ctx, blobPath)
+	switch err.(type) {
+	case nil:
+		size = fileInfo.Size()
+	case storageDriver.PathNotFoundError:
+	default:
+		return 0, err
+	}
+
+	bs.sizes[dgst] = size
+	return size, nil
+}
+
+// sum returns the total size of the blobs in the set.
+func (bs *blobSizes) sum(blobs map[digest.Digest]struct{}) (int64, error) {
+	var total int64
+	for dgst := range blobs {
+		size, err := bs.size(dgst)
+		if err != nil {
+			return 0, err
+		}
+
+		total += size
+	}
+
+	return total, nil
+}
+
+// quotaUsed returns the combined usage of the repositories matching the
+// quota pattern.
+func (bs *blobSizes) quotaUsed(quota Quota, repos map[string]map[digest.Digest]struct{}) (int64, error) {
+	var used int64
+	for name, blobs := range repos {
+		if len(matchingQuotas([]Quota{quota}, name)) == 0 {
+			continue
+		}
+
+		size, err := bs.sum(blobs)
+		if err != nil {
+			return 0, err
+		}
+
+		used += size
+	}
+
+	return used, nil
+}
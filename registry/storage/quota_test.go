@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/libtrust"
+)
+
+func TestQuotas(t *testing.T) {
+	ctx := context.Background()
+	d := inmemory.New()
+	quotas := []Quota{
+		{Pattern: "team/*", Limit: 100},
+		{Pattern: "other", Limit: 50},
+	}
+	registry := NewRegistryWithDriver(ctx, d, cache.NewInMemoryBlobDescriptorCacheProvider(), EnforceQuotas(quotas))
+
+	blobs := func(name string) distribution.BlobStore {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository %s: %v", name, err)
+		}
+
+		return repo.Blobs(ctx)
+	}
+
+	put := func(name string, size int) (distribution.Descriptor, error) {
+		return blobs(name).Put(ctx, "application/octet-stream", bytes.Repeat([]byte(name[len(name)-1:]), size))
+	}
+
+	checkExceeded := func(err error, name, pattern string, used, size int64) {
+		exceeded, ok := err.(distribution.ErrQuotaExceeded)
+		if !ok {
+			t.Fatalf("expected quota exceeded error for %s, got %v", name, err)
+		}
+
+		expected := distribution.ErrQuotaExceeded{
+			Name:    name,
+			Pattern: pattern,
+			Limit:   exceeded.Limit,
+			Used:    used,
+			Size:    size,
+		}
+		if exceeded != expected {
+			t.Fatalf("unexpected quota exceeded error: %#v != %#v", exceeded, expected)
+		}
+	}
+
+	shared, err := put("team/aa", 60)
+	if err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	// Putting the same blob again does not change the usage.
+	if _, err := put("team/aa", 60); err != nil {
+		t.Fatalf("unexpected error putting blob again: %v", err)
+	}
+
+	// The blob counts once for each repository it is linked into, so
+	// mounting it in another repository of the namespace goes over quota.
+	_, err = blobs("team/bb").Mount(ctx, "team/aa", shared.Digest)
+	checkExceeded(err, "team/bb", "team/*", 60, 60)
+
+	if _, err := blobs("team/bb").Stat(ctx, shared.Digest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected rejected mount not to be linked: %v", err)
+	}
+
+	if _, err := put("team/bb", 30); err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	_, err = put("team/bb", 20)
+	checkExceeded(err, "team/bb", "team/*", 90, 20)
+
+	// Repositories outside the namespace are not affected.
+	if _, err := put("team/aa/nested", 40); err != nil {
+		t.Fatalf("unexpected error putting blob outside of quota: %v", err)
+	}
+
+	used, usages, err := Usage(ctx, d, quotas, "team/aa")
+	if err != nil {
+		t.Fatalf("unexpected error getting usage: %v", err)
+	}
+
+	if used != 60 || len(usages) != 1 || usages[0].Quota != quotas[0] || usages[0].Used != 90 {
+		t.Fatalf("unexpected usage: %d, %#v", used, usages)
+	}
+
+	// A rejected upload is not moved into the blob store.
+	bw, err := blobs("other").Create(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error creating upload: %v", err)
+	}
+
+	content := bytes.Repeat([]byte("o"), 51)
+	if _, err := bw.Write(content); err != nil {
+		t.Fatalf("unexpected error writing upload: %v", err)
+	}
+
+	dgst, err := digest.FromBytes(content)
+	if err != nil {
+		t.Fatalf("unexpected error digesting content: %v", err)
+	}
+
+	_, err = bw.Commit(ctx, distribution.Descriptor{Digest: dgst})
+	checkExceeded(err, "other", "other", 0, 51)
+
+	blobPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		t.Fatalf("unexpected error getting blob path: %v", err)
+	}
+
+	if _, err := d.Stat(ctx, blobPath); err == nil {
+		t.Fatalf("expected rejected blob not to be stored")
+	} else if _, ok := err.(driver.PathNotFoundError); !ok {
+		t.Fatalf("unexpected error checking blob data: %v", err)
+	}
+
+	used, usages, err = Usage(ctx, d, quotas, "other")
+	if err != nil {
+		t.Fatalf("unexpected error getting usage: %v", err)
+	}
+
+	if used != 0 || len(usages) != 1 || usages[0].Used != 0 {
+		t.Fatalf("unexpected usage: %d, %#v", used, usages)
+	}
+}
+
+func TestManifestQuota(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	sm := putTestManifest(t, env, env.tag)
+
+	payload, err := sm.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	dgst, err := digest.FromBytes(payload)
+	if err != nil {
+		t.Fatalf("unexpected error digesting payload: %v", err)
+	}
+
+	// Remove the manifest, leaving only the layers linked.
+	if err := env.repository.Manifests().Delete(dgst); err != nil {
+		t.Fatalf("unexpected error deleting manifest: %v", err)
+	}
+
+	used, _, err := Usage(env.ctx, env.driver, nil, env.name)
+	if err != nil {
+		t.Fatalf("unexpected error getting usage: %v", err)
+	}
+
+	limited := NewRegistryWithDriver(env.ctx, env.driver, nil, EnforceQuotas([]Quota{
+		{Pattern: env.name, Limit: used + int64(len(payload))/2},
+	}))
+
+	repo, err := limited.Repository(env.ctx, env.name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	if err := repo.Manifests().Put(sm, env.tag); err == nil {
+		t.Fatalf("expected error putting manifest over quota")
+	} else if _, ok := err.(distribution.ErrQuotaExceeded); !ok {
+		t.Fatalf("unexpected error putting manifest over quota: %v", err)
+	}
+
+	if exists, err := repo.Manifests().Exists(dgst); err != nil || exists {
+		t.Fatalf("expected rejected manifest not to exist: %v, %v", exists, err)
+	}
+}
+
+// TestSignatureQuota checks that storing the signatures of a revision is
+// subject to the quotas, as the revision is.
+func TestSignatureQuota(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	sm := putTestManifest(t, env, env.tag)
+
+	payload, err := sm.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	dgst, err := digest.FromBytes(payload)
+	if err != nil {
+		t.Fatalf("unexpected error digesting payload: %v", err)
+	}
+
+	used, _, err := Usage(env.ctx, env.driver, nil, env.name)
+	if err != nil {
+		t.Fatalf("unexpected error getting usage: %v", err)
+	}
+
+	limited := NewRegistryWithDriver(env.ctx, env.driver, nil, EnforceQuotas([]Quota{
+		{Pattern: env.name, Limit: used},
+	}))
+
+	repo, err := limited.Repository(env.ctx, env.name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	resigned, err := manifest.Sign(&sm.Manifest, key)
+	if err != nil {
+		t.Fatalf("unexpected error signing manifest: %v", err)
+	}
+
+	signatures, err := resigned.Signatures()
+	if err != nil {
+		t.Fatalf("unexpected error getting signatures: %v", err)
+	}
+
+	if err := repo.Signatures().Put(dgst, signatures...); err == nil {
+		t.Fatalf("expected error putting signature over quota")
+	} else if _, ok := err.(distribution.ErrQuotaExceeded); !ok {
+		t.Fatalf("unexpected error putting signature over quota: %v", err)
+	}
+}
+
+// listCountingDriver records the directories listed through it.
+type listCountingDriver struct {
+	driver.StorageDriver
+
+	mu     sync.Mutex
+	listed []string
+}
+
+func (d *listCountingDriver) List(ctx context.Context, p string) ([]string, error) {
+	d.mu.Lock()
+	d.listed = append(d.listed, p)
+	d.mu.Unlock()
+
+	return d.StorageDriver.List(ctx, p)
+}
+
+func (d *listCountingDriver) reset() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	listed := d.listed
+	d.listed = nil
+	return listed
+}
+
+// TestQuotaUsageCache checks that the usage of a quota is walked once, under
+// the namespace of the quota only, then kept up to date with the links
+// created and removed through the registry.
+func TestQuotaUsageCache(t *testing.T) {
+	ctx := context.Background()
+	d := &listCountingDriver{StorageDriver: inmemory.New()}
+	registry := NewRegistryWithDriver(ctx, d, nil, EnforceQuotas([]Quota{
+		{Pattern: "team/*", Limit: 100},
+	}))
+
+	blobs := func(name string) distribution.BlobStore {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository %s: %v", name, err)
+		}
+
+		return repo.Blobs(ctx)
+	}
+
+	put := func(name string, b byte, size int) (distribution.Descriptor, error) {
+		return blobs(name).Put(ctx, "application/octet-stream", bytes.Repeat([]byte{b}, size))
+	}
+
+	if _, err := put("other", 'o', 200); err != nil {
+		t.Fatalf("unexpected error putting blob outside of quota: %v", err)
+	}
+
+	if _, err := put("team/aa", 'a', 40); err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error getting path: %v", err)
+	}
+
+	blobsRoot, err := defaultPathMapper.path(blobsPathSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error getting path: %v", err)
+	}
+
+	for _, p := range d.reset() {
+		if !strings.HasPrefix(p, path.Join(root, "team")) && !strings.HasPrefix(p, blobsRoot) {
+			t.Fatalf("unexpected directory listed outside of the quota namespace: %s", p)
+		}
+	}
+
+	desc, err := put("team/bb", 'b', 30)
+	if err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	if listed := d.reset(); len(listed) != 0 {
+		t.Fatalf("unexpected walk with a known usage: %v", listed)
+	}
+
+	// The blobs linked since the walk are accounted for.
+	_, err = put("team/cc", 'c', 40)
+	if _, ok := err.(distribution.ErrQuotaExceeded); !ok {
+		t.Fatalf("expected quota exceeded error, got %v", err)
+	}
+
+	// Removing a link releases its usage.
+	if err := blobs("team/bb").Delete(ctx, desc.Digest); err != nil {
+		t.Fatalf("unexpected error deleting blob: %v", err)
+	}
+
+	if _, err := put("team/cc", 'c', 40); err != nil {
+		t.Fatalf("unexpected error putting blob after delete: %v", err)
+	}
+}
+
+func TestQuotaPrefix(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"team/*":         "team",
+		"team/big-image": "team/big-image",
+		"*":              "",
+		"a/b?/c":         "a",
+		"a/[bc]":         "a",
+	} {
+		if prefix := quotaPrefix(pattern); prefix != expected {
+			t.Fatalf("unexpected prefix of %q: %q != %q", pattern, prefix, expected)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"crypto/x509"
+	"path"
+
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/storage/cache"
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
@@ -16,13 +20,101 @@ type registry struct {
 	blobServer                  distribution.BlobServer
 	statter                     distribution.BlobStatter // global statter service.
 	blobDescriptorCacheProvider cache.BlobDescriptorCacheProvider
+
+	// skipLayerVerification allows manifests to be stored before the layers
+	// they reference are present, as is the case for a pull through cache.
+	skipLayerVerification bool
+
+	// quotaUsage, if set, limits the bytes linked into the repositories
+	// matching the patterns of its quotas.
+	quotaUsage *quotaUsage
+
+	// digestAlgorithms select the canonical digest algorithm of the blobs
+	// uploaded to the repositories matching their patterns.
+	digestAlgorithms []DigestAlgorithm
+
+	// trustPolicies require the manifests put in the repositories matching
+	// their patterns to be signed by a trusted certificate.
+	trustPolicies []TrustPolicy
+}
+
+// RegistryOption is the type used to configure optional behavior of a
+// registry instance.
+type RegistryOption func(*registry)
+
+// EnforceQuotas rejects writes that would take the repositories matching any
+// of the quotas over its limit.
+func EnforceQuotas(quotas []Quota) RegistryOption {
+	return func(reg *registry) {
+		if len(quotas) > 0 {
+			reg.quotaUsage = newQuotaUsage(quotas)
+		}
+	}
+}
+
+// EnableOnlineGC records the time blobs are linked into repositories, so that
+// a garbage collection run with a grace period while the registry is serving
+// keeps the existing blobs linked during the collection.
+func EnableOnlineGC() RegistryOption {
+	return func(reg *registry) {
+		reg.blobStore.touchLinked = true
+	}
+}
+
+// DigestAlgorithm selects the algorithm of the canonical digest of the blobs
+// uploaded to the repositories whose names match Pattern, in the syntax of
+// path.Match. Blobs are stored and hashed during upload with that algorithm,
+// whatever the algorithm of the digest provided by the client.
+// 按 repository 名称模式选择上传 blob 的规范摘要算法
+type DigestAlgorithm struct {
+	Pattern   string
+	Algorithm string
+}
+
+// UseDigestAlgorithms stores the blobs uploaded to the repositories matching
+// any of the patterns under digests of its algorithm, rather than
+// digest.CanonicalAlgorithm. Only the first matching pattern applies.
+func UseDigestAlgorithms(algorithms []DigestAlgorithm) RegistryOption {
+	return func(reg *registry) {
+		reg.digestAlgorithms = algorithms
+	}
+}
+
+// TrustPolicy requires the manifests put in the repositories whose names
+// match Pattern, in the syntax of path.Match, to carry a signature whose
+// certificate chain verifies against Roots.
+// 按 repository 名称模式要求 manifest 签名可信
+type TrustPolicy struct {
+	Pattern string
+	Roots   *x509.CertPool
+}
+
+// RequireTrustedSignatures rejects the manifests put in the repositories
+// matching any of the policies unless they are signed by a certificate
+// trusted by the roots of the first matching policy.
+func RequireTrustedSignatures(policies []TrustPolicy) RegistryOption {
+	return func(reg *registry) {
+		reg.trustPolicies = policies
+	}
 }
 
 // NewRegistryWithDriver creates a new registry instance from the provided
 // driver. The resulting registry may be shared by multiple goroutines but is
 // cheap to allocate.
 // 创建含 storageDriver 的 registry
-func NewRegistryWithDriver(ctx context.Context, driver storagedriver.StorageDriver, blobDescriptorCacheProvider cache.BlobDescriptorCacheProvider) distribution.Namespace {
+func NewRegistryWithDriver(ctx context.Context, driver storagedriver.StorageDriver, blobDescriptorCacheProvider cache.BlobDescriptorCacheProvider, options ...RegistryOption) distribution.Namespace {
+	return newRegistryWithDriver(ctx, driver, blobDescriptorCacheProvider, false, options)
+}
+
+// NewRegistryWithDriverSkipLayerVerification creates a new registry instance
+// like NewRegistryWithDriver, but manifests are accepted without checking
+// that the layers they reference are present in the repository. This is only
+// suitable for a pull through cache, where layers are fetched on demand.
+func NewRegistryWithDriverSkipLayerVerification(ctx context.Context, driver storagedriver.StorageDriver, blobDescriptorCacheProvider cache.BlobDescriptorCacheProvider, options ...RegistryOption) distribution.Namespace {
+	return newRegistryWithDriver(ctx, driver, blobDescriptorCacheProvider, true, options)
+}
+
+func newRegistryWithDriver(ctx context.Context, driver storagedriver.StorageDriver, blobDescriptorCacheProvider cache.BlobDescriptorCacheProvider, skipLayerVerification bool, options []RegistryOption) distribution.Namespace {
 
 	// create global statter, with cache.
 	var statter distribution.BlobStatter = &blobStatter{
@@ -43,7 +135,7 @@ func NewRegistryWithDriver(ctx context.Context, driver storagedriver.StorageDriv
 		statter: statter,
 	}
 
-	return &registry{
+	reg := &registry{
 		blobStore: bs,
 		blobServer: &blobServer{
 			driver:  driver,
@@ -51,7 +143,14 @@ func NewRegistryWithDriver(ctx context.Context, driver storagedriver.StorageDriv
 			pathFn:  bs.path,
 		},
 		blobDescriptorCacheProvider: blobDescriptorCacheProvider,
+		skipLayerVerification:       skipLayerVerification,
 	}
+
+	for _, option := range options {
+		option(reg)
+	}
+
+	return reg
 }
 
 // Scope returns the namespace scope for a registry. The registry
@@ -60,6 +159,16 @@ func (reg *registry) Scope() distribution.Scope {
 	return distribution.GlobalScope
 }
 
+// Catalog returns an instance of the catalog service, listing the
+// repositories in the registry.
+func (reg *registry) Catalog(ctx context.Context) distribution.CatalogService {
+	return &catalog{
+		ctx:    ctx,
+		driver: reg.blobStore.driver,
+		pm:     reg.blobStore.pm,
+	}
+}
+
 // Repository returns an instance of the repository tied to the registry.
 // Instances should not be shared between goroutines but are cheap to
 // allocate. In general, they should be request scoped.
@@ -103,6 +212,33 @@ func (repo *repository) Name() string {
 	return repo.name
 }
 
+// digestAlgorithm returns the algorithm of the canonical digest of the blobs
+// uploaded to the repository.
+func (repo *repository) digestAlgorithm() string {
+	for _, algorithm := range repo.digestAlgorithms {
+		// Patterns are validated when the registry is configured.
+		if matched, _ := path.Match(algorithm.Pattern, repo.name); matched {
+			return algorithm.Algorithm
+		}
+	}
+
+	return digest.CanonicalAlgorithm
+}
+
+// trustRoots returns the certificates trusted to sign the manifests of the
+// repository, or nil if its manifests need not be signed by a trusted
+// certificate.
+func (repo *repository) trustRoots() *x509.CertPool {
+	for _, policy := range repo.trustPolicies {
+		// Patterns are validated when the registry is configured.
+		if matched, _ := path.Match(policy.Pattern, repo.name); matched {
+			return policy.Roots
+		}
+	}
+
+	return nil
+}
+
 // Manifests returns an instance of ManifestService. Instantiation is cheap and
 // may be context sensitive in the future. The instance should be used similar
 // to a request local.
@@ -114,20 +250,7 @@ func (repo *repository) Manifests() distribution.ManifestService {
 		revisionStore: &revisionStore{
 			ctx:        repo.ctx,
 			repository: repo,
-			blobStore: &linkedBlobStore{
-				ctx:        repo.ctx,
-				blobStore:  repo.blobStore,
-				repository: repo,
-				statter: &linkedBlobStatter{
-					blobStore:  repo.blobStore,
-					repository: repo,
-					linkPath:   manifestRevisionLinkPath,
-				},
-
-				// TODO(stevvooe): linkPath limits this blob store to only
-				// manifests. This instance cannot be used for blob checks.
-				linkPath: manifestRevisionLinkPath,
-			},
+			blobStore:  repo.revisions(),
 		},
 		tagStore: &tagStore{
 			ctx:        repo.ctx,
@@ -137,6 +260,27 @@ func (repo *repository) Manifests() distribution.ManifestService {
 	}
 }
 
+// revisions returns the blob store of the manifest revisions linked into the
+// repository.
+func (repo *repository) revisions() *linkedBlobStore {
+	return &linkedBlobStore{
+		ctx:        repo.ctx,
+		blobStore:  repo.blobStore,
+		repository: repo,
+		statter: &linkedBlobStatter{
+			blobStore:      repo.blobStore,
+			repository:     repo,
+			linkPath:       manifestRevisionLinkPath,
+			legacyLinkPath: blobLinkPath,
+		},
+
+		// TODO(stevvooe): linkPath limits this blob store to only
+		// manifests. This instance cannot be used for blob checks.
+		linkPath:   manifestRevisionLinkPath,
+		quotaUsage: repo.quotaUsage,
+	}
+}
+
 // Blobs returns an instance of the BlobStore. Instantiation is cheap and
 // may be context sensitive in the future. The instance should be used similar
 // to a request local.
@@ -156,22 +300,21 @@ func (repo *repository) Blobs(ctx context.Context) distribution.BlobStore {
 	}
 
 	return &linkedBlobStore{
-		blobStore:  repo.blobStore,
-		blobServer: repo.blobServer,
-		statter:    statter,
-		repository: repo,
-		ctx:        ctx,
+		blobStore:       repo.blobStore,
+		blobServer:      repo.blobServer,
+		statter:         statter,
+		repository:      repo,
+		ctx:             ctx,
+		descriptorCache: repo.descriptorCache,
 
 		// TODO(stevvooe): linkPath limits this blob store to only layers.
 		// This instance cannot be used for manifest checks.
-		linkPath: blobLinkPath,
+		linkPath:        blobLinkPath,
+		quotaUsage:      repo.quotaUsage,
+		digestAlgorithm: repo.digestAlgorithm(),
 	}
 }
 
 func (repo *repository) Signatures() distribution.SignatureService {
-	return &signatureStore{
-		repository: repo,
-		blobStore:  repo.blobStore,
-		ctx:        repo.ctx,
-	}
+	return newSignatureStore(repo.ctx, repo, repo.blobStore)
 }
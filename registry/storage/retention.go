@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// RetentionPolicy selects the tags to prune from the repositories whose names
+// match Repository, in the syntax of path.Match. Among the tags matching
+// Tags, all tags but the KeepLast most recently updated are pruned, along
+// with any tag not updated within MaxAge. A zero KeepLast or MaxAge disables
+// that rule. Tags matching any of the Protect expressions are never pruned
+// and do not count towards KeepLast.
+// 按 repository 名称模式清理 tag 的保留策略
+type RetentionPolicy struct {
+	Repository string
+	Tags       *regexp.Regexp // nil matches every tag
+	KeepLast   int
+	MaxAge     time.Duration
+	Protect    []*regexp.Regexp
+}
+
+// PrunedTag describes a tag removed, or that would be removed, by a
+// retention policy.
+type PrunedTag struct {
+	Repository string    `json:"repository"`
+	Tag        string    `json:"tag"`
+	Updated    time.Time `json:"updated"`
+	Reason     string    `json:"reason"`
+}
+
+// PruneTags applies the retention policies to the repositories of registry,
+// removing the tags they select through the manifest service of each
+// repository. Only the first policy matching a repository name applies to
+// it. The manifests referenced by the pruned tags are left in place for
+// garbage collection. If dryRun is true, the tags are reported without
+// being removed.
+func PruneTags(ctx context.Context, registry distribution.Namespace, driver storageDriver.StorageDriver, policies []RetentionPolicy, dryRun bool) ([]PrunedTag, error) {
+	return pruneTags(ctx, registry, driver, policies, time.Now(), dryRun)
+}
+
+// pruneTags implements PruneTags, measuring the age of tags from now.
+func pruneTags(ctx context.Context, registry distribution.Namespace, driver storageDriver.StorageDriver, policies []RetentionPolicy, now time.Time, dryRun bool) ([]PrunedTag, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	repos, _, err := registry.Catalog(ctx).Get(0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []PrunedTag
+	for _, name := range repos {
+		policy, ok := matchingRetentionPolicy(policies, name)
+		if !ok {
+			continue
+		}
+
+		tags, err := tagUpdates(ctx, driver, name)
+		if err != nil {
+			return pruned, err
+		}
+
+		selected := policy.selectTags(name, tags, now)
+		if len(selected) == 0 {
+			continue
+		}
+
+		if dryRun {
+			pruned = append(pruned, selected...)
+			continue
+		}
+
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			return pruned, err
+		}
+
+		manifests := repo.Manifests()
+		for _, tag := range selected {
+			if err := manifests.DeleteByTag(tag.Tag); err != nil {
+				switch err.(type) {
+				case distribution.ErrManifestUnknown:
+					continue // removed since it was listed
+				case distribution.ErrTagImmutable:
+					context.GetLogger(ctx).Warnf("retention: not pruning immutable tag %s:%s", name, tag.Tag)
+					continue
+				}
+
+				return pruned, err
+			}
+
+			pruned = append(pruned, tag)
+		}
+	}
+
+	return pruned, nil
+}
+
+// matchingRetentionPolicy returns the first policy whose pattern matches the
+// repository name.
+func matchingRetentionPolicy(policies []RetentionPolicy, name string) (RetentionPolicy, bool) {
+	for _, policy := range policies {
+		// Patterns are validated when the registry is configured.
+		if matched, _ := path.Match(policy.Repository, name); matched {
+			return policy, true
+		}
+	}
+
+	return RetentionPolicy{}, false
+}
+
+// selectTags returns the tags of the named repository to prune, given the
+// time each tag was last updated.
+func (policy RetentionPolicy) selectTags(name string, tags []tagUpdate, now time.Time) []PrunedTag {
+	var candidates []tagUpdate
+	for _, tag := range tags {
+		if policy.Tags != nil && !policy.Tags.MatchString(tag.tag) {
+			continue
+		}
+
+		if policy.protects(tag.tag) {
+			continue
+		}
+
+		candidates = append(candidates, tag)
+	}
+
+	sort.Sort(byMostRecentUpdate(candidates))
+
+	var selected []PrunedTag
+	for i, tag := range candidates {
+		var reason string
+		switch {
+		case policy.KeepLast > 0 && i >= policy.KeepLast:
+			reason = fmt.Sprintf("not among the last %d tags", policy.KeepLast)
+		case policy.MaxAge > 0 && now.Sub(tag.updated) > policy.MaxAge:
+			reason = fmt.Sprintf("not updated within %v", policy.MaxAge)
+		default:
+			continue
+		}
+
+		selected = append(selected, PrunedTag{
+			Repository: name,
+			Tag:        tag.tag,
+			Updated:    tag.updated,
+			Reason:     reason,
+		})
+	}
+
+	return selected
+}
+
+// protects returns true if the tag matches any of the protected expressions.
+func (policy RetentionPolicy) protects(tag string) bool {
+	for _, protect := range policy.Protect {
+		if protect.MatchString(tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tagUpdate records when a tag was last pointed at a revision.
+type tagUpdate struct {
+	tag     string
+	updated time.Time
+}
+
+// tagUpdates returns the tags of the named repository, along with the
+// modification time of their current link, which is rewritten each time the
+// tag is pushed.
+func tagUpdates(ctx context.Context, driver storageDriver.StorageDriver, name string) ([]tagUpdate, error) {
+	tagsPath, err := defaultPathMapper.path(manifestTagsPathSpec{name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := driver.List(ctx, tagsPath)
+	if err != nil {
+		switch err.(type) {
+		case storageDriver.PathNotFoundError:
+			return nil, nil // no tags
+		default:
+			return nil, err
+		}
+	}
+
+	var tags []tagUpdate
+	for _, entry := range entries {
+		_, tag := path.Split(entry)
+
+		currentPath, err := defaultPathMapper.path(manifestTagCurrentPathSpec{name: name, tag: tag})
+		if err != nil {
+			return nil, err
+		}
+
+		fileInfo, err := driver.Stat(ctx, currentPath)
+		if err != nil {
+			switch err.(type) {
+			case storageDriver.PathNotFoundError:
+				continue // removed since it was listed
+			default:
+				return nil, err
+			}
+		}
+
+		tags = append(tags, tagUpdate{tag: tag, updated: fileInfo.ModTime()})
+	}
+
+	return tags, nil
+}
+
+// byMostRecentUpdate sorts tags by decreasing update time, then by name.
+type byMostRecentUpdate []tagUpdate
+
+func (b byMostRecentUpdate) Len() int      { return len(b) }
+func (b byMostRecentUpdate) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byMostRecentUpdate) Less(i, j int) bool {
+	if !b[i].updated.Equal(b[j].updated) {
+		return b[i].updated.After(b[j].updated)
+	}
+
+	return b[i].tag < b[j].tag
+}
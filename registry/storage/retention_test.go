@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPruneTags(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "ci/app", "build-1")
+	for _, tag := range []string{"build-1", "release-1", "build-2", "build-3", "latest", "build-4"} {
+		putTestManifest(t, env, tag)
+	}
+
+	prune := func(policies []RetentionPolicy, now time.Time, dryRun bool) []string {
+		pruned, err := pruneTags(env.ctx, env.registry, env.driver, policies, now, dryRun)
+		if err != nil {
+			t.Fatalf("unexpected error pruning tags: %v", err)
+		}
+
+		var tags []string
+		for _, tag := range pruned {
+			if tag.Repository != env.name {
+				t.Fatalf("unexpected repository for pruned tag: %#v", tag)
+			}
+			tags = append(tags, tag.Tag)
+		}
+
+		return tags
+	}
+
+	checkTags := func(expected ...string) {
+		tags, err := env.repository.Manifests().Tags()
+		if err != nil {
+			t.Fatalf("unexpected error listing tags: %v", err)
+		}
+
+		if !reflect.DeepEqual(tags, expected) {
+			t.Fatalf("unexpected tags: %v != %v", tags, expected)
+		}
+	}
+
+	keepLast := []RetentionPolicy{
+		{Repository: "other/*", KeepLast: 1},
+		{
+			Repository: "ci/*",
+			Tags:       regexp.MustCompile("^build-"),
+			KeepLast:   2,
+			Protect:    []*regexp.Regexp{regexp.MustCompile("^build-1$")},
+		},
+		{Repository: "*/*", KeepLast: 1}, // only the first matching policy applies
+	}
+
+	if pruned := prune(keepLast, time.Now(), true); !reflect.DeepEqual(pruned, []string{"build-2"}) {
+		t.Fatalf("unexpected dry run report: %v", pruned)
+	}
+	checkTags("build-1", "build-2", "build-3", "build-4", "latest", "release-1")
+
+	if pruned := prune(keepLast, time.Now(), false); !reflect.DeepEqual(pruned, []string{"build-2"}) {
+		t.Fatalf("unexpected pruned tags: %v", pruned)
+	}
+	checkTags("build-1", "build-3", "build-4", "latest", "release-1")
+
+	// Pruning again finds nothing to do.
+	if pruned := prune(keepLast, time.Now(), false); len(pruned) != 0 {
+		t.Fatalf("unexpected pruned tags: %v", pruned)
+	}
+
+	maxAge := []RetentionPolicy{
+		{
+			Repository: "ci/app",
+			MaxAge:     24 * time.Hour,
+			Protect:    []*regexp.Regexp{regexp.MustCompile("^release-"), regexp.MustCompile("^latest$")},
+		},
+	}
+
+	if pruned := prune(maxAge, time.Now(), false); len(pruned) != 0 {
+		t.Fatalf("unexpected pruned tags: %v", pruned)
+	}
+
+	if pruned := prune(maxAge, time.Now().Add(48*time.Hour), false); !reflect.DeepEqual(pruned, []string{"build-4", "build-3", "build-1"}) {
+		t.Fatalf("unexpected pruned tags: %v", pruned)
+	}
+	checkTags("latest", "release-1")
+}
@@ -2,11 +2,13 @@ package storage
 
 import (
 	"encoding/json"
+	"path"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/libtrust"
 )
 
@@ -31,7 +33,7 @@ func newRevisionStore(ctx context.Context, repo *repository, blobStore *blobStor
 }
 
 // get retrieves the manifest, keyed by revision digest.
-func (rs *revisionStore) get(ctx context.Context, revision digest.Digest) (*manifest.SignedManifest, error) {
+func (rs *revisionStore) get(ctx context.Context, revision digest.Digest) (distribution.Manifest, error) {
 	// Ensure that this revision is available in this repository.
 	_, err := rs.blobStore.Stat(ctx, revision)
 	if err != nil {
@@ -60,6 +62,22 @@ func (rs *revisionStore) get(ctx context.Context, revision digest.Digest) (*mani
 		return nil, err
 	}
 
+	// The schema version in the content decides how the manifest is
+	// reassembled.
+	var versioned struct {
+		SchemaVersion int    `json:"schemaVersion"`
+		MediaType     string `json:"mediaType"`
+	}
+
+	if err := json.Unmarshal(content, &versioned); err != nil {
+		return nil, err
+	}
+
+	if versioned.SchemaVersion != 1 {
+		// Later schemas are stored unsigned, exactly as they were pushed.
+		return distribution.UnmarshalManifest(versioned.MediaType, content)
+	}
+
 	// Fetch the signatures for the manifest
 	signatures, err := rs.repository.Signatures().Get(revision)
 	if err != nil {
@@ -85,36 +103,114 @@ func (rs *revisionStore) get(ctx context.Context, revision digest.Digest) (*mani
 	return &sm, nil
 }
 
-// put stores the manifest in the repository, if not already present. Any
-// updated signatures will be stored, as well.
-func (rs *revisionStore) put(ctx context.Context, sm *manifest.SignedManifest) (distribution.Descriptor, error) {
+// put stores the manifest in the repository, if not already present. For
+// schema1, the payload and signatures are stored separately and any updated
+// signatures will be stored, as well. Other schemas are stored unsigned, as
+// is.
+func (rs *revisionStore) put(ctx context.Context, m distribution.Manifest) (distribution.Descriptor, error) {
+	sm, ok := m.(*manifest.SignedManifest)
+	if !ok {
+		desc, err := m.Descriptor()
+		if err != nil {
+			return distribution.Descriptor{}, err
+		}
+
+		return rs.putContent(ctx, desc.MediaType, m.Content())
+	}
+
 	// Resolve the payload in the manifest.
 	payload, err := sm.Payload()
 	if err != nil {
 		return distribution.Descriptor{}, err
 	}
 
-	// Digest and store the manifest payload in the blob store.
-	revision, err := rs.blobStore.Put(ctx, manifest.ManifestMediaType, payload)
+	revision, err := rs.putContent(ctx, manifest.ManifestMediaType, payload)
 	if err != nil {
-		context.GetLogger(ctx).Errorf("error putting payload into blobstore: %v", err)
 		return distribution.Descriptor{}, err
 	}
 
-	// Link the revision into the repository.
-	if err := rs.blobStore.linkBlob(ctx, revision); err != nil {
+	// Grab each json signature and store them.
+	signatures, err := sm.Signatures()
+	if err != nil {
 		return distribution.Descriptor{}, err
 	}
 
-	// Grab each json signature and store them.
-	signatures, err := sm.Signatures()
+	// The signatures were verified along with the manifest.
+	if err := newSignatureStore(ctx, rs.repository, rs.repository.blobStore).put(revision.Digest, signatures...); err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	return revision, nil
+}
+
+// putContent digests and stores the manifest content in the blob store,
+// linking the revision into the repository.
+func (rs *revisionStore) putContent(ctx context.Context, mediaType string, p []byte) (distribution.Descriptor, error) {
+	revision, err := rs.blobStore.Put(ctx, mediaType, p)
 	if err != nil {
+		context.GetLogger(ctx).Errorf("error putting payload into blobstore: %v", err)
 		return distribution.Descriptor{}, err
 	}
 
-	if err := rs.repository.Signatures().Put(revision.Digest, signatures...); err != nil {
+	// Link the revision into the repository.
+	if err := rs.blobStore.linkBlob(ctx, revision); err != nil {
 		return distribution.Descriptor{}, err
 	}
 
 	return revision, nil
 }
+
+// delete removes the revision from the repository, along with any signatures
+// stored for it. The underlying blobs are left in the blob store. A revision
+// pushed before revisions had their own link set is unlinked from the layers.
+func (rs *revisionStore) delete(ctx context.Context, revision digest.Digest) error {
+	revisionPath, err := rs.blobStore.pm.path(manifestRevisionPathSpec{
+		name:     rs.repository.Name(),
+		revision: revision,
+	})
+	if err != nil {
+		return err
+	}
+
+	linkPath, err := manifestRevisionLinkPath(rs.blobStore.pm, rs.repository.Name(), revision)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{revisionPath}
+	if _, err := rs.blobStore.driver.Stat(ctx, linkPath); err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			return err
+		}
+
+		legacyLinkPath, err := blobLinkPath(rs.blobStore.pm, rs.repository.Name(), revision)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path.Dir(legacyLinkPath))
+	}
+
+	deleted := false
+	for _, p := range paths {
+		if err := rs.blobStore.driver.Delete(ctx, p); err != nil {
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				continue
+			}
+			return err
+		}
+		deleted = true
+	}
+
+	if !deleted {
+		return distribution.ErrManifestUnknownRevision{
+			Name:     rs.repository.Name(),
+			Revision: revision,
+		}
+	}
+
+	if rs.blobStore.quotaUsage != nil {
+		rs.blobStore.quotaUsage.invalidate(rs.repository.Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// Reasons for which the scrubber reports a blob as corrupt.
+const (
+	// ScrubTruncated is reported for blobs with less data than the size
+	// recorded in the descriptor cache, or no data at all.
+	ScrubTruncated = "truncated"
+
+	// ScrubDigestMismatch is reported for blobs whose data does not hash
+	// to their digest.
+	ScrubDigestMismatch = "digest mismatch"
+)
+
+// ScrubOptions configures a run of ScrubBlobs.
+type ScrubOptions struct {
+	// RateLimit is the maximum number of bytes read per second, across all
+	// blobs. Zero reads as fast as the storage allows.
+	RateLimit int64
+
+	// Quarantine moves the data of corrupt blobs out of the blob store, so
+	// that the registry stops serving them. Pushing the blob again restores
+	// it.
+	Quarantine bool
+
+	// Cache, if set, provides the size of the blobs when they were pushed,
+	// to tell truncated blobs from other mismatches. It is cleared of the
+	// descriptors of quarantined blobs.
+	Cache distribution.BlobDescriptorService
+}
+
+// ScrubReport summarizes a run of ScrubBlobs.
+// blob 完整性检查的报告
+type ScrubReport struct {
+	// Blobs is the number of blobs verified.
+	Blobs int `json:"blobs"`
+
+	// Bytes is the number of bytes read.
+	Bytes int64 `json:"bytes"`
+
+	// Corrupt lists the blobs that failed verification, by digest.
+	Corrupt []CorruptBlob `json:"corrupt"`
+}
+
+// CorruptBlob describes a blob whose data failed verification.
+type CorruptBlob struct {
+	Digest digest.Digest `json:"digest"`
+
+	// Size is the size of the blob data found in the blob store.
+	Size int64 `json:"size"`
+
+	// Reason is either ScrubTruncated or ScrubDigestMismatch.
+	Reason string `json:"reason"`
+
+	// Quarantined is true if the blob data was moved out of the blob store.
+	Quarantined bool `json:"quarantined"`
+}
+
+// ScrubBlobs reads the data of every blob in the blob store, verifying that
+// it hashes to the digest of the blob. Blobs removed while the scrub runs are
+// skipped. Corrupt blobs are reported and, if options.Quarantine is set,
+// moved to the quarantine directory. An error is only returned if the scrub
+// could not complete, along with the report so far.
+// 校验 blob store 中每个 blob 的数据是否与其 digest 一致
+func ScrubBlobs(ctx context.Context, driver storageDriver.StorageDriver, options ScrubOptions) (ScrubReport, error) {
+	report := ScrubReport{
+		Corrupt: []CorruptBlob{},
+	}
+
+	sizes, err := blobDataSizes(ctx, driver)
+	if err != nil {
+		return report, err
+	}
+
+	var dgsts []string
+	for dgst := range sizes {
+		dgsts = append(dgsts, dgst.String())
+	}
+	sort.Strings(dgsts)
+
+	limiter := newRateLimiter(options.RateLimit)
+	for _, s := range dgsts {
+		dgst := digest.Digest(s)
+
+		size := sizes[dgst]
+		if options.Cache != nil {
+			if desc, err := options.Cache.Stat(ctx, dgst); err == nil {
+				size = desc.Length
+			}
+		}
+
+		corrupt, n, err := scrubBlob(ctx, driver, dgst, size, limiter)
+		report.Bytes += n
+		if err != nil {
+			if _, ok := err.(storageDriver.PathNotFoundError); ok {
+				continue
+			}
+
+			return report, err
+		}
+
+		report.Blobs++
+		if corrupt == nil {
+			continue
+		}
+
+		context.GetLogger(ctx).Errorf("scrub: blob %v is corrupt: %s", dgst, corrupt.Reason)
+
+		if options.Quarantine {
+			if err := quarantineBlob(ctx, driver, dgst, options.Cache); err != nil {
+				context.GetLogger(ctx).Errorf("scrub: error quarantining blob %v: %v", dgst, err)
+			} else {
+				corrupt.Quarantined = true
+			}
+		}
+
+		report.Corrupt = append(report.Corrupt, *corrupt)
+	}
+
+	return report, nil
+}
+
+// scrubBlob streams the data of the blob through a verifier, returning a
+// description of the corruption if it fails, along with the number of bytes
+// read. size is the expected size of the data.
+func scrubBlob(ctx context.Context, driver storageDriver.StorageDriver, dgst digest.Digest, size int64, limiter *rateLimiter) (*CorruptBlob, int64, error) {
+	verifier, err := digest.NewDigestVerifier(dgst)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dataPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rc, err := driver.ReadStream(ctx, dataPath, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+
+	n, err := io.Copy(verifier, limiter.reader(rc))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, n, err
+	}
+
+	if verifier.Verified() {
+		return nil, n, nil
+	}
+
+	corrupt := &CorruptBlob{Digest: dgst, Size: n, Reason: ScrubDigestMismatch}
+	if n == 0 || n < size || err == io.ErrUnexpectedEOF {
+		corrupt.Reason = ScrubTruncated
+	}
+
+	return corrupt, n, nil
+}
+
+// quarantineBlob moves the data of the blob to the quarantine directory,
+// replacing earlier quarantined data with the same digest. The blob is then
+// unknown to the blob statter.
+func quarantineBlob(ctx context.Context, driver storageDriver.StorageDriver, dgst digest.Digest, cache distribution.BlobDescriptorService) error {
+	dataPath, err := defaultPathMapper.path(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		return err
+	}
+
+	quarantinePath, err := defaultPathMapper.path(quarantineDataPathSpec{digest: dgst})
+	if err != nil {
+		return err
+	}
+
+	if err := driver.Move(ctx, dataPath, quarantinePath); err != nil {
+		return err
+	}
+
+	if cache != nil {
+		if err := cache.Clear(ctx, dgst); err != nil && err != distribution.ErrBlobUnknown {
+			context.GetLogger(ctx).Errorf("scrub: error clearing blob %v from cache: %v", dgst, err)
+		}
+	}
+
+	context.GetLogger(ctx).Infof("scrub: quarantined blob %v to %s", dgst, quarantinePath)
+	return nil
+}
+
+// rateLimiter paces the readers it wraps so that, together, they read no
+// more than limit bytes per second. A zero limit does not pace them.
+type rateLimiter struct {
+	limit   int64
+	start   time.Time
+	read    int64
+	sleeper func(time.Duration)
+}
+
+func newRateLimiter(limit int64) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		start:   time.Now(),
+		sleeper: time.Sleep,
+	}
+}
+
+// reader wraps r so that reads from it are paced by the limiter.
+func (rl *rateLimiter) reader(r io.Reader) io.Reader {
+	if rl.limit <= 0 {
+		return r
+	}
+
+	return &rateLimitedReader{Reader: r, limiter: rl}
+}
+
+// wait sleeps until n more bytes can be read within the limit.
+func (rl *rateLimiter) wait(n int) {
+	rl.read += int64(n)
+	due := time.Duration(float64(rl.read) / float64(rl.limit) * float64(time.Second))
+	if elapsed := time.Since(rl.start); due > elapsed {
+		rl.sleeper(due - elapsed)
+	}
+}
+
+type rateLimitedReader struct {
+	io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.limiter.wait(n)
+	return n, err
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/cache"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestScrubBlobs(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	provider := cache.NewInMemoryBlobDescriptorCacheProvider()
+	reg := NewRegistryWithDriver(ctx, driver, provider)
+
+	repo, err := reg.Repository(ctx, "foo/bar")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	put := func(content string) distribution.Descriptor {
+		desc, err := repo.Blobs(ctx).Put(ctx, "application/octet-stream", []byte(content))
+		if err != nil {
+			t.Fatalf("unexpected error putting blob: %v", err)
+		}
+
+		return desc
+	}
+
+	overwrite := func(dgst digest.Digest, content string) {
+		dataPath, err := pm.path(blobDataPathSpec{digest: dgst})
+		if err != nil {
+			t.Fatalf("unexpected error resolving path: %v", err)
+		}
+
+		if err := driver.PutContent(ctx, dataPath, []byte(content)); err != nil {
+			t.Fatalf("unexpected error overwriting blob data: %v", err)
+		}
+	}
+
+	good := put("good blob")
+	flipped := put("flipped blob")
+	truncated := put("truncated blob")
+	emptied := put("emptied blob")
+
+	// Serving the blobs records their sizes in the descriptor cache.
+	statter := reg.(*registry).blobStore.statter
+	for _, desc := range []distribution.Descriptor{good, flipped, truncated, emptied} {
+		if _, err := statter.Stat(ctx, desc.Digest); err != nil {
+			t.Fatalf("unexpected error statting blob: %v", err)
+		}
+	}
+
+	overwrite(flipped.Digest, "flipped blub")
+	overwrite(truncated.Digest, "trunc")
+	overwrite(emptied.Digest, "")
+
+	report, err := ScrubBlobs(ctx, driver, ScrubOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error scrubbing blobs: %v", err)
+	}
+
+	if report.Blobs != 4 || report.Bytes != int64(len("good blob")+len("flipped blub")+len("trunc")) {
+		t.Fatalf("unexpected scrub report: %#v", report)
+	}
+
+	// Without the sizes from the cache, shorter data is a mismatch.
+	expected := map[digest.Digest]CorruptBlob{
+		flipped.Digest:   {Digest: flipped.Digest, Size: 12, Reason: ScrubDigestMismatch},
+		truncated.Digest: {Digest: truncated.Digest, Size: 5, Reason: ScrubDigestMismatch},
+		emptied.Digest:   {Digest: emptied.Digest, Size: 0, Reason: ScrubTruncated},
+	}
+
+	if len(report.Corrupt) != len(expected) {
+		t.Fatalf("unexpected corrupt blobs: %#v", report.Corrupt)
+	}
+
+	for _, corrupt := range report.Corrupt {
+		if corrupt != expected[corrupt.Digest] {
+			t.Fatalf("unexpected corrupt blob: %#v != %#v", corrupt, expected[corrupt.Digest])
+		}
+	}
+
+	report, err = ScrubBlobs(ctx, driver, ScrubOptions{Quarantine: true, Cache: provider})
+	if err != nil {
+		t.Fatalf("unexpected error scrubbing blobs: %v", err)
+	}
+
+	if len(report.Corrupt) != len(expected) {
+		t.Fatalf("unexpected corrupt blobs: %#v", report.Corrupt)
+	}
+
+	expected[truncated.Digest] = CorruptBlob{Digest: truncated.Digest, Size: 5, Reason: ScrubTruncated}
+	for _, corrupt := range report.Corrupt {
+		want := expected[corrupt.Digest]
+		want.Quarantined = true
+		if corrupt != want {
+			t.Fatalf("unexpected corrupt blob: %#v != %#v", corrupt, want)
+		}
+	}
+
+	for _, dgst := range []digest.Digest{flipped.Digest, truncated.Digest, emptied.Digest} {
+		if _, err := statter.Stat(ctx, dgst); err != distribution.ErrBlobUnknown {
+			t.Fatalf("expected quarantined blob %v to be unknown: %v", dgst, err)
+		}
+	}
+
+	if _, err := statter.Stat(ctx, good.Digest); err != nil {
+		t.Fatalf("unexpected error statting good blob: %v", err)
+	}
+
+	quarantinePath, err := pm.path(quarantineDataPathSpec{digest: flipped.Digest})
+	if err != nil {
+		t.Fatalf("unexpected error resolving path: %v", err)
+	}
+
+	if content, err := driver.GetContent(ctx, quarantinePath); err != nil || string(content) != "flipped blub" {
+		t.Fatalf("unexpected quarantined data: %q, %v", content, err)
+	}
+
+	report, err = ScrubBlobs(ctx, driver, ScrubOptions{Quarantine: true})
+	if err != nil {
+		t.Fatalf("unexpected error scrubbing blobs: %v", err)
+	}
+
+	if report.Blobs != 1 || len(report.Corrupt) != 0 {
+		t.Fatalf("unexpected scrub report after quarantine: %#v", report)
+	}
+
+	// Pushing the blob again restores it.
+	put("flipped blob")
+	if _, err := statter.Stat(ctx, flipped.Digest); err != nil {
+		t.Fatalf("unexpected error statting restored blob: %v", err)
+	}
+}
+
+func TestScrubRateLimit(t *testing.T) {
+	var slept time.Duration
+	limiter := newRateLimiter(1000)
+	limiter.start = time.Now().Add(time.Hour) // sleep as if no time passed
+	limiter.sleeper = func(d time.Duration) {
+		slept = d
+	}
+
+	n, err := ioutil.ReadAll(limiter.reader(bytes.NewReader(make([]byte, 2500))))
+	if err != nil || len(n) != 2500 {
+		t.Fatalf("unexpected read: %d, %v", len(n), err)
+	}
+
+	// The last sleep waits for all bytes read, less the (negative) elapsed
+	// time.
+	if slept < 2500*time.Millisecond {
+		t.Fatalf("unexpected pacing: slept %v", slept)
+	}
+
+	if limiter := newRateLimiter(0); limiter.reader(bytes.NewReader(nil)) == nil {
+		t.Fatalf("expected unlimited reader")
+	}
+}
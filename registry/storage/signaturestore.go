@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"encoding/json"
 	"path"
 	"sync"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/libtrust"
 )
 
 type signatureStore struct {
@@ -42,7 +46,12 @@ func (s *signatureStore) Get(dgst digest.Digest) ([][]byte, error) {
 
 	signaturePaths, err := s.blobStore.driver.List(s.ctx, signaturesPath)
 	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case driver.PathNotFoundError:
+			return nil, nil // unsigned revision
+		default:
+			return nil, err
+		}
 	}
 
 	var wg sync.WaitGroup
@@ -105,7 +114,31 @@ loop:
 	return signatures, err
 }
 
+// Put verifies the signatures against the payload of the schema1 manifest
+// revision identified by dgst, then stores them alongside the signatures
+// already attached to the revision.
 func (s *signatureStore) Put(dgst digest.Digest, signatures ...[]byte) error {
+	payload, err := s.repository.revisions().Get(s.ctx, dgst)
+	if err != nil {
+		if err == distribution.ErrBlobUnknown {
+			return distribution.ErrManifestUnknownRevision{
+				Name:     s.repository.Name(),
+				Revision: dgst,
+			}
+		}
+
+		return err
+	}
+
+	if err := verifySignatures(payload, signatures); err != nil {
+		return err
+	}
+
+	return s.put(dgst, signatures...)
+}
+
+// put stores the signatures of the revision without verifying them.
+func (s *signatureStore) put(dgst digest.Digest, signatures ...[]byte) error {
 	bs := s.linkedBlobStore(s.ctx, dgst)
 	for _, signature := range signatures {
 		if _, err := bs.Put(s.ctx, "application/json", signature); err != nil {
@@ -115,6 +148,36 @@ func (s *signatureStore) Put(dgst digest.Digest, signatures ...[]byte) error {
 	return nil
 }
 
+// verifySignatures checks that the signatures verify against the payload of
+// a schema1 manifest. Other manifest formats are unsigned, so
+// distribution.ErrUnsupported is returned for them.
+// 校验附加签名
+func verifySignatures(payload []byte, signatures [][]byte) error {
+	var versioned manifest.Versioned
+	if err := json.Unmarshal(payload, &versioned); err != nil {
+		return err
+	}
+
+	if versioned.SchemaVersion != 1 {
+		return distribution.ErrUnsupported
+	}
+
+	if len(signatures) == 0 {
+		return distribution.ErrManifestUnverified{}
+	}
+
+	js, err := libtrust.NewJSONSignature(payload, signatures...)
+	if err != nil {
+		return distribution.ErrManifestUnverified{}
+	}
+
+	if _, err := js.Verify(); err != nil {
+		return distribution.ErrManifestUnverified{}
+	}
+
+	return nil
+}
+
 // namedBlobStore returns the namedBlobStore of the signatures for the
 // manifest with the given digest. Effectively, each singature link path
 // layout is a unique linked blob store.
@@ -136,6 +199,7 @@ func (s *signatureStore) linkedBlobStore(ctx context.Context, revision digest.Di
 			repository: s.repository,
 			linkPath:   linkpath,
 		},
-		linkPath: linkpath,
+		linkPath:   linkpath,
+		quotaUsage: s.repository.quotaUsage,
 	}
 }
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"path"
+	"sort"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
@@ -42,6 +43,9 @@ func (ts *tagStore) tags() ([]string, error) {
 		tags = append(tags, filename)
 	}
 
+	// Tags are returned in lexical order so that they can be paginated.
+	sort.Strings(tags)
+
 	return tags, nil
 }
 
@@ -119,7 +123,67 @@ func (ts *tagStore) delete(tag string) error {
 		return err
 	}
 
-	return ts.blobStore.driver.Delete(ts.ctx, tagPath)
+	if err := ts.blobStore.driver.Delete(ts.ctx, tagPath); err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			return distribution.ErrManifestUnknown{Name: ts.repository.Name(), Tag: tag}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// untagRevision removes all references to revision from the tags in the
+// repository. Tags whose current link points at the revision are removed
+// entirely, since they can no longer be resolved. For all other tags, only
+// the index entry for the revision is removed.
+func (ts *tagStore) untagRevision(revision digest.Digest) error {
+	tags, err := ts.tags()
+	if err != nil {
+		switch err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			return nil // no tags, nothing to do.
+		}
+
+		return err
+	}
+
+	for _, tag := range tags {
+		current, err := ts.resolve(tag)
+		if err != nil {
+			if _, ok := err.(distribution.ErrManifestUnknown); !ok {
+				return err
+			}
+		} else if current == revision {
+			if err := ts.delete(tag); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		indexEntryPath, err := ts.blobStore.pm.path(manifestTagIndexEntryPathSpec{
+			name:     ts.repository.Name(),
+			tag:      tag,
+			revision: revision,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := ts.blobStore.driver.Delete(ts.ctx, indexEntryPath); err != nil {
+			switch err.(type) {
+			case storagedriver.PathNotFoundError:
+				// revision was never tagged with this tag.
+			default:
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 // namedBlobStore returns the namedBlobStore for the named tag, allowing one
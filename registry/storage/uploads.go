@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.google.com/p/go-uuid/uuid"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// UploadInfo describes an upload in progress, as found in the uploads
+// directory of its repository.
+// 正在进行的上传的状态
+type UploadInfo struct {
+	Repository string `json:"repository"`
+	ID         string `json:"id"`
+
+	// Size is the number of bytes received so far.
+	Size int64 `json:"size"`
+
+	// StartedAt is the time the upload was started. It is zero if the
+	// startedat file is missing, in which case PurgeUploads never removes
+	// the upload.
+	StartedAt time.Time `json:"startedAt"`
+
+	// HashStateOffset is the largest offset at which the hash state of the
+	// upload was saved. Resuming the upload at a larger offset rehashes the
+	// data from there.
+	HashStateOffset int64 `json:"hashStateOffset"`
+}
+
+// ListUploads returns the uploads in progress in the named repository, or
+// in every repository if name is empty, ordered by repository and start
+// time.
+// 列出 repository 中正在进行的上传
+func ListUploads(ctx context.Context, driver storageDriver.StorageDriver, name string) ([]UploadInfo, error) {
+	uploads := []UploadInfo{}
+
+	if name != "" {
+		if err := v2.ValidateRespositoryName(name); err != nil {
+			return uploads, distribution.ErrRepositoryNameInvalid{Name: name, Reason: err}
+		}
+
+		found, err := repositoryUploads(ctx, driver, name)
+		if err != nil {
+			return uploads, err
+		}
+
+		uploads = append(uploads, found...)
+	} else {
+		root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+		if err != nil {
+			return uploads, err
+		}
+
+		err = Walk(ctx, driver, root, func(fileInfo storageDriver.FileInfo) error {
+			filePath := fileInfo.Path()
+			_, file := path.Split(filePath)
+			if file[0] != '_' || !fileInfo.IsDir() {
+				return nil
+			}
+
+			if file == "_uploads" {
+				name := strings.TrimPrefix(path.Dir(filePath), root+"/")
+				found, err := repositoryUploads(ctx, driver, name)
+				if err != nil {
+					return err
+				}
+
+				uploads = append(uploads, found...)
+			}
+
+			// Uploads are only found directly under a repository.
+			return ErrSkipDir
+		})
+
+		if err != nil && !isWalkRootNotFound(err, root) {
+			return uploads, err
+		}
+	}
+
+	sort.Sort(uploadsByRepository(uploads))
+	return uploads, nil
+}
+
+// CancelUpload removes the upload identified by id from the named
+// repository, along with the data received so far. The upload does not
+// need to have a valid startedat file.
+// 取消 repository 中的一个上传
+func CancelUpload(ctx context.Context, driver storageDriver.StorageDriver, name, id string) error {
+	if err := v2.ValidateRespositoryName(name); err != nil {
+		return distribution.ErrRepositoryNameInvalid{Name: name, Reason: err}
+	}
+
+	// Only accept ids that the registry could have allocated, so that the
+	// id cannot escape the uploads directory.
+	if uuid.Parse(id) == nil {
+		return distribution.ErrBlobUploadUnknown
+	}
+
+	dir, err := uploadDir(name, id)
+	if err != nil {
+		return err
+	}
+
+	if err := driver.Delete(ctx, dir); err != nil {
+		switch err.(type) {
+		case storageDriver.PathNotFoundError:
+			return distribution.ErrBlobUploadUnknown
+		default:
+			return err
+		}
+	}
+
+	context.GetLogger(ctx).Infof("cancelled upload %s in %s", id, name)
+	return nil
+}
+
+// repositoryUploads reads the state of each upload in the named repository.
+// Entries of the uploads directory that are not upload ids are ignored.
+func repositoryUploads(ctx context.Context, driver storageDriver.StorageDriver, name string) ([]UploadInfo, error) {
+	root, err := defaultPathMapper.path(repositoriesRootPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := driver.List(ctx, path.Join(root, name, "_uploads"))
+	if err != nil {
+		switch err.(type) {
+		case storageDriver.PathNotFoundError:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	var uploads []UploadInfo
+	for _, child := range children {
+		id := path.Base(child)
+		if uuid.Parse(id) == nil {
+			continue
+		}
+
+		upload, err := readUpload(ctx, driver, name, id)
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, nil
+}
+
+// readUpload reads the size, start time and hash state offset of an upload.
+// Files missing from the upload leave the corresponding fields zero.
+func readUpload(ctx context.Context, driver storageDriver.StorageDriver, name, id string) (UploadInfo, error) {
+	upload := UploadInfo{Repository: name, ID: id}
+
+	dataPath, err := defaultPathMapper.path(uploadDataPathSpec{name: name, id: id})
+	if err != nil {
+		return upload, err
+	}
+
+	fi, err := driver.Stat(ctx, dataPath)
+	switch err.(type) {
+	case nil:
+		upload.Size = fi.Size()
+	case storageDriver.PathNotFoundError:
+	default:
+		return upload, err
+	}
+
+	startedAtPath, err := defaultPathMapper.path(uploadStartedAtPathSpec{name: name, id: id})
+	if err != nil {
+		return upload, err
+	}
+
+	startedAtBytes, err := driver.GetContent(ctx, startedAtPath)
+	switch err.(type) {
+	case nil:
+		if startedAt, err := time.Parse(time.RFC3339, string(startedAtBytes)); err == nil {
+			upload.StartedAt = startedAt
+		} else {
+			context.GetLogger(ctx).Warnf("invalid startedat file for upload %s in %s: %v", id, name, err)
+		}
+	case storageDriver.PathNotFoundError:
+	default:
+		return upload, err
+	}
+
+	dir, err := uploadDir(name, id)
+	if err != nil {
+		return upload, err
+	}
+
+	// The hash states are kept per digest algorithm, by offset.
+	err = Walk(ctx, driver, path.Join(dir, "hashstates"), func(fileInfo storageDriver.FileInfo) error {
+		if fileInfo.IsDir() {
+			return nil
+		}
+
+		offset, err := strconv.ParseInt(path.Base(fileInfo.Path()), 10, 64)
+		if err == nil && offset > upload.HashStateOffset {
+			upload.HashStateOffset = offset
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		switch err.(type) {
+		case storageDriver.PathNotFoundError:
+		default:
+			return upload, err
+		}
+	}
+
+	return upload, nil
+}
+
+// uploadDir returns the directory holding the files of an upload.
+func uploadDir(name, id string) (string, error) {
+	startedAtPath, err := defaultPathMapper.path(uploadStartedAtPathSpec{name: name, id: id})
+	if err != nil {
+		return "", err
+	}
+
+	return path.Dir(startedAtPath), nil
+}
+
+// uploadsByRepository sorts uploads by repository, then start time.
+type uploadsByRepository []UploadInfo
+
+func (u uploadsByRepository) Len() int      { return len(u) }
+func (u uploadsByRepository) Swap(i, j int) { u[i], u[j] = u[j], u[i] }
+func (u uploadsByRepository) Less(i, j int) bool {
+	if u[i].Repository != u[j].Repository {
+		return u[i].Repository < u[j].Repository
+	}
+
+	return u[i].StartedAt.Before(u[j].StartedAt)
+}
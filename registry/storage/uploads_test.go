@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"code.google.com/p/go-uuid/uuid"
+	"github.com/docker/distribution"
+)
+
+func TestListAndCancelUploads(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "v1")
+
+	uploads, err := ListUploads(env.ctx, env.driver, "")
+	if err != nil {
+		t.Fatalf("unexpected error listing uploads of empty registry: %v", err)
+	}
+
+	if len(uploads) != 0 {
+		t.Fatalf("unexpected uploads in empty registry: %#v", uploads)
+	}
+
+	bw, err := env.repository.Blobs(env.ctx).Create(env.ctx)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+
+	if _, err := bw.ReadFrom(bytes.NewReader([]byte("some layer data"))); err != nil {
+		t.Fatalf("unexpected error writing upload: %v", err)
+	}
+
+	if err := bw.Close(); err != nil {
+		t.Fatalf("unexpected error closing upload: %v", err)
+	}
+
+	// An older upload in another repository, with a saved hash state.
+	startedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	otherID := uuid.New()
+	addUploads(env.ctx, t, env.driver, otherID, "foo/baz", startedAt)
+	hashStatePath, err := pm.path(uploadHashStatePathSpec{name: "foo/baz", id: otherID, alg: "sha256", offset: 1024})
+	if err != nil {
+		t.Fatalf("unexpected error resolving path: %v", err)
+	}
+
+	if err := env.driver.PutContent(env.ctx, hashStatePath, []byte("state")); err != nil {
+		t.Fatalf("unexpected error writing hash state: %v", err)
+	}
+
+	uploads, err = ListUploads(env.ctx, env.driver, "")
+	if err != nil {
+		t.Fatalf("unexpected error listing uploads: %v", err)
+	}
+
+	if len(uploads) != 2 {
+		t.Fatalf("unexpected number of uploads: %#v", uploads)
+	}
+
+	if uploads[0].Repository != "foo/bar" || uploads[0].ID != bw.ID() || uploads[0].Size != 15 || uploads[0].StartedAt.IsZero() {
+		t.Fatalf("unexpected upload: %#v", uploads[0])
+	}
+
+	expected := UploadInfo{Repository: "foo/baz", ID: otherID, StartedAt: startedAt, HashStateOffset: 1024}
+	if uploads[1] != expected {
+		t.Fatalf("unexpected upload: %#v != %#v", uploads[1], expected)
+	}
+
+	uploads, err = ListUploads(env.ctx, env.driver, "foo/baz")
+	if err != nil {
+		t.Fatalf("unexpected error listing uploads of foo/baz: %v", err)
+	}
+
+	if len(uploads) != 1 || uploads[0] != expected {
+		t.Fatalf("unexpected uploads of foo/baz: %#v", uploads)
+	}
+
+	if _, err := ListUploads(env.ctx, env.driver, "Foo"); err == nil {
+		t.Fatalf("expected error listing uploads of invalid repository")
+	}
+
+	if err := CancelUpload(env.ctx, env.driver, env.name, bw.ID()); err != nil {
+		t.Fatalf("unexpected error cancelling upload: %v", err)
+	}
+
+	if _, err := env.repository.Blobs(env.ctx).Resume(env.ctx, bw.ID()); err != distribution.ErrBlobUploadUnknown {
+		t.Fatalf("expected cancelled upload to be unknown: %v", err)
+	}
+
+	if err := CancelUpload(env.ctx, env.driver, env.name, bw.ID()); err != distribution.ErrBlobUploadUnknown {
+		t.Fatalf("unexpected error cancelling unknown upload: %v", err)
+	}
+
+	if err := CancelUpload(env.ctx, env.driver, env.name, "../../_layers"); err != distribution.ErrBlobUploadUnknown {
+		t.Fatalf("unexpected error cancelling invalid upload: %v", err)
+	}
+
+	uploads, err = ListUploads(env.ctx, env.driver, "")
+	if err != nil {
+		t.Fatalf("unexpected error listing uploads: %v", err)
+	}
+
+	if len(uploads) != 1 || uploads[0].ID != otherID {
+		t.Fatalf("unexpected uploads after cancel: %#v", uploads)
+	}
+}
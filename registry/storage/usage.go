@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	storageDriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+// UsageReport describes how the bytes in the global blob store are used by
+// the repositories. A blob is linked into a repository as a layer, a
+// manifest revision or a signature.
+// registry 的存储用量报告
+type UsageReport struct {
+	// Repositories lists the usage of each repository with linked blobs,
+	// largest first.
+	Repositories []RepositoryUsage `json:"repositories"`
+
+	// TotalBytes is the size of all blob data in the blob store.
+	TotalBytes int64 `json:"totalBytes"`
+
+	// LogicalBytes is the sum of the logical bytes of all repositories. It
+	// is what the blob store would use if no blob was shared.
+	LogicalBytes int64 `json:"logicalBytes"`
+
+	// UniqueBytes is the size of the blobs linked into a single repository.
+	UniqueBytes int64 `json:"uniqueBytes"`
+
+	// SharedBytes is the size of the blobs linked into more than one
+	// repository, each counted once.
+	SharedBytes int64 `json:"sharedBytes"`
+
+	// SavedBytes is the number of bytes saved by sharing blobs between
+	// repositories, LogicalBytes less UniqueBytes and SharedBytes.
+	SavedBytes int64 `json:"savedBytes"`
+
+	// OrphanedBytes is the size of the blobs not linked into any
+	// repository, which garbage collection would remove.
+	OrphanedBytes int64 `json:"orphanedBytes"`
+}
+
+// RepositoryUsage reports the bytes used by a repository.
+type RepositoryUsage struct {
+	Name string `json:"name"`
+
+	// Blobs is the number of distinct blobs linked into the repository.
+	Blobs int `json:"blobs"`
+
+	// LogicalBytes is the size of all blobs linked into the repository.
+	LogicalBytes int64 `json:"logicalBytes"`
+
+	// UniqueBytes is the size of the blobs linked only into this
+	// repository, which removing the repository would free.
+	UniqueBytes int64 `json:"uniqueBytes"`
+
+	// SharedBytes is the size of the blobs also linked into other
+	// repositories.
+	SharedBytes int64 `json:"sharedBytes"`
+}
+
+// ReportUsage walks the links of every repository and the data of every
+// blob, reporting how the storage is used. The report is not a consistent
+// snapshot if the registry accepts writes while it runs.
+func ReportUsage(ctx context.Context, driver storageDriver.StorageDriver) (UsageReport, error) {
+	usage := UsageReport{
+		Repositories: []RepositoryUsage{},
+	}
+
+	sizes, err := blobDataSizes(ctx, driver)
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	repos, err := linkedBlobs(ctx, driver, "", func(string) bool { return true })
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	// Count the repositories linking each blob.
+	linkCounts := make(map[digest.Digest]int)
+	for _, blobs := range repos {
+		for dgst := range blobs {
+			linkCounts[dgst]++
+		}
+	}
+
+	for name, blobs := range repos {
+		repoUsage := RepositoryUsage{
+			Name:  name,
+			Blobs: len(blobs),
+		}
+
+		for dgst := range blobs {
+			size := sizes[dgst] // missing data counts for nothing
+
+			repoUsage.LogicalBytes += size
+			if linkCounts[dgst] > 1 {
+				repoUsage.SharedBytes += size
+			} else {
+				repoUsage.UniqueBytes += size
+			}
+		}
+
+		usage.Repositories = append(usage.Repositories, repoUsage)
+		usage.LogicalBytes += repoUsage.LogicalBytes
+	}
+
+	for dgst, size := range sizes {
+		usage.TotalBytes += size
+
+		switch linkCounts[dgst] {
+		case 0:
+			usage.OrphanedBytes += size
+		case 1:
+			usage.UniqueBytes += size
+		default:
+			usage.SharedBytes += size
+		}
+	}
+
+	usage.SavedBytes = usage.LogicalBytes - usage.UniqueBytes - usage.SharedBytes
+
+	sort.Sort(byLogicalBytes(usage.Repositories))
+
+	return usage, nil
+}
+
+// blobDataSizes walks the global blob store, returning the size of the data
+// of every blob.
+func blobDataSizes(ctx context.Context, driver storageDriver.StorageDriver) (map[digest.Digest]int64, error) {
+	root, err := defaultPathMapper.path(blobsPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[digest.Digest]int64)
+	err = Walk(ctx, driver, root, func(fileInfo storageDriver.FileInfo) error {
+		if fileInfo.IsDir() {
+			return nil
+		}
+
+		if dgst, ok := blobDigestFromPath(root, fileInfo.Path()); ok {
+			sizes[dgst] = fileInfo.Size()
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if isWalkRootNotFound(err, root) {
+			return sizes, nil // no blobs
+		}
+		return nil, err
+	}
+
+	return sizes, nil
+}
+
+// byLogicalBytes sorts repository usages by decreasing logical bytes, then
+// by name.
+type byLogicalBytes []RepositoryUsage
+
+func (b byLogicalBytes) Len() int      { return len(b) }
+func (b byLogicalBytes) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byLogicalBytes) Less(i, j int) bool {
+	if b[i].LogicalBytes != b[j].LogicalBytes {
+		return b[i].LogicalBytes > b[j].LogicalBytes
+	}
+
+	return b[i].Name < b[j].Name
+}
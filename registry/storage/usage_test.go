@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestReportUsage(t *testing.T) {
+	ctx := context.Background()
+	d := inmemory.New()
+	registry := NewRegistryWithDriver(ctx, d, nil)
+
+	usage, err := ReportUsage(ctx, d)
+	if err != nil {
+		t.Fatalf("unexpected error reporting usage of empty registry: %v", err)
+	}
+
+	if !reflect.DeepEqual(usage, UsageReport{Repositories: []RepositoryUsage{}}) {
+		t.Fatalf("unexpected usage of empty registry: %#v", usage)
+	}
+
+	blobs := func(name string) distribution.BlobStore {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository %s: %v", name, err)
+		}
+
+		return repo.Blobs(ctx)
+	}
+
+	put := func(name string, b byte, size int) distribution.Descriptor {
+		desc, err := blobs(name).Put(ctx, "application/octet-stream", bytes.Repeat([]byte{b}, size))
+		if err != nil {
+			t.Fatalf("unexpected error putting blob: %v", err)
+		}
+
+		return desc
+	}
+
+	shared := put("foo/bar", 'a', 10)
+	put("foo/bar", 'b', 20)
+	put("foo/baz", 'c', 30)
+	if _, err := blobs("foo/baz").Mount(ctx, "foo/bar", shared.Digest); err != nil {
+		t.Fatalf("unexpected error mounting blob: %v", err)
+	}
+
+	orphan := put("foo/qux", 'd', 40)
+	if err := blobs("foo/qux").Delete(ctx, orphan.Digest); err != nil {
+		t.Fatalf("unexpected error deleting blob: %v", err)
+	}
+
+	usage, err = ReportUsage(ctx, d)
+	if err != nil {
+		t.Fatalf("unexpected error reporting usage: %v", err)
+	}
+
+	expected := UsageReport{
+		Repositories: []RepositoryUsage{
+			{Name: "foo/baz", Blobs: 2, LogicalBytes: 40, UniqueBytes: 30, SharedBytes: 10},
+			{Name: "foo/bar", Blobs: 2, LogicalBytes: 30, UniqueBytes: 20, SharedBytes: 10},
+		},
+		TotalBytes:    100,
+		LogicalBytes:  70,
+		UniqueBytes:   50,
+		SharedBytes:   10,
+		SavedBytes:    10,
+		OrphanedBytes: 40,
+	}
+
+	if !reflect.DeepEqual(usage, expected) {
+		t.Fatalf("unexpected usage: %#v != %#v", usage, expected)
+	}
+}
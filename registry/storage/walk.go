@@ -3,6 +3,7 @@ package storage
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/docker/distribution/context"
 	storageDriver "github.com/docker/distribution/registry/storage/driver"
@@ -18,11 +19,15 @@ var ErrSkipDir = errors.New("skip this directory")
 // If the returned error is ErrSkipDir and fileInfo refers
 // to a directory, the directory will not be entered and Walk
 // will continue the traversal.  Otherwise Walk will return
+// the error, including from nested directories.
 // 作为被 Walk 调用的函数
 type WalkFn func(fileInfo storageDriver.FileInfo) error
 
 // Walk traverses a filesystem defined within driver, starting
-// from the given path, calling f on each file
+// from the given path, calling f on each file. The first error
+// listing or statting a file, or returned by f, stops the
+// traversal and is returned, so that callers never act on an
+// incomplete walk.
 // 对 driver 中定义的文件系统从 from 开始遍历，并对每个文件调用 f 函数 
 func Walk(ctx context.Context, driver storageDriver.StorageDriver, from string, f WalkFn) error {
 	children, err := driver.List(ctx, from)
@@ -41,12 +46,26 @@ func Walk(ctx context.Context, driver storageDriver.StorageDriver, from string,
 		}
 
 		if fileInfo.IsDir() && !skipDir {
-			Walk(ctx, driver, child, f)
+			if err := Walk(ctx, driver, child, f); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// isWalkRootNotFound returns true if err reports that the root of a walk
+// does not exist, as opposed to a file beneath it disappearing during the
+// walk, which leaves the walk incomplete.
+func isWalkRootNotFound(err error, root string) bool {
+	notFound, ok := err.(storageDriver.PathNotFoundError)
+	if !ok {
+		return false
+	}
+
+	return strings.TrimSuffix(notFound.Path, "/") == strings.TrimSuffix(root, "/")
+}
+
 // pushError formats an error type given a path and an error
 // and pushes it to a slice of errors
 // 格式化 error 和 path 信息， 并添加到 errors 数组切片上
@@ -52,8 +52,8 @@ func TestWalkErrors(t *testing.T) {
 	if len(expected) != fileCount-1 {
 		t.Error("Walk failed to terminate with error")
 	}
-	if err != nil {
-		t.Error(err.Error())
+	if err == nil || err.Error() != "Early termination" {
+		t.Errorf("Expected error from nested directory, got %v", err)
 	}
 
 	err = Walk(ctx, d, "/nonexistant", func(fileInfo driver.FileInfo) error {